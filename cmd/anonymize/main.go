@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/keep94/mailmerge/merge"
+	"github.com/keep94/toolbox/build"
+)
+
+var (
+	fCsv         string
+	fOut         string
+	fKey         string
+	fKeepDomains bool
+	fColumns     string
+	fVersion     bool
+)
+
+func main() {
+	flag.Parse()
+	if fVersion {
+		version, _ := build.MainVersion()
+		fmt.Println(build.BuildId(version))
+		return
+	}
+	if fCsv == "" || fOut == "" {
+		fmt.Println("-csv, and -out flags required.")
+		flag.Usage()
+		os.Exit(2)
+	}
+	csvFile, err := merge.ReadCsv(fCsv)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	key := []byte(fKey)
+	if fKey == "" {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	var others []string
+	if fColumns != "" {
+		others = strings.Split(fColumns, ",")
+	}
+	anon := csvFile.Anonymize(key, fKeepDomains, others...)
+	if err := anon.Write(fOut); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	flag.StringVar(&fCsv, "csv", "", "Path to source CSV file")
+	flag.StringVar(&fOut, "out", "", "Path to anonymized CSV file to create")
+	flag.StringVar(
+		&fKey,
+		"key",
+		"",
+		"Secret key. Same key, same pseudonyms. Default is a random key")
+	flag.BoolVar(&fKeepDomains, "keepdomains", false, "Keep email domains")
+	flag.StringVar(
+		&fColumns, "columns", "", "Comma separated other columns to anonymize")
+	flag.BoolVar(&fVersion, "version", false, "Show version")
+}
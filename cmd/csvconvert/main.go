@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/keep94/mailmerge/merge"
+	"github.com/keep94/toolbox/build"
+)
+
+var (
+	fIn      string
+	fOut     string
+	fVersion bool
+)
+
+func main() {
+	flag.Parse()
+	if fVersion {
+		version, _ := build.MainVersion()
+		fmt.Println(build.BuildId(version))
+		return
+	}
+	if fIn == "" || fOut == "" {
+		fmt.Println("-in, and -out flags required.")
+		flag.Usage()
+		os.Exit(2)
+	}
+	csvFile, err := read(fIn)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := write(csvFile, fOut); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// format returns "csv" or "json" based on the extension of path.
+func format(path string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".csv", ".json":
+		return ext[1:], nil
+	}
+	return "", fmt.Errorf("%s: only .csv and .json files are supported", path)
+}
+
+func read(path string) (*merge.CsvFile, error) {
+	f, err := format(path)
+	if err != nil {
+		return nil, err
+	}
+	if f == "csv" {
+		return merge.ReadCsv(path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var result merge.CsvFile
+	if err := json.Unmarshal(content, &result); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &result, nil
+}
+
+func write(csvFile *merge.CsvFile, path string) error {
+	f, err := format(path)
+	if err != nil {
+		return err
+	}
+	if f == "csv" {
+		return csvFile.Write(path)
+	}
+	content, err := json.MarshalIndent(csvFile, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0644)
+}
+
+func init() {
+	flag.StringVar(&fIn, "in", "", "Path to source .csv or .json file")
+	flag.StringVar(&fOut, "out", "", "Path to .csv or .json file being created")
+	flag.BoolVar(&fVersion, "version", false, "Show version")
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/keep94/mailmerge/merge"
+	"github.com/keep94/toolbox/build"
+)
+
+var (
+	fCsv     string
+	fSchema  bool
+	fJson    bool
+	fVersion bool
+)
+
+// stats are the counts that csvstats reports.
+type stats struct {
+	Rows       int
+	Going      int
+	Duplicates []string
+}
+
+func main() {
+	flag.Parse()
+	if fVersion {
+		version, _ := build.MainVersion()
+		fmt.Println(build.BuildId(version))
+		return
+	}
+	if fCsv == "" {
+		fatal("-csv flag required.", 2)
+	}
+	csvFile, err := merge.ReadCsv(fCsv)
+	if err != nil {
+		fatal(err.Error(), 1)
+	}
+	var schema *merge.Schema
+	if fSchema {
+		schema = merge.InferSchema(csvFile)
+	}
+	if fJson {
+		printJson(computeStats(csvFile), schema)
+		return
+	}
+	printStats(computeStats(csvFile))
+	if schema != nil {
+		fmt.Println()
+		printSchema(schema)
+	}
+}
+
+// fatal reports message and exits with code. With -json, message goes
+// out as a JSON object with type error.
+func fatal(message string, code int) {
+	if fJson {
+		json.NewEncoder(os.Stdout).Encode(
+			map[string]any{"type": "error", "error": message})
+		os.Exit(code)
+	}
+	fmt.Println(message)
+	if code == 2 {
+		flag.Usage()
+	}
+	os.Exit(code)
+}
+
+func computeStats(csvFile *merge.CsvFile) *stats {
+	seen := make(map[string]int)
+	var duplicates []string
+	for _, row := range csvFile.Rows {
+		email := strings.ToLower(row.Email())
+		seen[email]++
+		if seen[email] == 2 {
+			duplicates = append(duplicates, row.Email())
+		}
+	}
+	return &stats{
+		Rows:       len(csvFile.Rows),
+		Going:      len(csvFile.SelectGoing().Rows),
+		Duplicates: duplicates,
+	}
+}
+
+func printStats(s *stats) {
+	fmt.Println("Rows:", s.Rows)
+	fmt.Println("Going:", s.Going)
+	fmt.Println("Duplicate emails:", len(s.Duplicates))
+	for _, email := range s.Duplicates {
+		fmt.Println(" ", email)
+	}
+}
+
+// printJson prints s and, if not nil, schema as one JSON object per
+// line with a type field like mailmerge -json does.
+func printJson(s *stats, schema *merge.Schema) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	duplicates := s.Duplicates
+	if duplicates == nil {
+		duplicates = []string{}
+	}
+	encoder.Encode(map[string]any{
+		"type":       "stats",
+		"rows":       s.Rows,
+		"going":      s.Going,
+		"duplicates": duplicates,
+	})
+	if schema == nil {
+		return
+	}
+	for _, column := range schema.Columns {
+		encoder.Encode(map[string]any{
+			"type":       "column",
+			"name":       column.Name,
+			"columnType": column.Type,
+			"empty":      column.Empty,
+			"emptyRate":  column.EmptyRate,
+			"distinct":   column.Distinct,
+		})
+	}
+}
+
+func printSchema(schema *merge.Schema) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "COLUMN\tTYPE\tEMPTY\tDISTINCT")
+	for _, column := range schema.Columns {
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%d (%.0f%%)\t%d\n",
+			column.Name,
+			column.Type,
+			column.Empty,
+			100*column.EmptyRate,
+			column.Distinct)
+	}
+	w.Flush()
+}
+
+func init() {
+	flag.StringVar(&fCsv, "csv", "", "Path to CSV file")
+	flag.BoolVar(&fSchema, "schema", false, "Also show column types")
+	flag.BoolVar(
+		&fJson, "json", false, "Report to stdout as one JSON object per line")
+	flag.BoolVar(&fVersion, "version", false, "Show version")
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+)
+
+// email is the parts of an .eml file that a template needs.
+type email struct {
+	Subject string
+	Text    string
+	HTML    string
+	Files   []file
+}
+
+// file is an attachment or inline image.
+type file struct {
+	Name string
+
+	// The Content-ID without angle brackets, if any. HTML refers to inline
+	// images as cid:ContentId.
+	ContentId string
+
+	// True for inline images.
+	Inline bool
+
+	Content []byte
+}
+
+// readEml reads an .eml file.
+func readEml(r io.Reader) (*email, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	var decoder mime.WordDecoder
+	decoder.CharsetReader = charsetReader
+	subject, err := decoder.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		return nil, fmt.Errorf("Subject: %v", err)
+	}
+	result := &email{Subject: subject}
+	err = result.addPart(textproto.MIMEHeader(msg.Header), msg.Body)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// addPart adds the part with header and body to this email walking
+// into multipart parts.
+func (e *email) addPart(header textproto.MIMEHeader, body io.Reader) error {
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("Content-Type: %v", err)
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := e.addPart(part.Header, part); err != nil {
+				return err
+			}
+		}
+	}
+	content, err := io.ReadAll(decodeTransfer(header, body))
+	if err != nil {
+		return err
+	}
+	disposition, dispParams, _ := mime.ParseMediaType(
+		header.Get("Content-Disposition"))
+	name := dispParams["filename"]
+	if name == "" {
+		name = params["name"]
+	}
+	contentId := strings.Trim(header.Get("Content-ID"), "<>")
+	isText := mediaType == "text/plain" || mediaType == "text/html"
+	if disposition == "" && name == "" && isText {
+		text, err := decodeCharset(content, params["charset"])
+		if err != nil {
+			return err
+		}
+		if mediaType == "text/plain" && e.Text == "" {
+			e.Text = text
+			return nil
+		}
+		if mediaType == "text/html" && e.HTML == "" {
+			e.HTML = text
+			return nil
+		}
+	}
+	if name == "" {
+		name = fmt.Sprintf("part%d%s", len(e.Files)+1, extension(mediaType))
+	}
+	e.Files = append(e.Files, file{
+		Name:      filepath.Base(name),
+		ContentId: contentId,
+		Inline:    disposition != "attachment" && contentId != "",
+		Content:   content,
+	})
+	return nil
+}
+
+func extension(mediaType string) string {
+	extensions, _ := mime.ExtensionsByType(mediaType)
+	if len(extensions) == 0 {
+		return ""
+	}
+	return extensions[0]
+}
+
+// decodeTransfer undoes the Content-Transfer-Encoding of body.
+func decodeTransfer(header textproto.MIMEHeader, body io.Reader) io.Reader {
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
+
+// decodeCharset returns content as a string. Besides UTF-8, decodeCharset
+// understands the Latin-1 charsets that older mail programs use.
+func decodeCharset(content []byte, charset string) (string, error) {
+	reader, err := charsetReader(charset, bytes.NewReader(content))
+	if err != nil {
+		return "", err
+	}
+	result, err := io.ReadAll(reader)
+	return string(result), err
+}
+
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "", "utf-8", "us-ascii":
+		return input, nil
+	case "iso-8859-1", "latin1", "windows-1252":
+		content, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		var result bytes.Buffer
+		for _, b := range content {
+			result.WriteRune(rune(b))
+		}
+		return &result, nil
+	}
+	return nil, fmt.Errorf("unsupported charset %s", charset)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/keep94/toolbox/build"
+)
+
+var (
+	fEml     string
+	fDir     string
+	fName    string
+	fVersion bool
+)
+
+var (
+	kTags = regexp.MustCompile(
+		`(?s)<(script|style)\b.*?</(script|style)>|<[^>]*>`)
+	kBlankLines = regexp.MustCompile(`\n\s*\n(\s*\n)+`)
+)
+
+func main() {
+	flag.Parse()
+	if fVersion {
+		version, _ := build.MainVersion()
+		fmt.Println(build.BuildId(version))
+		return
+	}
+	if fEml == "" {
+		fmt.Println("-eml flag required.")
+		flag.Usage()
+		os.Exit(2)
+	}
+	f, err := os.Open(fEml)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	eml, err := readEml(f)
+	if err != nil {
+		fmt.Println(fmt.Errorf("%s: %v", fEml, err))
+		os.Exit(1)
+	}
+	base := strings.TrimSuffix(filepath.Base(fEml), filepath.Ext(fEml))
+	files := skeleton(eml, base, fName)
+	if err := writeFiles(fDir, files); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	var attachArgs strings.Builder
+	for _, f := range files {
+		fmt.Println("Created", filepath.Join(fDir, f.Name))
+		if !f.Inline && !f.Template {
+			fmt.Fprintf(&attachArgs, " -attach %s", filepath.Join(fDir, f.Name))
+		}
+	}
+	fmt.Println("Try it with:")
+	fmt.Printf(
+		"mailmerge -template %s -csv your.csv -subject %q%s -dryrun\n",
+		filepath.Join(fDir, files[0].Name),
+		personalize(eml.Subject, fName),
+		attachArgs.String())
+}
+
+// outputFile is a file that emltemplate writes.
+type outputFile struct {
+	Name    string
+	Content []byte
+
+	// True for the text and HTML templates
+	Template bool
+
+	// True for inline images
+	Inline bool
+}
+
+// skeleton returns the files to write for eml. The text template comes
+// first. If eml has no text part, skeleton makes one from the HTML part.
+// Inline images keep their names in the HTML template. If name is not
+// empty, skeleton replaces it with template fields.
+func skeleton(eml *email, base, name string) []outputFile {
+	text := eml.Text
+	if text == "" {
+		text = htmlToText(eml.HTML)
+	}
+	result := []outputFile{{
+		Name:     base + ".txt",
+		Content:  []byte(personalize(text, name)),
+		Template: true,
+	}}
+	used := map[string]bool{base + ".txt": true, base + ".html": true}
+	var files []outputFile
+	body := eml.HTML
+	for _, f := range eml.Files {
+		fileName := uniqueName(f.Name, used)
+		if f.ContentId != "" {
+			body = strings.ReplaceAll(body, "cid:"+f.ContentId, fileName)
+		}
+		files = append(
+			files,
+			outputFile{Name: fileName, Content: f.Content, Inline: f.Inline})
+	}
+	if body != "" {
+		result = append(result, outputFile{
+			Name:     base + ".html",
+			Content:  []byte(personalize(body, name)),
+			Template: true,
+		})
+	}
+	return append(result, files...)
+}
+
+// uniqueName returns name or, if name is in used, name with a number
+// added. uniqueName adds the result to used.
+func uniqueName(name string, used map[string]bool) string {
+	result := name
+	ext := filepath.Ext(name)
+	for i := 2; used[result]; i++ {
+		result = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), i, ext)
+	}
+	used[result] = true
+	return result
+}
+
+// personalize escapes text so that text/template leaves it alone and,
+// if name isn't empty, replaces the full name with {{.name}} and the
+// first word of name with {{firstName .name}}.
+func personalize(text, name string) string {
+	text = strings.ReplaceAll(text, "{{", `{{"{{"}}`)
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return text
+	}
+	text = strings.ReplaceAll(text, name, "{{.name}}")
+	if first, _, ok := strings.Cut(name, " "); ok {
+		text = regexp.MustCompile(`\b`+regexp.QuoteMeta(first)+`\b`).
+			ReplaceAllString(text, "{{firstName .name}}")
+	}
+	return text
+}
+
+// htmlToText returns the text of body without tags.
+func htmlToText(body string) string {
+	body = strings.NewReplacer(
+		"<br>", "\n", "<br/>", "\n", "<br />", "\n", "</p>", "\n\n",
+		"</div>", "\n").Replace(body)
+	text := html.UnescapeString(kTags.ReplaceAllString(body, ""))
+	return strings.TrimSpace(kBlankLines.ReplaceAllString(text, "\n\n")) +
+		"\n"
+}
+
+// writeFiles writes files to dir. writeFiles writes nothing if any of
+// them already exist in dir.
+func writeFiles(dir string, files []outputFile) error {
+	for _, f := range files {
+		_, err := os.Stat(filepath.Join(dir, f.Name))
+		if err == nil {
+			return fmt.Errorf("%s already exists", filepath.Join(dir, f.Name))
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, f := range files {
+		err := os.WriteFile(filepath.Join(dir, f.Name), f.Content, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	flag.StringVar(
+		&fEml, "eml", "", "Path to .eml file to turn into a template")
+	flag.StringVar(&fDir, "dir", ".", "Directory to create files in")
+	flag.StringVar(
+		&fName,
+		"name",
+		"",
+		"Name of the person the email was written to e.g 'Alice Smith'. "+
+			"Becomes template fields")
+	flag.BoolVar(&fVersion, "version", false, "Show version")
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/keep94/mailmerge/merge"
+	"github.com/keep94/toolbox/build"
+)
+
+var (
+	fRows    int
+	fOut     string
+	fSeed    int64
+	fVersion bool
+)
+
+var (
+	kFirstNames = []string{
+		"Alice", "Bob", "Carol", "David", "Elena", "Farid", "Grace", "Hiro",
+		"Ines", "James", "Keiko", "Liam", "Maria", "Noah", "Olga", "Priya",
+		"Quinn", "Rosa", "Sam", "Tariq", "Uma", "Victor", "Wen", "Yusuf",
+	}
+	kLastNames = []string{
+		"Anderson", "Brown", "Chen", "Diaz", "Evans", "Fischer", "Garcia",
+		"Haddad", "Ito", "Jones", "Kowalski", "Lopez", "Martin", "Nguyen",
+		"O'Brien", "Patel", "Rossi", "Smith", "Tanaka", "van der Berg",
+	}
+	kTitles  = []string{"Dr.", "Mr.", "Ms.", "Prof."}
+	kPets    = []string{"Rufus", "Patches", "Milo", "Luna", "Biscuit", "Max"}
+	kDomains = []string{"example.com", "example.org", "example.net"}
+)
+
+func main() {
+	flag.Parse()
+	if fVersion {
+		version, _ := build.MainVersion()
+		fmt.Println(build.BuildId(version))
+		return
+	}
+	if fOut == "" || fRows < 0 {
+		fmt.Println("-out flag required and -rows must not be negative.")
+		flag.Usage()
+		os.Exit(2)
+	}
+	csvFile := generate(rand.New(rand.NewSource(fSeed)), fRows)
+	if err := csvFile.Write(fOut); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// generate returns a CsvFile of count made up people. Emails are unique
+// and use only example domains.
+func generate(r *rand.Rand, count int) *merge.CsvFile {
+	result := &merge.CsvFile{
+		Headers: []string{
+			merge.Name, merge.Email, merge.Going, "guests", "petname"},
+	}
+	used := make(map[string]int)
+	for i := 0; i < count; i++ {
+		first := pick(r, kFirstNames)
+		last := pick(r, kLastNames)
+		name := first + " " + last
+		if r.Intn(10) == 0 {
+			name = pick(r, kTitles) + " " + name
+		}
+		local := strings.ToLower(
+			first + "." + strings.NewReplacer(" ", "", "'", "").Replace(last))
+		used[local]++
+		if used[local] > 1 {
+			local += strconv.Itoa(used[local])
+		}
+		going := "yes"
+		switch r.Intn(10) {
+		case 0:
+			going = "no"
+		case 1:
+			going = ""
+		}
+		pet := ""
+		if r.Intn(2) == 0 {
+			pet = pick(r, kPets)
+		}
+		result.Rows = append(result.Rows, merge.CsvRow{
+			merge.Name:  name,
+			merge.Email: local + "@" + pick(r, kDomains),
+			merge.Going: going,
+			"guests":    strconv.Itoa(r.Intn(4)),
+			"petname":   pet,
+		})
+	}
+	return result
+}
+
+func pick(r *rand.Rand, choices []string) string {
+	return choices[r.Intn(len(choices))]
+}
+
+func init() {
+	flag.IntVar(&fRows, "rows", 100, "Number of rows")
+	flag.StringVar(&fOut, "out", "", "Path to CSV file being created")
+	flag.Int64Var(&fSeed, "seed", 1, "Random seed. Same seed, same file")
+	flag.BoolVar(&fVersion, "version", false, "Show version")
+}
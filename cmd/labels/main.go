@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+
+	"github.com/keep94/mailmerge/merge"
+	"github.com/keep94/toolbox/build"
+)
+
+var (
+	fCsv     string
+	fOut     string
+	fFormat  string
+	fAddress string
+	fVersion bool
+)
+
+// layout describes how labels go on a printed page.
+type layout struct {
+	PageWidth     string
+	PageHeight    string
+	Columns       int
+	Rows          int
+	LabelWidth    string
+	LabelHeight   string
+	MarginTop     string
+	MarginLeft    string
+	ColumnGap     string
+	AddressTop    string
+	AddressLeft   string
+	LabelFontSize string
+}
+
+var kLayouts = map[string]*layout{
+	"avery5160": {
+		PageWidth:     "8.5in",
+		PageHeight:    "11in",
+		Columns:       3,
+		Rows:          10,
+		LabelWidth:    "2.625in",
+		LabelHeight:   "1in",
+		MarginTop:     "0.5in",
+		MarginLeft:    "0.1875in",
+		ColumnGap:     "0.125in",
+		AddressTop:    "0.1in",
+		AddressLeft:   "0.15in",
+		LabelFontSize: "10pt",
+	},
+	"envelope10": {
+		PageWidth:     "9.5in",
+		PageHeight:    "4.125in",
+		Columns:       1,
+		Rows:          1,
+		LabelWidth:    "9.5in",
+		LabelHeight:   "4.125in",
+		MarginTop:     "0in",
+		MarginLeft:    "0in",
+		ColumnGap:     "0in",
+		AddressTop:    "2in",
+		AddressLeft:   "4.25in",
+		LabelFontSize: "12pt",
+	},
+}
+
+var kPageTemplate = template.Must(template.New("labels").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<style>
+@page { size: {{.Layout.PageWidth}} {{.Layout.PageHeight}}; margin: 0; }
+body { margin: 0; font-family: sans-serif; }
+.page {
+  width: {{.Layout.PageWidth}};
+  height: {{.Layout.PageHeight}};
+  padding: {{.Layout.MarginTop}} 0 0 {{.Layout.MarginLeft}};
+  box-sizing: border-box;
+  display: grid;
+  grid-template-columns: repeat({{.Layout.Columns}}, {{.Layout.LabelWidth}});
+  grid-auto-rows: {{.Layout.LabelHeight}};
+  column-gap: {{.Layout.ColumnGap}};
+  page-break-after: always;
+  overflow: hidden;
+}
+.label {
+  padding: {{.Layout.AddressTop}} 0 0 {{.Layout.AddressLeft}};
+  box-sizing: border-box;
+  font-size: {{.Layout.LabelFontSize}};
+  line-height: 1.2;
+  overflow: hidden;
+}
+</style>
+</head>
+<body>
+{{range .Pages}}<div class="page">
+{{range .}}<div class="label">{{range .}}{{.}}<br>{{end}}</div>
+{{end}}</div>
+{{end}}</body>
+</html>
+`))
+
+func main() {
+	flag.Parse()
+	if fVersion {
+		version, _ := build.MainVersion()
+		fmt.Println(build.BuildId(version))
+		return
+	}
+	if fCsv == "" || fOut == "" {
+		fmt.Println("-csv, and -out flags required.")
+		flag.Usage()
+		os.Exit(2)
+	}
+	layout, ok := kLayouts[fFormat]
+	if !ok {
+		fmt.Println("-format must be avery5160 or envelope10.")
+		os.Exit(2)
+	}
+	csvFile, err := merge.ReadCsv(fCsv)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	columns := strings.Split(fAddress, ",")
+	var labels [][]string
+	for _, row := range csvFile.Rows {
+		labels = append(labels, addressLines(row, columns))
+	}
+	if err := writeLabels(fOut, layout, labels); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// addressLines returns the lines of the address label for row. The first
+// line is the name. Cells may themselves hold several lines.
+func addressLines(row merge.CsvRow, columns []string) []string {
+	result := []string{row.Name()}
+	for _, column := range columns {
+		cell := row[strings.TrimSpace(column)]
+		for _, line := range strings.Split(cell, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				result = append(result, line)
+			}
+		}
+	}
+	return result
+}
+
+func writeLabels(path string, layout *layout, labels [][]string) error {
+	perPage := layout.Columns * layout.Rows
+	var pages [][][]string
+	for len(labels) > perPage {
+		pages = append(pages, labels[:perPage])
+		labels = labels[perPage:]
+	}
+	if len(labels) > 0 {
+		pages = append(pages, labels)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return kPageTemplate.Execute(
+		f,
+		map[string]any{"Layout": layout, "Pages": pages})
+}
+
+func init() {
+	flag.StringVar(&fCsv, "csv", "", "Path to CSV file")
+	flag.StringVar(&fOut, "out", "", "Path to HTML file being created")
+	flag.StringVar(
+		&fFormat, "format", "avery5160", "avery5160 or envelope10")
+	flag.StringVar(
+		&fAddress,
+		"address",
+		"address",
+		"Comma separated columns making up the address below the name")
+	flag.BoolVar(&fVersion, "version", false, "Show version")
+}
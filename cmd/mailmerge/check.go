@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+
+	"github.com/keep94/mailmerge/mailer"
+)
+
+// checkError returns err, from checking the backend before sending,
+// along with what to do about it.
+func checkError(config *config, drafts bool, from string, err error) error {
+	hint := checkHint(config, drafts, from, err)
+	if hint == "" {
+		return err
+	}
+	return fmt.Errorf("%v\n  %s", err, hint)
+}
+
+func checkHint(config *config, drafts bool, from string, err error) string {
+	if from == "" {
+		from = config.EmailId
+	}
+	var protocolErr *textproto.Error
+	var imapErr *mailer.ImapError
+	var apiErr *mailer.ApiError
+	var oauthErr *mailer.OAuthError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &protocolErr):
+		switch protocolErr.Code {
+		case 530, 534, 535:
+			return "Check emailId and password in .mailmerge.yaml. " +
+				"gmail needs an app password rather than your usual one."
+		case 550, 553, 555:
+			return fmt.Sprintf(
+				"The server won't let %s send as %s. "+
+					"Use -from with an address the server allows.",
+				config.EmailId, from)
+		}
+	case errors.As(err, &imapErr):
+		if imapErr.Command == "LOGIN" {
+			return "Check emailId and password in .mailmerge.yaml. " +
+				"Some servers need IMAP turned on in their settings."
+		}
+	case errors.As(err, &oauthErr):
+		return "The oauth credentials in .mailmerge.yaml were refused. " +
+			"An expired or revoked refreshToken must be authorized again."
+	case errors.As(err, &apiErr):
+		if apiErr.StatusCode == 401 || apiErr.StatusCode == 403 {
+			return "Check that the app in oauth has permission to send mail."
+		}
+	case errors.As(err, &netErr):
+		if drafts {
+			return "Check imapHost and imapPort in .mailmerge.yaml " +
+				"and your network connection."
+		}
+		if config.Backend == "" || config.Backend == "smtp" {
+			return "Check smtpHost and smtpPort in .mailmerge.yaml " +
+				"and your network connection."
+		}
+		return "Check your network connection."
+	}
+	return ""
+}
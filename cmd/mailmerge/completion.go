@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	// Flags whose values are paths
+	kPathFlags = map[string]bool{
+		"template":      true,
+		"html-template": true,
+		"layout":        true,
+		"csv":           true,
+		"seedlist":      true,
+		"attach":        true,
+		"plugin":        true,
+		"campaigns":     true,
+		"config":        true,
+		"queue":         true,
+		"flush":         true,
+	}
+
+	// Flags whose values come from a fixed list
+	kFlagChoices = map[string][]string{
+		"screen":     {"report", "exclude"},
+		"priority":   {"high", "normal", "low"},
+		"completion": {"bash", "zsh", "fish"},
+		"lang":       {"en", "es", "fr", "de"},
+	}
+)
+
+// writeCompletion writes the completion script for shell to w.
+func writeCompletion(w io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		writeBashCompletion(w)
+	case "zsh":
+		writeZshCompletion(w)
+	case "fish":
+		writeFishCompletion(w)
+	default:
+		return fmt.Errorf("-completion must be bash, zsh, or fish: %s", shell)
+	}
+	return nil
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func writeBashCompletion(w io.Writer) {
+	var names, pathFlags []string
+	flag.VisitAll(func(f *flag.Flag) {
+		names = append(names, "-"+f.Name)
+		if kPathFlags[f.Name] {
+			pathFlags = append(pathFlags, "-"+f.Name)
+		}
+	})
+	fmt.Fprintln(w, "_mailmerge() {")
+	fmt.Fprintln(w, `  local cur="${COMP_WORDS[COMP_CWORD]}"`)
+	fmt.Fprintln(w, `  local prev="${COMP_WORDS[COMP_CWORD-1]}"`)
+	fmt.Fprintln(w, `  case "$prev" in`)
+	fmt.Fprintf(w, "    %s)\n", strings.Join(pathFlags, "|"))
+	fmt.Fprintln(w, `      COMPREPLY=($(compgen -f -- "$cur")); return;;`)
+	flag.VisitAll(func(f *flag.Flag) {
+		if choices, ok := kFlagChoices[f.Name]; ok {
+			fmt.Fprintf(w, "    -%s)\n", f.Name)
+			fmt.Fprintf(
+				w,
+				"      COMPREPLY=($(compgen -W %q -- \"$cur\")); return;;\n",
+				strings.Join(choices, " "))
+		}
+	})
+	fmt.Fprintln(w, "  esac")
+	fmt.Fprintf(
+		w,
+		"  COMPREPLY=($(compgen -W %q -- \"$cur\"))\n",
+		strings.Join(names, " "))
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -o filenames -F _mailmerge mailmerge")
+}
+
+func writeZshCompletion(w io.Writer) {
+	fmt.Fprintln(w, "#compdef mailmerge")
+	fmt.Fprintln(w, "_arguments \\")
+	flag.VisitAll(func(f *flag.Flag) {
+		usage := strings.NewReplacer(
+			"'", "'\\''", "[", "\\[", "]", "\\]", ":", "\\:").Replace(f.Usage)
+		spec := fmt.Sprintf("-%s[%s]", f.Name, usage)
+		if choices, ok := kFlagChoices[f.Name]; ok {
+			spec += fmt.Sprintf(":%s:(%s)", f.Name, strings.Join(choices, " "))
+		} else if kPathFlags[f.Name] {
+			spec += ":file:_files"
+		} else if !isBoolFlag(f) {
+			spec += fmt.Sprintf(":%s:", f.Name)
+		}
+		if _, ok := f.Value.(*stringList); ok {
+			spec = "*" + spec
+		}
+		fmt.Fprintf(w, "  '%s' \\\n", spec)
+	})
+	fmt.Fprintln(w)
+}
+
+func writeFishCompletion(w io.Writer) {
+	flag.VisitAll(func(f *flag.Flag) {
+		usage := strings.ReplaceAll(f.Usage, "'", "\\'")
+		line := fmt.Sprintf(
+			"complete -c mailmerge -o %s -d '%s'", f.Name, usage)
+		if choices, ok := kFlagChoices[f.Name]; ok {
+			line += fmt.Sprintf(" -x -a '%s'", strings.Join(choices, " "))
+		} else if kPathFlags[f.Name] {
+			line += " -r -F"
+		} else if !isBoolFlag(f) {
+			line += " -x"
+		}
+		fmt.Fprintln(w, line)
+	})
+}
@@ -0,0 +1,628 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/mail"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/keep94/mailmerge/mailer"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	kDefaultSmtpPort       = 587
+	kDefaultImapPort       = 993
+	kDefaultMaxMessageSize = 25000000
+	kDefaultSendWaitTime   = 100 * time.Millisecond
+	kSystemConfigPath      = "/etc/mailmerge.yaml"
+)
+
+var (
+	kUnmarshalError = regexp.MustCompile(
+		`cannot unmarshal !!\w+ (.*) into (\w+)`)
+	kNotFoundError = regexp.MustCompile(`not found in type main\.\w+`)
+
+	kUnmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+	kEnvVar          = regexp.MustCompile(
+		`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+)
+
+type config struct {
+	EmailId    string   `yaml:"emailId,omitempty"`
+	Password   string   `yaml:"password,omitempty"`
+	SmtpHost   string   `yaml:"smtpHost,omitempty"`
+	SmtpPort   int      `yaml:"smtpPort,omitempty"`
+	Identities []string `yaml:"identities,omitempty"`
+
+	// How to send: smtp, the default, gmailapi, or graph. gmailapi and
+	// graph send through the Gmail API and Microsoft Graph using oauth
+	// instead of password.
+	Backend string       `yaml:"backend,omitempty"`
+	OAuth   *oauthConfig `yaml:"oauth,omitempty"`
+
+	// Where -drafts saves drafts. The default is imap.gmail.com port
+	// 993 and the Drafts mailbox. For gmail, set draftsMailbox to
+	// [Gmail]/Drafts.
+	ImapHost      string `yaml:"imapHost,omitempty"`
+	ImapPort      int    `yaml:"imapPort,omitempty"`
+	DraftsMailbox string `yaml:"draftsMailbox,omitempty"`
+
+	// If set, the mailbox, such as Sent, that gets a copy of each email
+	// sent over SMTP. For SMTP servers that don't keep sent emails.
+	// Uses imapHost and imapPort.
+	CopyToSent string `yaml:"copyToSent,omitempty"`
+
+	// Emails bigger than this many bytes are not sent. The default is
+	// gmail's limit.
+	MaxMessageSize int `yaml:"maxMessageSize,omitempty"`
+
+	// Emails bigger than this many bytes draw a warning. 0 means no
+	// warning.
+	WarnMessageSize int `yaml:"warnMessageSize,omitempty"`
+
+	// Shell command run for each row before any email is sent. Gets the
+	// row as JSON on stdin.
+	PreSendHook string `yaml:"preSendHook,omitempty"`
+
+	// Shell command run for each row after its email is sent. Gets the
+	// row as JSON on stdin.
+	PostSendHook string `yaml:"postSendHook,omitempty"`
+
+	// If true, a failing preSendHook skips the row instead of stopping
+	// mailmerge.
+	SkipOnHookFailure bool `yaml:"skipOnHookFailure,omitempty"`
+
+	// How long to wait between emails e.g 2s. The default is 100ms.
+	SendWaitTime time.Duration `yaml:"sendWaitTime,omitempty"`
+
+	// Daily caps for a new account e.g [50, 100, 200] sends at most 50
+	// emails the first day, 100 the second, 200 the third, and then no
+	// more caps.
+	Warmup []int `yaml:"warmup,omitempty"`
+
+	// Where mailmerge remembers what each account sent during warm-up.
+	// The default is $HOME/.mailmerge-warmup.json.
+	WarmupState string `yaml:"warmupState,omitempty"`
+
+	// Where mailmerge remembers recent runs to catch sending the same
+	// run twice. The default is $HOME/.mailmerge-runs.json.
+	RunHistory string `yaml:"runHistory,omitempty"`
+
+	// Settings for each organization, selected with -tenant. Settings a
+	// tenant leaves out come from the top level.
+	Tenants map[string]*config `yaml:"tenants,omitempty"`
+
+	// Config files whose settings this file builds on. Paths are
+	// relative to the directory of this file.
+	Include []string `yaml:"include,omitempty"`
+
+	// The settings that the config files set keyed by yaml name so that
+	// overlay can tell a setting of false or 0 from no setting.
+	set map[string]bool
+}
+
+// oauthConfig holds the OAuth credentials for the gmailapi and graph
+// backends.
+type oauthConfig struct {
+	ClientId     string `yaml:"clientId,omitempty"`
+	ClientSecret string `yaml:"clientSecret,omitempty"`
+
+	// Optional for graph which, without it, signs in as the app.
+	RefreshToken string `yaml:"refreshToken,omitempty"`
+
+	// The Microsoft 365 directory (tenant) ID. graph only.
+	TenantId string `yaml:"tenantId,omitempty"`
+}
+
+// withTenant returns this config with the fields that tenant sets
+// replacing this config's fields.
+func (c *config) withTenant(tenant *config) *config {
+	result := *c
+	result.Tenants = nil
+	return result.overlay(tenant)
+}
+
+// overlay returns this config with the fields that top sets replacing
+// this config's fields. If top didn't come from a config file, the
+// fields top sets are those that aren't zero.
+func (c *config) overlay(top *config) *config {
+	result := *c
+	result.set = make(map[string]bool)
+	maps.Copy(result.set, c.set)
+	maps.Copy(result.set, top.set)
+	dest := reflect.ValueOf(&result).Elem()
+	src := reflect.ValueOf(top).Elem()
+	for i := 0; i < src.NumField(); i++ {
+		field := src.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if top.set[name] || top.set == nil && !src.Field(i).IsZero() {
+			dest.Field(i).Set(src.Field(i))
+		}
+	}
+	return &result
+}
+
+// markSet records in this config, and in each of its tenants, which
+// settings node, the mapping this config came from, sets.
+func (c *config) markSet(node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	c.set = make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		c.set[key] = true
+		if key != "tenants" || value.Kind != yaml.MappingNode {
+			continue
+		}
+		for j := 0; j+1 < len(value.Content); j += 2 {
+			if tenant := c.Tenants[value.Content[j].Value]; tenant != nil {
+				tenant.markSet(value.Content[j+1])
+			}
+		}
+	}
+}
+
+// identity returns the identity in this config with the same address as
+// from. The result may include a display name. emailId is always an
+// identity.
+func (c *config) identity(from string) (string, error) {
+	fromAddr, err := mail.ParseAddress(from)
+	if err != nil {
+		return "", fmt.Errorf("-from: %v", err)
+	}
+	for _, id := range append([]string{c.EmailId}, c.Identities...) {
+		idAddr, err := mail.ParseAddress(id)
+		if err == nil && strings.EqualFold(idAddr.Address, fromAddr.Address) {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf(
+		"-from: %s is not emailId or one of the identities in config",
+		fromAddr.Address)
+}
+
+// check reports every bad value in this config, as read from one config
+// file, at once. Unlike validate, check doesn't look for settings that
+// are missing, as another config file may have them.
+func (c *config) check() error {
+	problems := c.problems()
+	for _, name := range slices.Sorted(maps.Keys(c.Tenants)) {
+		tenant := c.Tenants[name]
+		if tenant == nil {
+			continue
+		}
+		for _, problem := range tenant.problems() {
+			problems = append(
+				problems, fmt.Sprintf("tenants: %s: %s", name, problem))
+		}
+		if len(tenant.Tenants) > 0 {
+			problems = append(
+				problems,
+				fmt.Sprintf("tenants: %s: tenants may not have tenants", name))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// problems returns the bad values in this config not counting its
+// tenants.
+func (c *config) problems() []string {
+	var problems []string
+	switch c.Backend {
+	case "", "smtp", "gmailapi", "graph":
+	default:
+		problems = append(
+			problems,
+			fmt.Sprintf(
+				"backend must be smtp, gmailapi, or graph: %s", c.Backend))
+	}
+	for _, id := range c.Identities {
+		if _, err := mail.ParseAddress(id); err != nil {
+			problems = append(
+				problems, fmt.Sprintf("identities: %s: %v", id, err))
+		}
+	}
+	if c.MaxMessageSize < 0 {
+		problems = append(problems, "maxMessageSize must not be negative")
+	}
+	if c.WarnMessageSize < 0 {
+		problems = append(problems, "warnMessageSize must not be negative")
+	}
+	if c.SmtpPort < 0 || c.SmtpPort > 65535 {
+		problems = append(
+			problems, fmt.Sprintf("smtpPort out of range: %d", c.SmtpPort))
+	}
+	if c.ImapPort < 0 || c.ImapPort > 65535 {
+		problems = append(
+			problems, fmt.Sprintf("imapPort out of range: %d", c.ImapPort))
+	}
+	if c.SendWaitTime < 0 {
+		problems = append(problems, "sendWaitTime must not be negative")
+	}
+	for _, limit := range c.Warmup {
+		if limit <= 0 {
+			problems = append(
+				problems, fmt.Sprintf("warmup: %d must be positive", limit))
+		}
+	}
+	return problems
+}
+
+// validate reports every missing setting at once and fills in defaults.
+// validate expects check to have passed for each config file. If
+// credentials is false, validate doesn't require the password or oauth.
+func (c *config) validate(credentials bool) error {
+	var problems []string
+	if c.EmailId == "" {
+		problems = append(problems, "emailId is required")
+	}
+	switch c.Backend {
+	case "", "smtp":
+		if c.Password == "" && credentials {
+			problems = append(problems, "password is required")
+		}
+	case "gmailapi", "graph":
+		if credentials {
+			problems = append(problems, c.OAuth.problems(c.Backend)...)
+		}
+	}
+	if c.SmtpPort != 0 && c.SmtpHost == "" {
+		problems = append(problems, "smtpPort requires smtpHost")
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n  "))
+	}
+	if c.SmtpHost != "" && c.SmtpPort == 0 {
+		c.SmtpPort = kDefaultSmtpPort
+	}
+	if c.ImapHost != "" && c.ImapPort == 0 {
+		c.ImapPort = kDefaultImapPort
+	}
+	if c.MaxMessageSize == 0 {
+		c.MaxMessageSize = kDefaultMaxMessageSize
+	}
+	if c.SendWaitTime == 0 {
+		c.SendWaitTime = kDefaultSendWaitTime
+	}
+	if c.WarmupState == "" {
+		c.WarmupState = path.Join(os.Getenv("HOME"), ".mailmerge-warmup.json")
+	}
+	if c.RunHistory == "" {
+		c.RunHistory = path.Join(os.Getenv("HOME"), ".mailmerge-runs.json")
+	}
+	return nil
+}
+
+// problems reports the OAuth credentials that backend needs but are
+// missing.
+func (o *oauthConfig) problems(backend string) []string {
+	if o == nil {
+		return []string{fmt.Sprintf("backend %s requires oauth", backend)}
+	}
+	var result []string
+	if o.ClientId == "" {
+		result = append(result, "oauth: clientId is required")
+	}
+	if o.ClientSecret == "" {
+		result = append(result, "oauth: clientSecret is required")
+	}
+	if o.RefreshToken == "" && backend == "gmailapi" {
+		result = append(result, "oauth: refreshToken is required")
+	}
+	if o.TenantId == "" && backend == "graph" {
+		result = append(result, "oauth: tenantId is required")
+	}
+	return result
+}
+
+func (o *oauthConfig) credentials() mailer.OAuthCredentials {
+	return mailer.OAuthCredentials{
+		ClientId:     o.ClientId,
+		ClientSecret: o.ClientSecret,
+		RefreshToken: o.RefreshToken,
+	}
+}
+
+// readConfig reads the config. The config comes in layers, each
+// replacing the settings that it sets in the ones before: the system
+// config if there is one, $HOME/.mailmerge.yaml, and the file at layer
+// unless layer is empty. If tenant is not empty, readConfig returns the
+// settings for that tenant. If credentials is false, the password and
+// oauth may be missing.
+func readConfig(layer, tenant string, credentials bool) (*config, error) {
+	result, _, err := loadConfig(layer, tenant, credentials)
+	return result, err
+}
+
+// loadConfig works like readConfig but also returns the files it read
+// with each file after the files whose settings it replaces.
+func loadConfig(layer, tenant string, credentials bool) (
+	*config, []string, error) {
+	layers := []string{configPath()}
+	if _, err := os.Stat(kSystemConfigPath); err == nil {
+		layers = slices.Insert(layers, 0, kSystemConfigPath)
+	}
+	if layer != "" {
+		layers = append(layers, layer)
+	}
+	var files []string
+	merged := &config{}
+	for _, path := range layers {
+		c, err := readConfigFile(path, nil, &files)
+		if err != nil {
+			return nil, nil, err
+		}
+		merged = merged.overlay(c)
+	}
+	merged.Include = nil
+	result, err := merged.forTenant(tenant, credentials)
+	if err != nil {
+		return nil, nil, fmt.Errorf(
+			"%s:\n  %v", strings.Join(files, ", "), err)
+	}
+	return result, files, nil
+}
+
+// readConfigFile reads the config file at configPath along with the
+// files it includes. Settings in configPath replace those in the files
+// it includes. readConfigFile adds each file read to files after the
+// files it includes. including lists the files including configPath.
+func readConfigFile(
+	configPath string, including []string, files *[]string) (
+	*config, error) {
+	if slices.Contains(including, configPath) {
+		return nil, fmt.Errorf("%s: includes itself", configPath)
+	}
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	var top config
+	if err := decodeYaml(content, &top); err != nil {
+		return nil, fmt.Errorf("%s:\n  %v", configPath, yamlError(err))
+	}
+	if err := top.check(); err != nil {
+		return nil, fmt.Errorf("%s:\n  %v", configPath, err)
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err == nil &&
+		len(root.Content) > 0 {
+		top.markSet(root.Content[0])
+	}
+	including = append(including, configPath)
+	result := &config{}
+	for _, include := range top.Include {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(configPath), include)
+		}
+		c, err := readConfigFile(include, including, files)
+		if err != nil {
+			return nil, err
+		}
+		result = result.overlay(c)
+	}
+	*files = append(*files, configPath)
+	return result.overlay(&top), nil
+}
+
+// configPath returns the path of .mailmerge.yaml.
+func configPath() string {
+	return path.Join(os.Getenv("HOME"), ".mailmerge.yaml")
+}
+
+// forTenant returns the settings for tenant, or the top level settings
+// if tenant is empty, after validating them.
+func (c *config) forTenant(tenant string, credentials bool) (*config, error) {
+	selected := c
+	if tenant != "" {
+		t, ok := c.Tenants[tenant]
+		if !ok || t == nil {
+			return nil, fmt.Errorf("tenant %s is not in tenants", tenant)
+		}
+		selected = c.withTenant(t)
+	} else if len(c.Tenants) > 0 {
+		// Leave the tenants out of the config that gets used.
+		selected = c.withTenant(&config{})
+	}
+	if err := selected.validate(credentials); err != nil {
+		return nil, err
+	}
+	return selected, nil
+}
+
+// writeEffective writes this config as YAML to w with secrets hidden
+// after a comment listing files, the files it came from, as loadConfig
+// returns them.
+func (c *config) writeEffective(w io.Writer, files []string) error {
+	shown := *c
+	hide := func(secret *string) {
+		if *secret != "" {
+			*secret = "********"
+		}
+	}
+	hide(&shown.Password)
+	if c.OAuth != nil {
+		oauth := *c.OAuth
+		hide(&oauth.ClientSecret)
+		hide(&oauth.RefreshToken)
+		shown.OAuth = &oauth
+	}
+	fmt.Fprintln(w, "# Later files replace settings in earlier ones")
+	for _, file := range files {
+		if _, err := fmt.Fprintln(w, "# from", file); err != nil {
+			return err
+		}
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&shown); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// decodeYaml decodes content into out rejecting unknown fields. Each
+// ${VAR} in a value becomes the value of environment variable VAR so
+// that secrets can stay out of files. decodeYaml returns an error for
+// each VAR that isn't set.
+func decodeYaml(content []byte, out any) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return err
+	}
+	var problems []string
+	expandEnv(&root, &problems)
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n  "))
+	}
+
+	// Node.Decode can't reject unknown fields, so check for them in the
+	// same expanded content that gets decoded.
+	unknownFields(&root, reflect.TypeOf(out), &problems)
+	if err := root.Decode(out); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return err
+		}
+		problems = append(problems, typeErr.Errors...)
+	}
+	if len(problems) > 0 {
+		return &yaml.TypeError{Errors: problems}
+	}
+	return nil
+}
+
+// unknownFields adds a problem for each key in node that no field of t
+// has. The problems read like those of a yaml.Decoder that rejects
+// unknown fields. Types that decode themselves are left alone.
+func unknownFields(node *yaml.Node, t reflect.Type, problems *[]string) {
+	if reflect.PointerTo(t).Implements(kUnmarshalerType) {
+		return
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			unknownFields(child, t, problems)
+		}
+	case yaml.SequenceNode:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for _, child := range node.Content {
+				unknownFields(child, t.Elem(), problems)
+			}
+		}
+	case yaml.MappingNode:
+		switch t.Kind() {
+		case reflect.Map:
+			for i := 1; i < len(node.Content); i += 2 {
+				unknownFields(node.Content[i], t.Elem(), problems)
+			}
+		case reflect.Struct:
+			fields := make(map[string]reflect.Type)
+			for i := 0; i < t.NumField(); i++ {
+				field := t.Field(i)
+				name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+				if name == "" {
+					name = strings.ToLower(field.Name)
+				}
+				if field.IsExported() && name != "-" {
+					fields[name] = field.Type
+				}
+			}
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := node.Content[i]
+				fieldType, ok := fields[key.Value]
+				if !ok {
+					*problems = append(
+						*problems,
+						fmt.Sprintf(
+							"line %d: field %s not found in type %s",
+							key.Line,
+							key.Value,
+							t))
+					continue
+				}
+				unknownFields(node.Content[i+1], fieldType, problems)
+			}
+		}
+	}
+}
+
+// expandEnv replaces each ${VAR} in the values under node with the
+// value of environment variable VAR adding to problems each VAR that
+// isn't set. $${ escapes expansion and stands for a literal ${ so that
+// $${VAR} becomes ${VAR}.
+func expandEnv(node *yaml.Node, problems *[]string) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			expandEnv(child, problems)
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			expandEnv(node.Content[i], problems)
+		}
+	case yaml.ScalarNode:
+		if !kEnvVar.MatchString(node.Value) {
+			return
+		}
+		node.Value = kEnvVar.ReplaceAllStringFunc(
+			node.Value, func(ref string) string {
+				if ref == "$${" {
+					return "${"
+				}
+				name := kEnvVar.FindStringSubmatch(ref)[1]
+				value, ok := os.LookupEnv(name)
+				if !ok {
+					*problems = append(
+						*problems,
+						fmt.Sprintf(
+							"line %d: environment variable %s is not set",
+							node.Line,
+							name))
+				}
+				return value
+			})
+		if node.Style&(yaml.TaggedStyle|yaml.DoubleQuotedStyle|
+			yaml.SingleQuotedStyle) == 0 {
+			// Let the value decide its type, e.g a port number.
+			node.Tag = ""
+		}
+	}
+}
+
+// yamlError rewords yaml errors, which already have line numbers, for
+// people rather than programmers.
+func yamlError(err error) error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return err
+	}
+	problems := make([]string, 0, len(typeErr.Errors))
+	for _, problem := range typeErr.Errors {
+		problem = kNotFoundError.ReplaceAllString(problem, "is not recognized")
+		problem = kUnmarshalError.ReplaceAllString(
+			problem, "$1 is not a valid $2")
+		problems = append(problems, problem)
+	}
+	return errors.New(strings.Join(problems, "\n  "))
+}
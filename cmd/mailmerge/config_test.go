@@ -0,0 +1,170 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeConfigs writes each config file in files to dir, sets HOME to
+// dir, and returns dir.
+func writeConfigs(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+		assert.NoError(t, err)
+	}
+	return dir
+}
+
+func TestLoadConfigLayers(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{
+		".mailmerge.yaml": `include: [common.yaml]
+emailId: me@gmail.com
+skipOnHookFailure: true
+warnMessageSize: 5000
+`,
+		"common.yaml": `password: secret
+smtpHost: smtp.example.com
+`,
+		"layer.yaml": `skipOnHookFailure: false
+warnMessageSize: 0
+emailId: other@gmail.com
+`,
+	})
+	c, files, err := loadConfig(
+		filepath.Join(dir, "layer.yaml"), "", true)
+	assert.NoError(t, err)
+	assert.Equal(t, "other@gmail.com", c.EmailId)
+	assert.Equal(t, "secret", c.Password)
+	assert.Equal(t, "smtp.example.com", c.SmtpHost)
+	assert.False(t, c.SkipOnHookFailure)
+	assert.Equal(t, 0, c.WarnMessageSize)
+	assert.Equal(
+		t,
+		[]string{
+			filepath.Join(dir, "common.yaml"),
+			filepath.Join(dir, ".mailmerge.yaml"),
+			filepath.Join(dir, "layer.yaml"),
+		},
+		files)
+}
+
+func TestLoadConfigTenant(t *testing.T) {
+	writeConfigs(t, map[string]string{
+		".mailmerge.yaml": `emailId: me@gmail.com
+password: secret
+skipOnHookFailure: true
+tenants:
+  club:
+    emailId: club@gmail.com
+    skipOnHookFailure: false
+`,
+	})
+	c, _, err := loadConfig("", "club", true)
+	assert.NoError(t, err)
+	assert.Equal(t, "club@gmail.com", c.EmailId)
+	assert.Equal(t, "secret", c.Password)
+	assert.False(t, c.SkipOnHookFailure)
+	assert.Nil(t, c.Tenants)
+	c, _, err = loadConfig("", "", true)
+	assert.NoError(t, err)
+	assert.Equal(t, "me@gmail.com", c.EmailId)
+	assert.True(t, c.SkipOnHookFailure)
+}
+
+func TestLoadConfigEnv(t *testing.T) {
+	writeConfigs(t, map[string]string{
+		".mailmerge.yaml": "emailId: ${MM_TEST_EMAIL}\n" +
+			"password: a$${MM_TEST_EMAIL}b\n",
+	})
+	t.Setenv("MM_TEST_EMAIL", "me@gmail.com")
+	c, _, err := loadConfig("", "", true)
+	assert.NoError(t, err)
+	assert.Equal(t, "me@gmail.com", c.EmailId)
+	assert.Equal(t, "a${MM_TEST_EMAIL}b", c.Password)
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "include",
+			files: map[string]string{
+				".mailmerge.yaml": "include: [common.yaml]\n",
+				"common.yaml":     "smtpPort: 70000\n",
+			},
+			want: "{dir}/common.yaml:\n  smtpPort out of range: 70000",
+		},
+		{
+			name: "layer",
+			files: map[string]string{
+				".mailmerge.yaml": "emailId: me@gmail.com\n",
+				"layer.yaml":      "backend: pigeon\n",
+			},
+			want: "{dir}/layer.yaml:\n  " +
+				"backend must be smtp, gmailapi, or graph: pigeon",
+		},
+		{
+			name: "tenant",
+			files: map[string]string{
+				".mailmerge.yaml": "emailId: me@gmail.com\n",
+				"layer.yaml": "tenants:\n  club:\n" +
+					"    warmup: [10, 0]\n",
+			},
+			want: "{dir}/layer.yaml:\n  " +
+				"tenants: club: warmup: 0 must be positive",
+		},
+		{
+			name: "negative",
+			files: map[string]string{
+				".mailmerge.yaml": "maxMessageSize: -1\n" +
+					"warnMessageSize: 0\nsendWaitTime: -1s\n",
+			},
+			want: "{dir}/.mailmerge.yaml:\n" +
+				"  maxMessageSize must not be negative\n" +
+				"  sendWaitTime must not be negative",
+		},
+		{
+			name: "unknown",
+			files: map[string]string{
+				".mailmerge.yaml": "emailId: ${MM_TEST_EMAIL}\n" +
+					"smtpPort: ${MM_TEST_PORT}\n" +
+					"tenants:\n  club:\n    emailld: x\n",
+			},
+			want: "{dir}/.mailmerge.yaml:\n" +
+				"  line 5: field emailld is not recognized\n" +
+				"  line 2: `abc` is not a valid int",
+		},
+		{
+			name: "missing",
+			files: map[string]string{
+				".mailmerge.yaml": "emailId: me@gmail.com\n",
+				"layer.yaml":      "smtpHost: smtp.example.com\n",
+			},
+			want: "{dir}/.mailmerge.yaml, {dir}/layer.yaml:\n" +
+				"  password is required",
+		},
+	}
+	t.Setenv("MM_TEST_EMAIL", "me@gmail.com")
+	t.Setenv("MM_TEST_PORT", "abc")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writeConfigs(t, tc.files)
+			var layer string
+			if _, ok := tc.files["layer.yaml"]; ok {
+				layer = filepath.Join(dir, "layer.yaml")
+			}
+			_, _, err := loadConfig(layer, "", true)
+			assert.EqualError(
+				t, err, strings.ReplaceAll(tc.want, "{dir}", dir))
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/keep94/mailmerge/merge"
+)
+
+const kDoctorTimeout = 10 * time.Second
+
+// doctor checks everything mailmerge needs in one pass: the config
+// file, reaching the mail server, the credentials, and, if given, the
+// template and CSV file. doctor reports ok or FAIL for each along with
+// how to fix each failure and returns false if anything failed.
+func doctor() bool {
+	d := &diagnosis{}
+	if d.checkConfigFile() {
+		config, err := readConfig(fConfig, fTenant, true)
+		d.Report("config is valid", err, "Fix the fields listed above.")
+		if config != nil && d.checkServer(config) {
+			d.checkCredentials(config)
+		}
+	}
+	if fTemplate != "" {
+		d.checkTemplate()
+	}
+	if fCsv != "" {
+		d.checkCsv()
+	}
+	return !d.failed
+}
+
+// diagnosis reports the checks that doctor runs.
+type diagnosis struct {
+	failed bool
+}
+
+// Report reports the check what as passed if err is nil. Otherwise
+// Report reports it as failed along with err and fix.
+func (d *diagnosis) Report(what string, err error, fix string) {
+	if err != nil {
+		d.failed = true
+	}
+	out.Checked(what, err, fix)
+}
+
+// checkConfigFile returns false if the config file doesn't exist.
+func (d *diagnosis) checkConfigFile() bool {
+	path := configPath()
+	info, err := os.Stat(path)
+	if err != nil {
+		d.Report(
+			path+" exists",
+			err,
+			"Create it with emailId and password. "+
+				"newtemplate -extract mailmerge.yaml writes a sample.")
+		return false
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		d.Report(
+			path+" is private",
+			fmt.Errorf(
+				"others may read the password: mode %v", info.Mode().Perm()),
+			"Run chmod 600 "+path)
+		return true
+	}
+	d.Report(path+" exists and is private", nil, "")
+	return true
+}
+
+// checkServer makes sure that the server mailmerge sends through
+// resolves and accepts connections. checkServer returns false if not.
+func (d *diagnosis) checkServer(config *config) bool {
+	host, port, setting := serverAddress(config)
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	if _, err := net.LookupHost(host); err != nil {
+		d.Report(
+			host+" resolves",
+			err,
+			"Check "+setting+" in .mailmerge.yaml and your network connection.")
+		return false
+	}
+	conn, err := net.DialTimeout("tcp", addr, kDoctorTimeout)
+	if err == nil {
+		conn.Close()
+	}
+	d.Report(
+		addr+" is reachable",
+		err,
+		"Check "+setting+" in .mailmerge.yaml. "+
+			"Some networks block outgoing mail ports.")
+	return err == nil
+}
+
+// serverAddress returns where mailmerge connects to send along with the
+// settings that control it.
+func serverAddress(config *config) (host string, port int, setting string) {
+	switch {
+	case fDrafts:
+		host, port = config.ImapHost, config.ImapPort
+		if host == "" {
+			host, port = "imap.gmail.com", kDefaultImapPort
+		}
+		return host, port, "imapHost and imapPort"
+	case config.Backend == "gmailapi":
+		return "gmail.googleapis.com", 443, "backend"
+	case config.Backend == "graph":
+		return "graph.microsoft.com", 443, "backend"
+	}
+	host, port = config.SmtpHost, config.SmtpPort
+	if host == "" {
+		host, port = "smtp.gmail.com", kDefaultSmtpPort
+	}
+	return host, port, "smtpHost and smtpPort"
+}
+
+func (d *diagnosis) checkCredentials(config *config) {
+	var from string
+	if fFrom != "" {
+		var err error
+		from, err = config.identity(fFrom)
+		if err != nil {
+			d.Report("-from is allowed", err, "Add it to identities.")
+			return
+		}
+	}
+	sender := createEmailSender(config, false, fDrafts, newLogger())
+	defer sender.Shutdown()
+	err := sender.Check(from)
+	if err == nil {
+		d.Report("logged in and sender accepted", nil, "")
+		return
+	}
+	d.Report(
+		"logged in and sender accepted",
+		err,
+		checkHint(config, fDrafts, from, err))
+}
+
+func (d *diagnosis) checkTemplate() {
+	plugins, err := loadPlugins(fPlugin)
+	if err != nil {
+		d.Report(
+			"plugins load", err, "Rebuild the plugins with this mailmerge.")
+		return
+	}
+	_, err = readTemplate(fTemplate, plugins)
+	d.Report(
+		"template "+fTemplate+" parses",
+		err,
+		"Fix the template at the line shown above.")
+	if fHtmlTemplate != "" {
+		_, err = readHtmlTemplate(fHtmlTemplate, plugins)
+		d.Report(
+			"template "+fHtmlTemplate+" parses",
+			err,
+			"Fix the template at the line shown above.")
+	}
+	if fSubject != "" {
+		_, err = readSubject(fSubject, plugins)
+		d.Report(
+			"subject parses", err, "Fix the -subject template shown above.")
+	}
+}
+
+func (d *diagnosis) checkCsv() {
+	csvFile, err := merge.ReadCsv(fCsv)
+	if err != nil {
+		d.Report(
+			"CSV file "+fCsv+" reads",
+			err,
+			"Save the file as CSV, UTF-8, from your spreadsheet.")
+		return
+	}
+	for _, header := range csvFile.Headers {
+		if header == merge.Email {
+			d.Report(
+				fmt.Sprintf(
+					"CSV file %s reads: %d rows", fCsv, len(csvFile.Rows)),
+				nil,
+				"")
+			return
+		}
+	}
+	d.Report(
+		"CSV file "+fCsv+" has an email column",
+		fmt.Errorf("columns are %v", csvFile.Headers),
+		"Name the column with email addresses email.")
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/keep94/mailmerge/merge"
+)
+
+// runHook runs command through the shell with row as a JSON object on
+// stdin. env holds extra environment variables in key=value form. The
+// hook's output goes to mailmerge's stderr.
+func runHook(command string, row merge.CsvRow, env ...string) error {
+	rowJson, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(rowJson)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), env...)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %v", command, err)
+	}
+	return nil
+}
+
+// runPreSendHook runs the pre send hook, if any, for row just before
+// its email is sent or, with -drafts, saved as a draft. The hook doesn't
+// run for -dryrun.
+func runPreSendHook(config *config, row merge.CsvRow) error {
+	if config.PreSendHook == "" || fDryRun {
+		return nil
+	}
+	if err := runHook(config.PreSendHook, row); err != nil {
+		return fmt.Errorf("pre send hook failed: %v", err)
+	}
+	return nil
+}
+
+// runPostSendHook runs the post send hook, if any, for row. sendErr is the
+// result of sending row's email. Like the pre send hook, the post send
+// hook runs for -drafts but not for -dryrun.
+func runPostSendHook(config *config, row merge.CsvRow, sendErr error) {
+	if config.PostSendHook == "" || fDryRun {
+		return
+	}
+	status := "MAILMERGE_STATUS=" + sendStatus()
+	errMsg := "MAILMERGE_ERROR="
+	if sendErr != nil {
+		status = "MAILMERGE_STATUS=failed"
+		errMsg += sendErr.Error()
+	}
+	if err := runHook(config.PostSendHook, row, status, errMsg); err != nil {
+		warningf("post send hook failed: %v", err)
+	}
+}
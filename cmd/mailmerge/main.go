@@ -1,32 +1,78 @@
 package main
 
 import (
-	"bytes"
+	"bufio"
+	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
-	"path"
+	"slices"
+	"strconv"
 	"strings"
-	"text/template"
 	"time"
 
+	"github.com/keep94/mailmerge/mailer"
 	"github.com/keep94/mailmerge/merge"
 	"github.com/keep94/toolbox/build"
-	"github.com/keep94/toolbox/mailer"
-	"gopkg.in/yaml.v3"
 )
 
 var (
-	fTemplate string
-	fCsv      string
-	fSubject  string
-	fDryRun   bool
-	fIndex    int
-	fEmails   string
-	fNoEmails string
-	fVersion  bool
+	fTemplate     string
+	fLayout       string
+	fHtmlTemplate string
+	fCsv          string
+	fSubject      string
+	fDryRun       bool
+	fDrafts       bool
+	fQueue        string
+	fFlush        string
+	fShard        string
+	fDoctor       bool
+	fSimulate     string
+	fPreview      string
+	fResend       bool
+	fIndex        int
+	fEmails       string
+	fNoEmails     string
+	fTags         string
+	fNoTags       string
+	fVersion      bool
+	fSeedList     string
+	fScreen       string
+	fSalutation   string
+	fVerbose      bool
+	fVeryVerbose  bool
+	fFrom         string
+	fPriority     string
+	fReadReceipt  bool
+	fAttach       stringList
+	fPlugin       stringList
+	fTenant       string
+	fConfig       string
+	fShowConfig   bool
+	fPreset       string
+	fCampaigns    string
+	fCompletion   string
+	fJson         bool
+	fKeepGoing    bool
+	fMinLength    int
+	fExplain      bool
+	fLang         string
 )
 
+// stringList is a flag that may be repeated.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
 func main() {
 	flag.Parse()
 	if fVersion {
@@ -34,176 +80,942 @@ func main() {
 		fmt.Println(build.BuildId(version))
 		return
 	}
-	if fTemplate == "" || fCsv == "" || fSubject == "" {
-		fmt.Println("-template, -csv, and -subject flags required.")
-		flag.Usage()
-		os.Exit(2)
+	if fJson {
+		out = newJsonReporter()
+	}
+	if err := checkLang(); err != nil {
+		out.Fatal(err, 2)
+	}
+	if fCompletion != "" {
+		if err := writeCompletion(os.Stdout, fCompletion); err != nil {
+			out.Fatal(err, 2)
+		}
+		return
+	}
+	var campaign *preset
+	if fPreset != "" {
+		var err error
+		campaign, err = applyPreset(fCampaigns, fPreset)
+		if err != nil {
+			out.Fatal(err, 1)
+		}
+	}
+	if fShowConfig {
+		config, files, err := loadConfig(fConfig, fTenant, false)
+		if err != nil {
+			out.Fatal(err, 1)
+		}
+		if err := config.writeEffective(os.Stdout, files); err != nil {
+			out.Fatal(err, 1)
+		}
+		return
+	}
+	if fDoctor {
+		if !doctor() {
+			os.Exit(1)
+		}
+		return
+	}
+	if fFlush != "" {
+		flush(fFlush)
+		return
+	}
+	if fQueue != "" && (fDryRun || fDrafts) {
+		out.Fatal(
+			errors.New(tr("-queue can't be used with -dryrun or -drafts")), 2)
+	}
+	if fCsv == "" || !fExplain && (fTemplate == "" || fSubject == "") {
+		out.Usage(tr("-template, -csv, and -subject flags required."))
 	}
-	config, err := readConfig()
+	if fScreen != "" && fScreen != "report" && fScreen != "exclude" {
+		out.Fatal(
+			fmt.Errorf(tr("-screen must be report or exclude: %s"), fScreen),
+			2)
+	}
+	if fShard != "" {
+		if _, err := shardFilter(fShard); err != nil {
+			out.Fatal(err, 2)
+		}
+	}
+	if fPreview != "" {
+		out.Fatal(servePreview(fPreview, campaign), 1)
+	}
+	plugins, err := loadPlugins(fPlugin)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		out.Fatal(err, 1)
 	}
-	csvFile, err := merge.ReadCsv(fCsv)
+	csvFile, filters, err := readRecipients(campaign, plugins)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		out.Fatal(err, 1)
+	}
+	if fExplain {
+		if fScreen == "exclude" {
+			filters = append(filters, merge.ScreenFilter())
+		}
+		if err := explain(csvFile, filters); err != nil {
+			out.Fatal(err, 1)
+		}
+		return
 	}
-	csvFile = csvFile.SelectGoing()
-	template, err := readTemplate(fTemplate)
+	config, err := readConfig(fConfig, fTenant, fQueue == "")
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		out.Fatal(err, 1)
 	}
-	if fEmails != "" {
-		var err error
-		csvFile, err = doEmailFilter(csvFile, fEmails)
+	var from string
+	if fFrom != "" {
+		from, err = config.identity(fFrom)
 		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+			out.Fatal(err, 1)
 		}
-	} else if fNoEmails != "" {
-		var err error
-		csvFile, err = doNoEmailFilter(csvFile, fNoEmails)
+	}
+	priority, err := mailer.ParsePriority(fPriority)
+	if err != nil {
+		out.Fatal(err, 2)
+	}
+	csvFile, seedStart, err := selectRecipients(csvFile, filters, out.Screened)
+	if err != nil {
+		out.Fatal(err, 1)
+	}
+	template, err := readTemplate(fTemplate, plugins)
+	if err != nil {
+		out.Fatal(err, 1)
+	}
+	var htmlTemplate *merge.Template
+	if fHtmlTemplate != "" {
+		htmlTemplate, err = readHtmlTemplate(fHtmlTemplate, plugins)
 		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+			out.Fatal(err, 1)
 		}
 	}
-	sender := createEmailSender(config, fDryRun)
-	defer sender.Shutdown()
+	subject, err := readSubject(fSubject, plugins)
+	if err != nil {
+		out.Fatal(err, 1)
+	}
+	attachments, err := parseAttachments(fAttach)
+	if err != nil {
+		out.Fatal(err, 1)
+	}
+	newEmail := func(row merge.CsvRow) (*mailer.Email, error) {
+		email, err := createEmail(template, htmlTemplate, row, subject)
+		if err != nil {
+			return nil, err
+		}
+		email.Attachments, err = renderAttachments(attachments, row)
+		if err != nil {
+			return nil, &emailError{Kind: kAttachProblem, Err: err}
+		}
+		email.From = from
+		email.Priority = priority
+		email.ReadReceipt = fReadReceipt
+		return email, nil
+	}
+	logger := newLogger()
+	var sender emailSender
+	if fQueue == "" {
+		sender, err = newEmailSender(config, logger)
+		if err != nil {
+			out.Fatal(err, 2)
+		}
+		defer sender.Shutdown()
+		if err := sender.Check(from); err != nil {
+			out.Fatal(checkError(config, fDrafts, from, err), 1)
+		}
+	}
+	emails, err := preflight(
+		csvFile,
+		newEmail,
+		config,
+		&preflightOptions{
+			StartIndex: fIndex,
+			KeepGoing:  fKeepGoing,
+			MinLength:  fMinLength,
+			Logger:     logger,
+		})
+	if err != nil {
+		out.Fatal(err, 1)
+	}
+	var list []*outgoing
 	for index, row := range csvFile.Rows {
-		if index < fIndex {
+		if index < fIndex || emails[index] == nil {
 			continue
 		}
-		fmt.Printf("%d %s %s\n", index, row.Email(), row.Name())
-		email, err := createEmail(template, row, fSubject)
+		list = append(list, &outgoing{
+			Index: index,
+			Row:   row,
+			Seed:  index >= seedStart,
+			Email: emails[index],
+		})
+	}
+	if campaign != nil {
+		if err := checkGuards(campaign, csvFile.Headers, list); err != nil {
+			out.Fatal(err, 1)
+		}
+	}
+	if fQueue != "" {
+		if err := writeQueue(fQueue, list); err != nil {
+			out.Fatal(err, 1)
+		}
+		out.Queued(len(list), fQueue)
+		return
+	}
+	var history *runHistory
+	if !fDryRun && !fDrafts {
+		history, err = checkResend(config, list)
 		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+			out.Fatal(err, 1)
 		}
-		err = <-sender.SendFuture(*email)
+	}
+	sendAll(config, sender, list, history)
+}
+
+// checkGuards checks the people in list who aren't seeds against the
+// guards of campaign. headers are the columns of the CSV file.
+func checkGuards(
+	campaign *preset, headers []string, list []*outgoing) error {
+	guards, err := campaign.guardList()
+	if err != nil {
+		return fmt.Errorf("%s: %v", fCampaigns, err)
+	}
+	recipients := &merge.CsvFile{Headers: headers}
+	for _, o := range list {
+		if !o.Seed {
+			recipients.Rows = append(recipients.Rows, o.Row)
+		}
+	}
+	if err := guards.Check(recipients); err != nil {
+		return fmt.Errorf(
+			tr("Stopping because the recipients failed a guard:\n%v"),
+			"  "+strings.ReplaceAll(err.Error(), "\n", "\n  "))
+	}
+	return nil
+}
+
+// checkResend returns the history that remembers this run. checkResend
+// asks whether to send again if the account sent the same run recently
+// and stdin is a terminal. Otherwise checkResend returns an error unless
+// -resend is set.
+func checkResend(config *config, list []*outgoing) (*runHistory, error) {
+	templatePaths := []string{fTemplate}
+	if fLayout != "" {
+		templatePaths = append(templatePaths, fLayout)
+	}
+	if fHtmlTemplate != "" {
+		templatePaths = append(templatePaths, fHtmlTemplate)
+	}
+	fingerprint, err := runFingerprint(templatePaths, fSubject, list)
+	if err != nil {
+		return nil, err
+	}
+	result := newRunHistory(
+		config.RunHistory, config.EmailId, fingerprint, time.Now())
+	if fResend {
+		return result, nil
+	}
+	sent, err := result.LastSent()
+	if err != nil {
+		return nil, err
+	}
+	if sent.IsZero() {
+		return result, nil
+	}
+	when := sent.Format("2006-01-02 15:04")
+	if !fJson && isTerminal(os.Stdin) {
+		if confirm(fmt.Sprintf(
+			tr("Already sent this template and subject to these %d "+
+				"recipients on %s. Send again? [y/N] "),
+			len(list),
+			when)) {
+			return result, nil
+		}
+		return nil, errors.New(tr("Not sending"))
+	}
+	return nil, fmt.Errorf(
+		tr("Already sent this template and subject to these %d "+
+			"recipients on %s. Use -resend to send again"),
+		len(list),
+		when)
+}
+
+// isTerminal returns true if file is a terminal rather than a pipe or
+// a file.
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+
+	// The null device is a character device too.
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+// confirm shows prompt and returns true if the user answers yes.
+func confirm(prompt string) bool {
+	fmt.Print(prompt)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// flush sends the emails that -queue saved in dir removing each one
+// from the queue once sent.
+func flush(dir string) {
+	config, err := readConfig(fConfig, fTenant, true)
+	if err != nil {
+		out.Fatal(err, 1)
+	}
+	list, err := readQueue(dir)
+	if err != nil {
+		out.Fatal(err, 1)
+	}
+	sender, err := newEmailSender(config, newLogger())
+	if err != nil {
+		out.Fatal(err, 2)
+	}
+	defer sender.Shutdown()
+	checked := make(map[string]bool)
+	for _, o := range list {
+		if checked[o.Email.From] {
+			continue
+		}
+		if err := sender.Check(o.Email.From); err != nil {
+			out.Fatal(checkError(config, fDrafts, o.Email.From, err), 1)
+		}
+		checked[o.Email.From] = true
+	}
+	sendAll(config, sender, list, nil)
+}
+
+// sendAll sends each email in list in order running the pre send hook
+// just before each one. sendAll exits on the first failure, including a
+// failed pre send hook unless config says to skip the row instead,
+// unless -keepgoing is set. With -flush, sendAll removes each
+// email from the queue once sent. If history is not nil, sendAll records
+// the run in it once the first email is sent.
+func sendAll(
+	config *config,
+	sender emailSender,
+	list []*outgoing,
+	history *runHistory) {
+	var warm *warmup
+	remaining, limit := -1, -1
+	if len(config.Warmup) > 0 && !fDryRun && !fDrafts {
+		var err error
+		warm = newWarmup(
+			config.WarmupState, config.EmailId, config.Warmup, time.Now())
+		remaining, limit, err = warm.Remaining()
 		if err != nil {
-			fmt.Println(err)
+			out.Fatal(err, 1)
+		}
+	}
+	for _, o := range list {
+		if remaining == 0 {
+			if fFlush != "" {
+				warningf(
+					"Warm-up limit of %d emails a day reached. "+
+						"Run -flush again tomorrow",
+					limit)
+			} else {
+				warningf(
+					"Warm-up limit of %d emails a day reached. "+
+						"Run again tomorrow with -index %d",
+					limit,
+					o.Index)
+			}
+			break
+		}
+		err := runPreSendHook(config, o.Row)
+		if err != nil && config.SkipOnHookFailure {
+			out.Skipped(o.Index, 0, o.Row, err.Error())
+			continue
+		}
+		out.Sending(o.Index, o.Row, o.Seed)
+		if err == nil {
+			err = <-sender.SendFuture(*o.Email)
+			runPostSendHook(config, o.Row, err)
+		}
+		out.Sent(o.Index, o.Row, o.Seed, sendStatus(), err)
+		if err == nil && fFlush != "" && !fDryRun {
+			if err := dequeue(o); err != nil {
+				warningf("queue: %v", err)
+			}
+		}
+		if err == nil && history != nil {
+			if err := history.Record(); err != nil {
+				warningf("run history: %v", err)
+			}
+			history = nil
+		}
+		if err == nil && warm != nil {
+			if err := warm.Record(); err != nil {
+				warningf("warm-up: %v", err)
+			}
+			if remaining > 0 {
+				remaining--
+			}
+		}
+		if err != nil && !fKeepGoing {
+			out.Summary()
 			os.Exit(1)
 		}
 	}
+	out.Summary()
 }
 
-func createEmailSender(config *config, dryRun bool) emailSender {
+// sendStatus returns what sending an email means for the flags.
+func sendStatus() string {
+	switch {
+	case fDryRun:
+		return kDryRun
+	case fDrafts:
+		return kDrafted
+	}
+	return kSent
+}
+
+// newLogger returns the logger for the verbosity flags. -v logs session
+// events and each email sent; -vv also logs the SMTP conversation.
+func newLogger() *slog.Logger {
+	level := slog.LevelWarn
+	if fVeryVerbose {
+		level = mailer.LevelTrace
+	} else if fVerbose {
+		level = slog.LevelDebug
+	}
+	return slog.New(slog.NewTextHandler(
+		os.Stderr, &slog.HandlerOptions{Level: level}))
+}
+
+// newEmailSender returns what sends emails for the flags: the mailer,
+// a Drafter for -drafts, or stand-ins for -dryrun and -simulate.
+func newEmailSender(
+	config *config, logger *slog.Logger) (emailSender, error) {
+	if fSimulate != "" {
+		if !fDryRun {
+			return nil, errors.New(tr("-simulate needs -dryrun"))
+		}
+		return newSimulatedMailer(fSimulate, config)
+	}
+	return createEmailSender(config, fDryRun, fDrafts, logger), nil
+}
+
+func createEmailSender(
+	config *config, dryRun, drafts bool, logger *slog.Logger) emailSender {
 	if dryRun {
 		return dryRunMailer{}
 	}
-	return mailer.NewWithOptions(
-		config.EmailId,
-		config.Password,
-		mailer.SendWaitTime(100*time.Millisecond),
-	)
+	if drafts {
+		options := []mailer.Option{mailer.Logger(logger)}
+		if config.ImapHost != "" {
+			options = append(
+				options, mailer.ImapServer(config.ImapHost, config.ImapPort))
+		}
+		if config.DraftsMailbox != "" {
+			options = append(options, mailer.Mailbox(config.DraftsMailbox))
+		}
+		return mailer.NewDrafter(config.EmailId, config.Password, options...)
+	}
+	options := []mailer.Option{
+		mailer.SendWaitTime(config.SendWaitTime),
+		mailer.Logger(logger),
+	}
+	switch config.Backend {
+	case "gmailapi":
+		return mailer.NewGmailApi(
+			config.EmailId, config.OAuth.credentials(), options...)
+	case "graph":
+		return mailer.NewGraphApi(
+			config.EmailId,
+			config.OAuth.TenantId,
+			config.OAuth.credentials(),
+			options...)
+	}
+	if config.SmtpHost != "" {
+		options = append(
+			options, mailer.Server(config.SmtpHost, config.SmtpPort))
+	}
+	if config.CopyToSent != "" {
+		options = append(options, mailer.CopyToMailbox(config.CopyToSent))
+		if config.ImapHost != "" {
+			options = append(
+				options, mailer.ImapServer(config.ImapHost, config.ImapPort))
+		}
+	}
+	return mailer.NewWithOptions(config.EmailId, config.Password, options...)
 }
 
 type dryRunMailer struct {
 }
 
 func (d dryRunMailer) SendFuture(email mailer.Email) <-chan error {
-	fmt.Println()
-	fmt.Println("To:", email.To)
-	fmt.Println("Subject:", email.Subject)
-	fmt.Println("Body:")
-	fmt.Println(email.Body)
+	out.DryRun(&email)
 	result := make(chan error, 1)
 	result <- nil
 	close(result)
 	return result
 }
 
+func (d dryRunMailer) Check(from string) error {
+	return nil
+}
+
 func (d dryRunMailer) Shutdown() {
 }
 
+// createEmail renders the email for row. htmlTemplate, if not nil,
+// renders the HTML version of the body. Errors are *emailError saying
+// whether the subject or the body failed.
 func createEmail(
-	template *template.Template,
+	template, htmlTemplate *merge.Template,
 	row merge.CsvRow,
-	subject string) (*mailer.Email, error) {
-	var builder strings.Builder
-	if err := template.Execute(&builder, row); err != nil {
-		return nil, err
+	subject *merge.Subject) (*mailer.Email, error) {
+	subjectLine, err := subject.Execute(row)
+	if err != nil {
+		return nil, &emailError{Kind: kSubjectProblem, Err: withSnippet(err)}
+	}
+	body, err := template.Execute(row)
+	if err != nil {
+		return nil, &emailError{Kind: kBodyProblem, Err: withSnippet(err)}
 	}
 	result := &mailer.Email{
-		Subject: subject,
-		To:      []string{row.Email()},
-		Body:    builder.String(),
+		Subject: subjectLine,
+		To:      []string{mailer.FormatAddress(row.Name(), row.Email())},
+		Body:    body,
+		Headers: row.CustomHeaders(),
+	}
+	if htmlTemplate != nil {
+		result.HtmlBody, err = htmlTemplate.Execute(row)
+		if err != nil {
+			return nil, &emailError{Kind: kBodyProblem, Err: withSnippet(err)}
+		}
+	}
+	return result, nil
+}
+
+// parseAttachments compiles each -attach flag value as a template so
+// that each row can have its own attachment.
+func parseAttachments(paths []string) ([]*merge.Template, error) {
+	result := make([]*merge.Template, 0, len(paths))
+	for _, path := range paths {
+		tmpl, err := merge.ParseTemplate("-attach", path)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, tmpl)
 	}
 	return result, nil
 }
 
+// renderAttachments returns the paths of the files to attach for row:
+// those from -attach followed by those in the attachments column.
+func renderAttachments(attachments []*merge.Template, row merge.CsvRow) (
+	[]string, error) {
+	result := make([]string, 0, len(attachments))
+	for _, attachment := range attachments {
+		path, err := attachment.Execute(row)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, path)
+	}
+	return append(result, row.Attachments()...), nil
+}
+
 type emailSender interface {
 	SendFuture(email mailer.Email) <-chan error
+	Check(from string) error
 	Shutdown()
 }
 
-func readTemplate(templatePath string) (*template.Template, error) {
-	return template.ParseFiles(templatePath)
+func readTemplate(templatePath string, plugins *plugins) (
+	*merge.Template, error) {
+	options := templateOptions(plugins)
+	if fLayout == "" {
+		result, err := merge.ParseTemplateFile(templatePath, options...)
+		return result, withSnippet(err)
+	}
+	set, err := merge.NewTemplateSet(fLayout, options...)
+	if err != nil {
+		return nil, withSnippet(err)
+	}
+	result, err := set.ParseFile(templatePath)
+	return result, withSnippet(err)
 }
 
-func doEmailFilter(csvFile *merge.CsvFile, emails string) (
-	*merge.CsvFile, error) {
-	selectedEmails := merge.NewEmailSet(emails)
-	if err := checkEmails(csvFile, selectedEmails); err != nil {
-		return nil, err
+// readHtmlTemplate reads the HTML template for -html-template. Layouts
+// are for plain text so readHtmlTemplate ignores -layout.
+func readHtmlTemplate(templatePath string, plugins *plugins) (
+	*merge.Template, error) {
+	result, err := merge.ParseTemplateFile(
+		templatePath, append(templateOptions(plugins), merge.EscapeHTML())...)
+	return result, withSnippet(err)
+}
+
+// readSubject compiles the subject line so that it can use columns
+// and the same functions as the templates.
+func readSubject(subject string, plugins *plugins) (*merge.Subject, error) {
+	result, err := merge.ParseSubject(subject, templateOptions(plugins)...)
+	return result, withSnippet(err)
+}
+
+// withSnippet adds the line of the template where err happened with a
+// caret under the spot, if known, to err.
+func withSnippet(err error) error {
+	var templateErr *merge.TemplateError
+	if !errors.As(err, &templateErr) || templateErr.Snippet() == "" {
+		return err
 	}
-	return csvFile.SelectEmails(selectedEmails), nil
+	snippet := strings.ReplaceAll(templateErr.Snippet(), "\n", "\n  ")
+	return fmt.Errorf("%w\n  %s", err, snippet)
 }
 
-func doNoEmailFilter(csvFile *merge.CsvFile, noEmails string) (
-	*merge.CsvFile, error) {
-	selectedNoEmails := merge.NewEmailSet(noEmails)
-	if err := checkEmails(csvFile, selectedNoEmails); err != nil {
+func templateOptions(plugins *plugins) []merge.TemplateOption {
+	result := []merge.TemplateOption{
+		merge.WithFuncs(plugins.Funcs), merge.Language(fLang)}
+	if fSalutation != "" {
+		result = append(result, merge.GenericSalutation(fSalutation))
+	}
+	return result
+}
+
+// emailFilter returns the filter for the -emails or -noemails flag. The
+// emails in the flag must be among the rows of csvFile that filters
+// selects.
+func emailFilter(csvFile *merge.CsvFile, filters merge.FilterChain) (
+	merge.Filter, error) {
+	selected, err := filters.Select(csvFile)
+	if err != nil {
 		return nil, err
 	}
-	return csvFile.SelectNoEmails(selectedNoEmails), nil
+	if fEmails != "" {
+		emails := merge.NewEmailSet(fEmails)
+		if err := checkEmails(selected, emails); err != nil {
+			return nil, err
+		}
+		return merge.EmailsFilter(emails), nil
+	}
+	noEmails := merge.NewEmailSet(fNoEmails)
+	if err := checkEmails(selected, noEmails); err != nil {
+		return nil, err
+	}
+	return merge.NoEmailsFilter(noEmails), nil
 }
 
-func checkEmails(csvFile *merge.CsvFile, emails merge.EmailSet) error {
-	unrecognizedEmails := emails.Difference(csvFile.AsEmailSet())
-	if len(unrecognizedEmails) > 0 {
-		return fmt.Errorf("Unrecognized emails: %s", unrecognizedEmails)
+// shardFilter returns the filter for -shard e.g 2/3.
+func shardFilter(value string) (merge.Filter, error) {
+	shard, count, ok := strings.Cut(value, "/")
+	shardNum, err1 := strconv.Atoi(shard)
+	countNum, err2 := strconv.Atoi(count)
+	if !ok || err1 != nil || err2 != nil {
+		return nil, fmt.Errorf(tr("-shard must look like 2/3: %s"), value)
+	}
+	result, err := merge.ShardFilter(shardNum, countNum)
+	if err != nil {
+		return nil, fmt.Errorf("-shard: %v", err)
+	}
+	return result, nil
+}
+
+// explain reports which of filters, if any, removes each row of
+// csvFile.
+func explain(csvFile *merge.CsvFile, filters merge.FilterChain) error {
+	explanations, err := filters.Explain(csvFile)
+	if err != nil {
+		return err
+	}
+	for index, explanation := range explanations {
+		removedBy := ""
+		if explanation.RemovedBy != nil {
+			removedBy = explanation.RemovedBy.String()
+		}
+		out.Explained(index, explanation.Line, explanation.Row, removedBy)
 	}
 	return nil
 }
 
-type config struct {
-	EmailId  string `yaml:"emailId"`
-	Password string `yaml:"password"`
+// readRecipients reads the CSV file with the vars of campaign, if any,
+// added and the rows in priority order. readRecipients also returns the
+// filters that choose who gets the email from the flags and campaign.
+func readRecipients(campaign *preset, plugins *plugins) (
+	*merge.CsvFile, merge.FilterChain, error) {
+	csvFile, err := merge.ReadCsv(fCsv)
+	if err != nil {
+		return nil, nil, err
+	}
+	var filters merge.FilterChain
+	if campaign != nil {
+		csvFile = campaign.withVars(csvFile)
+		filters, err = campaign.filterChain()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", fCampaigns, err)
+		}
+	}
+	csvFile, err = csvFile.ByPriority()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(filters) == 0 {
+		filters = merge.FilterChain{merge.GoingFilter()}
+	}
+	if filter := plugins.Filter(); filter != nil {
+		filters = append(filters, filter)
+	}
+	if fEmails != "" || fNoEmails != "" {
+		filter, err := emailFilter(csvFile, filters)
+		if err != nil {
+			return nil, nil, err
+		}
+		filters = append(filters, filter)
+	}
+	if fTags != "" || fNoTags != "" {
+		if !slices.Contains(csvFile.Headers, merge.Tags) {
+			return nil, nil, fmt.Errorf(tr("%s has no tags column"), fCsv)
+		}
+		if fTags != "" {
+			filters = append(
+				filters, merge.TagsFilter(merge.NewTagSet(fTags)))
+		}
+		if fNoTags != "" {
+			filters = append(
+				filters, merge.NoTagsFilter(merge.NewTagSet(fNoTags)))
+		}
+	}
+	if fShard != "" {
+		filter, err := shardFilter(fShard)
+		if err != nil {
+			return nil, nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return csvFile, filters, nil
 }
 
-func readConfig() (*config, error) {
-	configPath := path.Join(os.Getenv("HOME"), ".mailmerge.yaml")
-	f, err := os.Open(configPath)
+// selectRecipients returns the rows of csvFile that get the email: those
+// filters keep, screened if -screen says so, with the name parts added,
+// followed by the seeds. selectRecipients also returns the index of the
+// first seed. If screened isn't nil, selectRecipients calls it for each
+// email that merge.ScreenEmail flags.
+func selectRecipients(
+	csvFile *merge.CsvFile,
+	filters merge.FilterChain,
+	screened func(email, reason string)) (*merge.CsvFile, int, error) {
+	csvFile, err := filters.Select(csvFile)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	defer f.Close()
-	var content bytes.Buffer
-	if _, err := content.ReadFrom(f); err != nil {
-		return nil, err
+	if fScreen != "" && screened != nil {
+		for _, row := range csvFile.Rows {
+			if reason := merge.ScreenEmail(row.Email()); reason != "" {
+				screened(row.Email(), reason)
+			}
+		}
+	}
+	if fScreen == "exclude" {
+		csvFile, err = merge.ScreenFilter().Select(csvFile)
+		if err != nil {
+			return nil, 0, err
+		}
 	}
-	var result config
-	if err := yaml.Unmarshal(content.Bytes(), &result); err != nil {
+	csvFile = csvFile.WithNameParts(merge.ParseName)
+	seedStart := len(csvFile.Rows)
+	if fSeedList != "" {
+		csvFile, err = addSeeds(csvFile, fSeedList)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return csvFile, seedStart, nil
+}
+
+// addSeeds returns csvFile with a row appended for each address in the
+// seed list file at seedListPath. Seed rows are copies of the first row
+// so that seed inboxes get a real email.
+func addSeeds(csvFile *merge.CsvFile, seedListPath string) (
+	*merge.CsvFile, error) {
+	seeds, err := readSeedList(seedListPath)
+	if err != nil {
 		return nil, err
 	}
+	if len(seeds) == 0 {
+		return csvFile, nil
+	}
+	if len(csvFile.Rows) == 0 {
+		return nil, errors.New(tr("No recipients to base seed emails on"))
+	}
+	result := *csvFile
+	result.Rows = slices.Clone(csvFile.Rows)
+	for _, seed := range seeds {
+		result.Rows = append(result.Rows, csvFile.Rows[0].WithEmail(seed))
+	}
 	return &result, nil
 }
 
+// readSeedList reads one email per line ignoring blank lines and lines
+// starting with #.
+func readSeedList(seedListPath string) ([]string, error) {
+	content, err := os.ReadFile(seedListPath)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		result = append(result, line)
+	}
+	return result, nil
+}
+
+func checkEmails(csvFile *merge.CsvFile, emails merge.EmailSet) error {
+	unrecognizedEmails := emails.Difference(csvFile.AsEmailSet())
+	if len(unrecognizedEmails) > 0 {
+		return fmt.Errorf(tr("Unrecognized emails: %s"), unrecognizedEmails)
+	}
+	return nil
+}
+
 func init() {
 	flag.StringVar(&fTemplate, "template", "", "Path to template file")
+	flag.StringVar(
+		&fHtmlTemplate,
+		"html-template",
+		"",
+		"Path to template file for an HTML version of each email")
+	flag.StringVar(
+		&fLayout,
+		"layout",
+		"",
+		"Path to base layout that the template fills in the blocks of")
 	flag.StringVar(&fCsv, "csv", "", "Path to CSV file")
 	flag.StringVar(&fSubject, "subject", "", "Subject")
 	flag.BoolVar(&fDryRun, "dryrun", false, "Dry Run?")
+	flag.StringVar(
+		&fPreview,
+		"preview",
+		"",
+		"Preview emails in a browser at this address e.g localhost:8080")
+	flag.StringVar(
+		&fSimulate,
+		"simulate",
+		"",
+		"With -dryrun, rehearse sending e.g rate,delay=200ms,errors=2%")
+	flag.BoolVar(
+		&fDoctor, "doctor", false, "Check config, server, template, and CSV")
+	flag.BoolVar(
+		&fDrafts, "drafts", false, "Save emails as drafts instead of sending")
+	flag.StringVar(
+		&fQueue,
+		"queue",
+		"",
+		"Save emails in this directory to send later with -flush")
+	flag.StringVar(
+		&fFlush,
+		"flush",
+		"",
+		"Send the emails that -queue saved in this directory")
+	flag.BoolVar(
+		&fResend, "resend", false, "Send even if the same run was just sent")
 	flag.IntVar(&fIndex, "index", 0, "Starting index")
+	flag.StringVar(
+		&fShard,
+		"shard",
+		"",
+		"Send only to this part of the list e.g 2/3 for the second of three")
 	flag.StringVar(&fEmails, "emails", "", "Comma separated emails to include")
 	flag.StringVar(
 		&fNoEmails,
 		"noemails",
 		"",
 		"Comma separated emails to exclude. Ignored if emails flag is present")
+	flag.StringVar(
+		&fTags,
+		"tags",
+		"",
+		"Comma separated tags. Include rows with any of them")
+	flag.StringVar(
+		&fNoTags,
+		"notags",
+		"",
+		"Comma separated tags. Exclude rows with any of them")
 	flag.BoolVar(&fVersion, "version", false, "Show version")
+	flag.StringVar(
+		&fSeedList,
+		"seedlist",
+		"",
+		"Path to file of test emails, one per line, to also send to")
+	flag.StringVar(
+		&fScreen,
+		"screen",
+		"",
+		"report or exclude role accounts and disposable emails")
+	flag.StringVar(
+		&fSalutation,
+		"salutation",
+		"",
+		"Salutation for rows without a name e.g 'Dear friend'")
+	flag.StringVar(
+		&fFrom, "from", "", "Send from this identity in config")
+	flag.StringVar(&fPriority, "priority", "normal", "high, normal, or low")
+	flag.BoolVar(&fReadReceipt, "readreceipt", false, "Request read receipts")
+	flag.Var(
+		&fAttach,
+		"attach",
+		"Path of file to attach. May be a template e.g certs/{{.email}}.pdf. "+
+			"May be repeated")
+	flag.Var(
+		&fPlugin,
+		"plugin",
+		"Path of Go plugin adding template functions or filters. "+
+			"May be repeated")
+	flag.StringVar(
+		&fTenant, "tenant", "", "Use the settings of this tenant in config")
+	flag.StringVar(
+		&fConfig,
+		"config",
+		"",
+		"Config file whose settings replace those in .mailmerge.yaml")
+	flag.BoolVar(
+		&fShowConfig,
+		"showconfig",
+		false,
+		"Show the config in effect with secrets hidden")
+	flag.StringVar(
+		&fPreset,
+		"preset",
+		"",
+		"Take flags not given from this preset in the campaigns file")
+	flag.StringVar(
+		&fCampaigns, "campaigns", "campaigns.yaml", "Path to campaigns file")
+	flag.StringVar(
+		&fCompletion,
+		"completion",
+		"",
+		"Print the bash, zsh, or fish completion script")
+	flag.BoolVar(
+		&fJson, "json", false, "Report to stdout as one JSON object per line")
+	flag.BoolVar(
+		&fKeepGoing,
+		"keepgoing",
+		false,
+		"Skip emails that can't be built or sent instead of stopping")
+	flag.IntVar(
+		&fMinLength,
+		"minlength",
+		0,
+		"Treat bodies shorter than this many characters as a problem")
+	flag.BoolVar(
+		&fExplain,
+		"explain",
+		false,
+		"Instead of sending, show who gets the email and why others don't")
+	flag.StringVar(
+		&fLang, "lang", "en", "Language of messages: en, es, fr, or de")
+	flag.BoolVar(&fVerbose, "v", false, "Log SMTP session events to stderr")
+	flag.BoolVar(
+		&fVeryVerbose, "vv", false, "Also log the SMTP conversation to stderr")
 }
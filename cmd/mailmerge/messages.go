@@ -0,0 +1,350 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// translation is an English message and its translation.
+type translation struct {
+	English    string
+	Translated string
+}
+
+// kMessages translates what mailmerge says to people. The keys are
+// languages for -lang. Messages missing from a language stay in English.
+// JSON output is for programs and always stays in English.
+var kMessages = map[string][]translation{
+	"es": {
+		{
+			"-template, -csv, and -subject flags required.",
+			"Se requieren los parámetros -template, -csv y -subject.",
+		},
+		{
+			"-screen must be report or exclude: %s",
+			"-screen debe ser report o exclude: %s",
+		},
+		{"Warning: ", "Advertencia: "},
+		{"Screened: %s %s", "Filtrado: %s %s"},
+		{"Skipping", "Omitiendo"},
+		{"line", "línea"},
+		{"(seed)", "(prueba)"},
+		{"gets email", "recibe el correo"},
+		{"removed by %s", "eliminado por %s"},
+		{"From:", "De:"},
+		{"To:", "Para:"},
+		{"Subject:", "Asunto:"},
+		{"Attachment:", "Adjunto:"},
+		{"Body:", "Cuerpo:"},
+		{"HTML body:", "Cuerpo HTML:"},
+		{
+			"Pre-flight found %d problem(s):",
+			"La verificación previa encontró %d problema(s):",
+		},
+		{"pre send hook failed", "falló el hook previo al envío"},
+		{"Body template errors", "Errores en la plantilla del cuerpo"},
+		{"Attachment path errors", "Errores en las rutas de adjuntos"},
+		{"Empty or short bodies", "Cuerpos vacíos o cortos"},
+		{"Missing attachments", "Adjuntos que faltan"},
+		{"Address and header errors", "Errores de direcciones y encabezados"},
+		{"Other errors", "Otros errores"},
+		{
+			"Emails over the %d byte limit",
+			"Correos que superan el límite de %d bytes",
+		},
+		{"%d %s: email is %d bytes", "%d %s: el correo tiene %d bytes"},
+		{"post send hook failed: %v", "falló el hook posterior al envío: %v"},
+		{"warm-up: %v", "calentamiento: %v"},
+		{"run history: %v", "historial de envíos: %v"},
+		{
+			"Warm-up limit of %d emails a day reached. Run again tomorrow " +
+				"with -index %d",
+			"Se alcanzó el límite de calentamiento de %d correos al día. " +
+				"Vuelva a ejecutar mañana con -index %d",
+		},
+		{
+			"No recipients to base seed emails on",
+			"No hay destinatarios en los que basar los correos de prueba",
+		},
+		{"Unrecognized emails: %s", "Correos no reconocidos: %s"},
+		{
+			"Warm-up limit of %d emails a day reached. Run -flush again " +
+				"tomorrow",
+			"Se alcanzó el límite de calentamiento de %d correos al día. " +
+				"Vuelva a ejecutar -flush mañana",
+		},
+		{
+			"Queued %d email(s) in %s. Send them with -flush %s",
+			"%d correo(s) en cola en %s. Envíelos con -flush %s",
+		},
+		{
+			"-queue can't be used with -dryrun or -drafts",
+			"-queue no se puede usar con -dryrun ni -drafts",
+		},
+		{
+			"queue: %v",
+			"cola: %v",
+		},
+		{"-shard must look like 2/3: %s", "-shard debe tener la forma 2/3: %s"},
+		{"-simulate needs -dryrun", "-simulate requiere -dryrun"},
+		{
+			"Already sent this template and subject to these %d " +
+				"recipients on %s. Use -resend to send again",
+			"Ya se envió esta plantilla y asunto a estos %d destinatarios " +
+				"el %s. Use -resend para enviar de nuevo",
+		},
+		{"%s has no tags column", "%s no tiene columna tags"},
+		{"Previewing on http://%s", "Vista previa en http://%s"},
+		{
+			"Stopping because the recipients failed a guard:\n%v",
+			"Se detiene porque los destinatarios no pasaron un control:\n%v",
+		},
+		{"Subject template errors", "Errores en la plantilla del asunto"},
+		{
+			"Already sent this template and subject to these %d " +
+				"recipients on %s. Send again? [y/N] ",
+			"Ya se envió esta plantilla y asunto a estos %d destinatarios " +
+				"el %s. ¿Enviar de nuevo? [y/N] ",
+		},
+		{"Not sending", "No se envía"},
+	},
+	"fr": {
+		{
+			"-template, -csv, and -subject flags required.",
+			"Les options -template, -csv et -subject sont obligatoires.",
+		},
+		{
+			"-screen must be report or exclude: %s",
+			"-screen doit valoir report ou exclude : %s",
+		},
+		{"Warning: ", "Attention : "},
+		{"Screened: %s %s", "Filtré : %s %s"},
+		{"Skipping", "Ignoré"},
+		{"line", "ligne"},
+		{"(seed)", "(test)"},
+		{"gets email", "reçoit le courriel"},
+		{"removed by %s", "retiré par %s"},
+		{"From:", "De :"},
+		{"To:", "À :"},
+		{"Subject:", "Objet :"},
+		{"Attachment:", "Pièce jointe :"},
+		{"Body:", "Corps :"},
+		{"HTML body:", "Corps HTML :"},
+		{
+			"Pre-flight found %d problem(s):",
+			"La vérification préalable a trouvé %d problème(s) :",
+		},
+		{"pre send hook failed", "échec du hook avant envoi"},
+		{"Body template errors", "Erreurs dans le modèle du corps"},
+		{
+			"Attachment path errors",
+			"Erreurs dans les chemins des pièces jointes",
+		},
+		{"Empty or short bodies", "Corps vides ou trop courts"},
+		{"Missing attachments", "Pièces jointes manquantes"},
+		{"Address and header errors", "Erreurs d'adresse et d'en-tête"},
+		{"Other errors", "Autres erreurs"},
+		{
+			"Emails over the %d byte limit",
+			"Courriels dépassant la limite de %d octets",
+		},
+		{"%d %s: email is %d bytes", "%d %s : le courriel fait %d octets"},
+		{"post send hook failed: %v", "échec du hook après envoi : %v"},
+		{"warm-up: %v", "montée en charge : %v"},
+		{"run history: %v", "historique des envois : %v"},
+		{
+			"Warm-up limit of %d emails a day reached. Run again tomorrow " +
+				"with -index %d",
+			"Limite de montée en charge de %d courriels par jour atteinte. " +
+				"Relancez demain avec -index %d",
+		},
+		{
+			"No recipients to base seed emails on",
+			"Aucun destinataire sur lequel baser les courriels de test",
+		},
+		{"Unrecognized emails: %s", "Adresses non reconnues : %s"},
+		{
+			"Warm-up limit of %d emails a day reached. Run -flush again " +
+				"tomorrow",
+			"Limite de montée en charge de %d courriels par jour atteinte. " +
+				"Relancez -flush demain",
+		},
+		{
+			"Queued %d email(s) in %s. Send them with -flush %s",
+			"%d courriel(s) en file d'attente dans %s. Envoyez-les avec " +
+				"-flush %s",
+		},
+		{
+			"-queue can't be used with -dryrun or -drafts",
+			"-queue ne peut pas être utilisé avec -dryrun ou -drafts",
+		},
+		{
+			"queue: %v",
+			"file d'attente : %v",
+		},
+		{
+			"-shard must look like 2/3: %s",
+			"-shard doit être de la forme 2/3 : %s",
+		},
+		{"-simulate needs -dryrun", "-simulate nécessite -dryrun"},
+		{
+			"Already sent this template and subject to these %d " +
+				"recipients on %s. Use -resend to send again",
+			"Ce modèle et cet objet ont déjà été envoyés à ces %d " +
+				"destinataires le %s. Utilisez -resend pour renvoyer",
+		},
+		{"%s has no tags column", "%s n'a pas de colonne tags"},
+		{"Previewing on http://%s", "Aperçu sur http://%s"},
+		{
+			"Stopping because the recipients failed a guard:\n%v",
+			"Arrêt car les destinataires ont échoué à un contrôle :\n%v",
+		},
+		{"Subject template errors", "Erreurs dans le modèle de l'objet"},
+		{
+			"Already sent this template and subject to these %d " +
+				"recipients on %s. Send again? [y/N] ",
+			"Ce modèle et cet objet ont déjà été envoyés à ces %d " +
+				"destinataires le %s. Renvoyer ? [y/N] ",
+		},
+		{"Not sending", "Rien n'est envoyé"},
+	},
+	"de": {
+		{
+			"-template, -csv, and -subject flags required.",
+			"Die Optionen -template, -csv und -subject sind erforderlich.",
+		},
+		{
+			"-screen must be report or exclude: %s",
+			"-screen muss report oder exclude sein: %s",
+		},
+		{"Warning: ", "Warnung: "},
+		{"Screened: %s %s", "Aussortiert: %s %s"},
+		{"Skipping", "Übersprungen"},
+		{"line", "Zeile"},
+		{"(seed)", "(Test)"},
+		{"gets email", "bekommt die E-Mail"},
+		{"removed by %s", "entfernt durch %s"},
+		{"From:", "Von:"},
+		{"To:", "An:"},
+		{"Subject:", "Betreff:"},
+		{"Attachment:", "Anhang:"},
+		{"Body:", "Text:"},
+		{"HTML body:", "HTML-Text:"},
+		{
+			"Pre-flight found %d problem(s):",
+			"Die Vorabprüfung hat %d Problem(e) gefunden:",
+		},
+		{"pre send hook failed", "Hook vor dem Senden fehlgeschlagen"},
+		{"Body template errors", "Fehler in der Textvorlage"},
+		{"Attachment path errors", "Fehler in Anhangspfaden"},
+		{"Empty or short bodies", "Leere oder kurze Texte"},
+		{"Missing attachments", "Fehlende Anhänge"},
+		{"Address and header errors", "Adress- und Kopfzeilenfehler"},
+		{"Other errors", "Andere Fehler"},
+		{
+			"Emails over the %d byte limit",
+			"E-Mails über dem Limit von %d Bytes",
+		},
+		{"%d %s: email is %d bytes", "%d %s: E-Mail hat %d Bytes"},
+		{
+			"post send hook failed: %v",
+			"Hook nach dem Senden fehlgeschlagen: %v",
+		},
+		{"warm-up: %v", "Aufwärmphase: %v"},
+		{"run history: %v", "Versandverlauf: %v"},
+		{
+			"Warm-up limit of %d emails a day reached. Run again tomorrow " +
+				"with -index %d",
+			"Aufwärmlimit von %d E-Mails pro Tag erreicht. Morgen erneut " +
+				"mit -index %d ausführen",
+		},
+		{
+			"No recipients to base seed emails on",
+			"Keine Empfänger als Vorlage für Test-E-Mails",
+		},
+		{"Unrecognized emails: %s", "Unbekannte E-Mails: %s"},
+		{
+			"Warm-up limit of %d emails a day reached. Run -flush again " +
+				"tomorrow",
+			"Aufwärmlimit von %d E-Mails pro Tag erreicht. Morgen erneut " +
+				"-flush ausführen",
+		},
+		{
+			"Queued %d email(s) in %s. Send them with -flush %s",
+			"%d E-Mail(s) in %s eingereiht. Mit -flush %s senden",
+		},
+		{
+			"-queue can't be used with -dryrun or -drafts",
+			"-queue kann nicht mit -dryrun oder -drafts verwendet werden",
+		},
+		{
+			"queue: %v",
+			"Warteschlange: %v",
+		},
+		{"-shard must look like 2/3: %s", "-shard muss die Form 2/3 haben: %s"},
+		{"-simulate needs -dryrun", "-simulate erfordert -dryrun"},
+		{
+			"Already sent this template and subject to these %d " +
+				"recipients on %s. Use -resend to send again",
+			"Diese Vorlage und dieser Betreff wurden bereits am %[2]s an " +
+				"diese %[1]d Empfänger gesendet. Verwenden Sie -resend, um " +
+				"erneut zu senden",
+		},
+		{"%s has no tags column", "%s hat keine Spalte tags"},
+		{"Previewing on http://%s", "Vorschau auf http://%s"},
+		{
+			"Stopping because the recipients failed a guard:\n%v",
+			"Abbruch, weil die Empfänger eine Prüfung nicht bestanden:\n%v",
+		},
+		{"Subject template errors", "Fehler in der Betreffvorlage"},
+		{
+			"Already sent this template and subject to these %d " +
+				"recipients on %s. Send again? [y/N] ",
+			"Diese Vorlage und dieser Betreff wurden bereits am %[2]s an " +
+				"diese %[1]d Empfänger gesendet. Erneut senden? [y/N] ",
+		},
+		{"Not sending", "Es wird nicht gesendet"},
+	},
+}
+
+// languages returns the languages that -lang accepts.
+func languages() []string {
+	result := []string{"en"}
+	for lang := range kMessages {
+		result = append(result, lang)
+	}
+	slices.Sort(result[1:])
+	return result
+}
+
+// checkLang returns an error if -lang isn't a language mailmerge knows.
+func checkLang() error {
+	if !slices.Contains(languages(), fLang) {
+		return fmt.Errorf(
+			"-lang must be one of %s: %s",
+			strings.Join(languages(), ", "),
+			fLang)
+	}
+	return nil
+}
+
+// tr returns message in the language of -lang.
+func tr(message string) string {
+	for _, t := range kMessages[fLang] {
+		if t.English == message {
+			return t.Translated
+		}
+	}
+	return message
+}
+
+// trReason translates the kind at the start of a reason like
+// "Missing attachments: cert.pdf".
+func trReason(reason string) string {
+	kind, detail, ok := strings.Cut(reason, ": ")
+	if !ok {
+		return reason
+	}
+	return tr(kind) + ": " + detail
+}
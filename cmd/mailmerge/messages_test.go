@@ -0,0 +1,53 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	kVerb = regexp.MustCompile(
+		`%(?:\[\d+\])?[-+# 0]*\d*(?:\.\d+)?[a-zA-Z%]`)
+	kVerbIndex = regexp.MustCompile(`\[\d+\]`)
+)
+
+// verbs returns the verbs in format sorted without argument indexes.
+func verbs(format string) []string {
+	result := kVerb.FindAllString(format, -1)
+	for i, verb := range result {
+		result[i] = kVerbIndex.ReplaceAllString(verb, "")
+	}
+	slices.Sort(result)
+	return result
+}
+
+func TestTranslationsKeepVerbs(t *testing.T) {
+	for lang, translations := range kMessages {
+		for _, translation := range translations {
+			assert.Equal(
+				t,
+				verbs(translation.English),
+				verbs(translation.Translated),
+				"%s: %q", lang, translation.English)
+		}
+	}
+}
+
+func TestLanguagesTranslateSameMessages(t *testing.T) {
+	english := func(lang string) []string {
+		var result []string
+		for _, translation := range kMessages[lang] {
+			result = append(result, translation.English)
+		}
+		slices.Sort(result)
+		return result
+	}
+	for _, lang := range languages() {
+		if lang != "en" {
+			assert.Equal(t, english("es"), english(lang), lang)
+		}
+	}
+}
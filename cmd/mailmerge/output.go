@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/keep94/mailmerge/mailer"
+	"github.com/keep94/mailmerge/merge"
+)
+
+// reporter writes what mailmerge has to say to stdout either as text for
+// people or, with -json, as one JSON object per line for programs.
+type reporter interface {
+
+	// Fatal reports err and exits with code.
+	Fatal(err error, code int)
+
+	// Usage reports that the flags are wrong and exits with code 2.
+	Usage(message string)
+
+	// Warning reports a problem that doesn't stop mailmerge. message is
+	// in English; translated is in the language of -lang. Use warningf
+	// instead of calling Warning directly.
+	Warning(message, translated string)
+
+	// Screened reports an email that -screen flagged.
+	Screened(email, reason string)
+
+	// Skipped reports a row that won't get an email. line is where row
+	// starts in the CSV file or 0 if unknown.
+	Skipped(index, line int, row merge.CsvRow, reason string)
+
+	// Sending reports that the email for row is about to go out.
+	Sending(index int, row merge.CsvRow, seed bool)
+
+	// Sent reports the result of sending the email for row. status says
+	// what sending means in this run: kSent, kDryRun, or kDrafted.
+	Sent(index int, row merge.CsvRow, seed bool, status string, err error)
+
+	// Explained reports which filter removed row. removedBy is empty if
+	// no filter removed row. line is where row starts in the CSV file or
+	// 0 if unknown.
+	Explained(index, line int, row merge.CsvRow, removedBy string)
+
+	// DryRun reports an email that -dryrun didn't send.
+	DryRun(email *mailer.Email)
+
+	// Queued reports that -queue saved count emails in dir.
+	Queued(count int, dir string)
+
+	// Checked reports a check that -doctor ran. err is nil if the check
+	// passed; otherwise fix says how to fix it.
+	Checked(what string, err error, fix string)
+
+	// Summary reports totals at the end of the run.
+	Summary()
+}
+
+// The statuses of an email that went out without error.
+const (
+	kSent    = "sent"
+	kDryRun  = "dryrun"
+	kDrafted = "drafted"
+)
+
+// out is where mailmerge reports to.
+var out reporter = textReporter{}
+
+type textReporter struct {
+}
+
+func (t textReporter) Fatal(err error, code int) {
+	fmt.Println(err)
+	os.Exit(code)
+}
+
+func (t textReporter) Usage(message string) {
+	fmt.Println(message)
+	flag.Usage()
+	os.Exit(2)
+}
+
+func (t textReporter) Warning(message, translated string) {
+	fmt.Println(tr("Warning: ") + translated)
+}
+
+func (t textReporter) Screened(email, reason string) {
+	fmt.Printf(tr("Screened: %s %s")+"\n", email, reason)
+}
+
+func (t textReporter) Skipped(
+	index, line int, row merge.CsvRow, reason string) {
+	fmt.Printf(
+		"%s %d %s%s: %s\n",
+		tr("Skipping"),
+		index,
+		row.Email(),
+		atLine(line),
+		trReason(reason))
+}
+
+func (t textReporter) Sending(index int, row merge.CsvRow, seed bool) {
+	if seed {
+		fmt.Printf(
+			"%d %s %s %s\n", index, row.Email(), row.Name(), tr("(seed)"))
+	} else {
+		fmt.Printf("%d %s %s\n", index, row.Email(), row.Name())
+	}
+}
+
+func (t textReporter) Sent(
+	index int, row merge.CsvRow, seed bool, status string, err error) {
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func (t textReporter) Explained(
+	index, line int, row merge.CsvRow, removedBy string) {
+	if removedBy == "" {
+		fmt.Printf(
+			"%d %s %s%s: %s\n",
+			index,
+			row.Email(),
+			row.Name(),
+			atLine(line),
+			tr("gets email"))
+	} else {
+		fmt.Printf(
+			"%d %s %s%s: %s\n",
+			index,
+			row.Email(),
+			row.Name(),
+			atLine(line),
+			fmt.Sprintf(tr("removed by %s"), removedBy))
+	}
+}
+
+func (t textReporter) DryRun(email *mailer.Email) {
+	fmt.Println()
+	if email.From != "" {
+		fmt.Println(tr("From:"), email.From)
+	}
+	fmt.Println(tr("To:"), strings.Join(email.To, ", "))
+	fmt.Println(tr("Subject:"), email.Subject)
+	for _, attachment := range email.Attachments {
+		fmt.Println(tr("Attachment:"), attachment)
+	}
+	fmt.Println(tr("Body:"))
+	fmt.Println(email.Body)
+	if email.HtmlBody != "" {
+		fmt.Println(tr("HTML body:"))
+		fmt.Println(email.HtmlBody)
+	}
+}
+
+func (t textReporter) Queued(count int, dir string) {
+	fmt.Printf(
+		tr("Queued %d email(s) in %s. Send them with -flush %s")+"\n",
+		count,
+		dir,
+		dir)
+}
+
+func (t textReporter) Checked(what string, err error, fix string) {
+	if err == nil {
+		fmt.Println("ok  ", what)
+		return
+	}
+	fmt.Println("FAIL", what)
+	fmt.Println("    ", strings.ReplaceAll(err.Error(), "\n", "\n     "))
+	if fix != "" {
+		fmt.Println("     Fix:", fix)
+	}
+}
+
+func (t textReporter) Summary() {
+}
+
+// jsonReporter reports each event as a JSON object with a type field.
+type jsonReporter struct {
+	encoder *json.Encoder
+	status  string
+	sent    int
+	failed  int
+	skipped int
+}
+
+func newJsonReporter() *jsonReporter {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	return &jsonReporter{encoder: encoder, status: kSent}
+}
+
+func (j *jsonReporter) Fatal(err error, code int) {
+	j.encoder.Encode(map[string]any{"type": "error", "error": err.Error()})
+	os.Exit(code)
+}
+
+func (j *jsonReporter) Usage(message string) {
+	j.Fatal(errors.New(message), 2)
+}
+
+func (j *jsonReporter) Warning(message, translated string) {
+	j.encoder.Encode(map[string]any{"type": "warning", "warning": message})
+}
+
+func (j *jsonReporter) Screened(email, reason string) {
+	j.encoder.Encode(map[string]any{
+		"type": "screened", "email": email, "reason": reason})
+}
+
+func (j *jsonReporter) Skipped(
+	index, line int, row merge.CsvRow, reason string) {
+	j.skipped++
+	j.encoder.Encode(map[string]any{
+		"type":   "skipped",
+		"index":  index,
+		"line":   line,
+		"email":  row.Email(),
+		"reason": reason,
+	})
+}
+
+func (j *jsonReporter) Sending(index int, row merge.CsvRow, seed bool) {
+}
+
+func (j *jsonReporter) Sent(
+	index int, row merge.CsvRow, seed bool, status string, err error) {
+	j.status = status
+	result := map[string]any{
+		"type":   "sent",
+		"index":  index,
+		"email":  row.Email(),
+		"name":   row.Name(),
+		"seed":   seed,
+		"status": status,
+	}
+	if err != nil {
+		j.failed++
+		result["status"] = "failed"
+		result["error"] = err.Error()
+	} else {
+		j.sent++
+	}
+	j.encoder.Encode(result)
+}
+
+func (j *jsonReporter) Explained(
+	index, line int, row merge.CsvRow, removedBy string) {
+	j.encoder.Encode(map[string]any{
+		"type":      "explain",
+		"index":     index,
+		"line":      line,
+		"email":     row.Email(),
+		"name":      row.Name(),
+		"removedBy": removedBy,
+	})
+}
+
+func (j *jsonReporter) DryRun(email *mailer.Email) {
+	j.encoder.Encode(map[string]any{
+		"type":        "dryrun",
+		"from":        email.From,
+		"to":          email.To,
+		"subject":     email.Subject,
+		"attachments": email.Attachments,
+		"body":        email.Body,
+		"htmlBody":    email.HtmlBody,
+	})
+}
+
+func (j *jsonReporter) Queued(count int, dir string) {
+	j.encoder.Encode(map[string]any{
+		"type": "queued", "count": count, "dir": dir})
+}
+
+func (j *jsonReporter) Checked(what string, err error, fix string) {
+	result := map[string]any{"type": "check", "check": what, "ok": err == nil}
+	if err != nil {
+		result["error"] = err.Error()
+		result["fix"] = fix
+	}
+	j.encoder.Encode(result)
+}
+
+func (j *jsonReporter) Summary() {
+	j.encoder.Encode(map[string]any{
+		"type":    "summary",
+		"status":  j.status,
+		"sent":    j.sent,
+		"failed":  j.failed,
+		"skipped": j.skipped,
+	})
+}
+
+// warningf reports a warning formatted like fmt.Sprintf so that vet
+// checks the format against args. Translations of format must keep its
+// verbs.
+func warningf(format string, args ...any) {
+	out.Warning(fmt.Sprintf(format, args...), fmt.Sprintf(tr(format), args...))
+}
+
+// atLine returns " (line N)" or the empty string if line is 0.
+func atLine(line int) string {
+	if line == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%s %d)", tr("line"), line)
+}
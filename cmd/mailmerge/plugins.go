@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"plugin"
+
+	"github.com/keep94/mailmerge/merge"
+)
+
+// plugins holds what the -plugin flags loaded.
+type plugins struct {
+	// Template functions from every plugin
+	Funcs map[string]any
+
+	// Row filters from every plugin
+	Filters []func(map[string]string) bool
+}
+
+// loadPlugins opens the Go plugins at paths. A plugin may export
+//
+//	var Funcs map[string]any
+//
+// to add template functions and
+//
+//	func Filter(row map[string]string) bool
+//
+// to send only to the rows for which Filter returns true. Plugins must be
+// built with the same Go version as mailmerge.
+func loadPlugins(paths []string) (*plugins, error) {
+	result := &plugins{Funcs: make(map[string]any)}
+	for _, path := range paths {
+		p, err := plugin.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		if sym, err := p.Lookup("Funcs"); err == nil {
+			funcs, ok := sym.(*map[string]any)
+			if !ok {
+				return nil, fmt.Errorf(
+					"%s: Funcs must be a map[string]any", path)
+			}
+			for name, f := range *funcs {
+				result.Funcs[name] = f
+			}
+			found = true
+		}
+		if sym, err := p.Lookup("Filter"); err == nil {
+			filter, ok := sym.(func(map[string]string) bool)
+			if !ok {
+				return nil, fmt.Errorf(
+					"%s: Filter must be a func(map[string]string) bool", path)
+			}
+			result.Filters = append(result.Filters, filter)
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("%s: exports neither Funcs nor Filter", path)
+		}
+	}
+	return result, nil
+}
+
+// Filter returns a filter keeping the rows that pass every plugin
+// filter or nil if there are no plugin filters.
+func (p *plugins) Filter() merge.Filter {
+	if len(p.Filters) == 0 {
+		return nil
+	}
+	return pluginFilter(p.Filters)
+}
+
+type pluginFilter []func(map[string]string) bool
+
+func (p pluginFilter) String() string {
+	return "plugin"
+}
+
+func (p pluginFilter) Select(csvFile *merge.CsvFile) (*merge.CsvFile, error) {
+	return csvFile.Select(func(row merge.CsvRow) bool {
+		for _, filter := range p {
+			// Plugins get a copy so that they can't change the row.
+			if !filter(row.Clone()) {
+				return false
+			}
+		}
+		return true
+	}), nil
+}
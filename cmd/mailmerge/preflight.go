@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/keep94/mailmerge/mailer"
+	"github.com/keep94/mailmerge/merge"
+)
+
+const (
+	kBodyProblem       = "Body template errors"
+	kSubjectProblem    = "Subject template errors"
+	kAttachProblem     = "Attachment path errors"
+	kShortProblem      = "Empty or short bodies"
+	kMissingProblem    = "Missing attachments"
+	kMessageProblem    = "Address and header errors"
+	kOtherEmailProblem = "Other errors"
+)
+
+// emailError is an error creating an email along with what kind of
+// problem it is e.g kBodyProblem.
+type emailError struct {
+	Kind string
+	Err  error
+}
+
+func (e *emailError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *emailError) Unwrap() error {
+	return e.Err
+}
+
+// problemReport groups pre-flight problems by kind keeping kinds in the
+// order first seen.
+type problemReport struct {
+	kinds    []string
+	problems map[string][]string
+	count    int
+}
+
+func (p *problemReport) Add(
+	kind string, index, line int, row merge.CsvRow, detail string) {
+	if p.problems == nil {
+		p.problems = make(map[string][]string)
+	}
+	if _, ok := p.problems[kind]; !ok {
+		p.kinds = append(p.kinds, kind)
+	}
+	p.problems[kind] = append(
+		p.problems[kind],
+		fmt.Sprintf("%d %s%s: %s", index, row.Email(), atLine(line), detail))
+	p.count++
+}
+
+// Err returns nil if there are no problems or an error listing them all.
+func (p *problemReport) Err() error {
+	if p.count == 0 {
+		return nil
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, tr("Pre-flight found %d problem(s):"), p.count)
+	for _, kind := range p.kinds {
+		fmt.Fprintf(&sb, "\n%s:\n  %s", tr(kind), strings.Join(
+			p.problems[kind], "\n  "))
+	}
+	return errors.New(sb.String())
+}
+
+// preflightOptions controls preflight.
+type preflightOptions struct {
+
+	// The index of the first row to send to
+	StartIndex int
+
+	// If true, rows with problems are skipped instead of stopping
+	// mailmerge.
+	KeepGoing bool
+
+	// Bodies with fewer characters than this, not counting leading and
+	// trailing whitespace, are a problem. Empty bodies are always a
+	// problem.
+	MinLength int
+
+	// Logs the stack traces of template panics
+	Logger *slog.Logger
+}
+
+// preflight creates the email for each row starting at StartIndex before
+// anything is sent so that problems show up front rather than mid-send.
+// preflight warns about emails over the warning size. Rather than
+// stopping at the first problem, preflight checks every row and fails
+// listing every problem grouped by kind: template errors, empty or short
+// bodies, missing attachments, bad addresses or headers, and emails over
+// the maximum size. If config has a pre send hook, attachments that don't
+// exist yet aren't a problem as the hook may make them just before
+// sending. Problems cite the line in the CSV file where each row starts.
+// With KeepGoing, preflight skips rows with problems instead. The
+// returned emails line up with the rows of csvFile; those before
+// StartIndex and those skipped are nil.
+func preflight(
+	csvFile *merge.CsvFile,
+	newEmail func(merge.CsvRow) (*mailer.Email, error),
+	config *config,
+	options *preflightOptions) ([]*mailer.Email, error) {
+	result := make([]*mailer.Email, len(csvFile.Rows))
+	var report problemReport
+	problem := func(kind string, index int, row merge.CsvRow, detail string) {
+		line := csvFile.Line(index)
+		if options.KeepGoing {
+			out.Skipped(index, line, row, kind+": "+detail)
+		} else {
+			report.Add(kind, index, line, row, detail)
+		}
+	}
+	tooBig := fmt.Sprintf(
+		tr("Emails over the %d byte limit"), config.MaxMessageSize)
+	for index, row := range csvFile.Rows {
+		if index < options.StartIndex {
+			continue
+		}
+		email, err := newEmail(row)
+		if err != nil {
+			kind := kOtherEmailProblem
+			var emailErr *emailError
+			if errors.As(err, &emailErr) {
+				kind = emailErr.Kind
+			}
+			var panicErr *merge.PanicError
+			if errors.As(err, &panicErr) {
+				options.Logger.Error(
+					"template panic",
+					"index", index,
+					"email", row.Email(),
+					"panic", panicErr.Value,
+					"stack", string(panicErr.Stack))
+			}
+			problem(kind, index, row, err.Error())
+			continue
+		}
+		length := utf8.RuneCountInString(strings.TrimSpace(email.Body))
+		if length == 0 || length < options.MinLength {
+			problem(
+				kShortProblem,
+				index,
+				row,
+				fmt.Sprintf("body has %d characters", length))
+			continue
+		}
+		missing := false
+		for _, attachment := range email.Attachments {
+			if !isFile(attachment) {
+				missing = true
+				if config.PreSendHook == "" {
+					problem(kMissingProblem, index, row, attachment)
+				}
+			}
+		}
+		if missing {
+			// The pre send hook may make the missing attachments just
+			// before sending, so the email can't be checked further.
+			if config.PreSendHook != "" {
+				result[index] = email
+			}
+			continue
+		}
+		msg, err := email.Message(config.EmailId)
+		if err != nil {
+			problem(kMessageProblem, index, row, err.Error())
+			continue
+		}
+		if len(msg) > config.MaxMessageSize {
+			problem(tooBig, index, row, fmt.Sprintf("%d bytes", len(msg)))
+			continue
+		}
+		if config.WarnMessageSize > 0 && len(msg) > config.WarnMessageSize {
+			warningf(
+				"%d %s: email is %d bytes",
+				index,
+				row.Email(),
+				len(msg))
+		}
+		result[index] = email
+	}
+	if err := report.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
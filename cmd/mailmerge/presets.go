@@ -0,0 +1,309 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/keep94/mailmerge/merge"
+	"gopkg.in/yaml.v3"
+)
+
+// preset is a named set of flag values in the campaigns file. Paths are
+// relative to the directory of the campaigns file.
+type preset struct {
+	Template     string   `yaml:"template"`
+	HtmlTemplate string   `yaml:"html-template"`
+	Layout       string   `yaml:"layout"`
+	Csv          string   `yaml:"csv"`
+	Subject      string   `yaml:"subject"`
+	Emails       string   `yaml:"emails"`
+	NoEmails     string   `yaml:"noemails"`
+	Tags         string   `yaml:"tags"`
+	NoTags       string   `yaml:"notags"`
+	SeedList     string   `yaml:"seedlist"`
+	Screen       string   `yaml:"screen"`
+	Salutation   string   `yaml:"salutation"`
+	From         string   `yaml:"from"`
+	Priority     string   `yaml:"priority"`
+	ReadReceipt  bool     `yaml:"readreceipt"`
+	Attach       []string `yaml:"attach"`
+	Tenant       string   `yaml:"tenant"`
+	Config       string   `yaml:"config"`
+
+	// Extra columns that every row gets unless the CSV file already has
+	// them e.g the event date.
+	Vars map[string]string `yaml:"vars"`
+
+	// Chooses who gets the email in place of selecting the people going.
+	Filters []filterSpec `yaml:"filters"`
+
+	// Checks the recipients before anything is sent.
+	Guards []guardSpec `yaml:"guards"`
+}
+
+// filterSpec is one entry in the filters of a preset e.g going or
+// limit: 100.
+type filterSpec struct {
+	Name string
+	Arg  string
+	Line int
+}
+
+func (f *filterSpec) UnmarshalYAML(node *yaml.Node) error {
+	f.Line = node.Line
+	var err error
+	f.Name, f.Arg, err = nameValue(node, "filter")
+	return err
+}
+
+// nameValue returns the name and value of node, a list entry in the
+// campaigns file that is either a name or name: value. what says what
+// kind of entry it is for errors.
+func nameValue(node *yaml.Node, what string) (name, value string, err error) {
+	switch {
+	case node.Kind == yaml.ScalarNode:
+		return node.Value, "", nil
+	case node.Kind == yaml.MappingNode && len(node.Content) == 2:
+		return node.Content[0].Value, node.Content[1].Value, nil
+	}
+	return "", "", fmt.Errorf(
+		"line %d: a %s must be a name or name: value", node.Line, what)
+}
+
+// filter returns the merge.Filter for this spec.
+func (f *filterSpec) filter() (merge.Filter, error) {
+	needsArg := f.Name == "emails" || f.Name == "noemails" ||
+		f.Name == "tags" || f.Name == "notags" ||
+		f.Name == "where" || f.Name == "limit"
+	if needsArg != (f.Arg != "") {
+		if needsArg {
+			return nil, fmt.Errorf("line %d: %s needs a value", f.Line, f.Name)
+		}
+		return nil, fmt.Errorf("line %d: %s takes no value", f.Line, f.Name)
+	}
+	switch f.Name {
+	case "going":
+		return merge.GoingFilter(), nil
+	case "screen":
+		return merge.ScreenFilter(), nil
+	case "dedupe":
+		return merge.DedupeFilter(), nil
+	case "emails":
+		return merge.EmailsFilter(merge.NewEmailSet(f.Arg)), nil
+	case "noemails":
+		return merge.NoEmailsFilter(merge.NewEmailSet(f.Arg)), nil
+	case "tags":
+		return merge.TagsFilter(merge.NewTagSet(f.Arg)), nil
+	case "notags":
+		return merge.NoTagsFilter(merge.NewTagSet(f.Arg)), nil
+	case "limit":
+		n, err := strconv.Atoi(f.Arg)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf(
+				"line %d: limit must be a whole number", f.Line)
+		}
+		return merge.LimitFilter(n), nil
+	case "where":
+		result, err := merge.WhereFilter(f.Arg)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", f.Line, err)
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf(
+		"line %d: %s is not going, screen, dedupe, emails, noemails, "+
+			"tags, notags, where, or limit",
+		f.Line,
+		f.Name)
+}
+
+// guardSpec is one entry in the guards of a preset e.g noduplicates or
+// maxrecipients: 2000.
+type guardSpec struct {
+	Name string
+	Arg  string
+	Line int
+}
+
+func (g *guardSpec) UnmarshalYAML(node *yaml.Node) error {
+	g.Line = node.Line
+	var err error
+	g.Name, g.Arg, err = nameValue(node, "guard")
+	return err
+}
+
+// guard returns the merge.Guard for this spec.
+func (g *guardSpec) guard() (merge.Guard, error) {
+	needsArg := g.Name == "maxrecipients" || g.Name == "maxempty"
+	if needsArg != (g.Arg != "") {
+		if needsArg {
+			return nil, fmt.Errorf("line %d: %s needs a value", g.Line, g.Name)
+		}
+		return nil, fmt.Errorf("line %d: %s takes no value", g.Line, g.Name)
+	}
+	switch g.Name {
+	case "noduplicates":
+		return merge.NoDuplicatesGuard(), nil
+	case "maxrecipients":
+		n, err := strconv.Atoi(g.Arg)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf(
+				"line %d: maxrecipients must be a whole number", g.Line)
+		}
+		return merge.MaxRecipientsGuard(n), nil
+	case "maxempty":
+		column, percent, ok := strings.Cut(strings.TrimSpace(g.Arg), " ")
+		percent = strings.TrimSuffix(strings.TrimSpace(percent), "%")
+		value, err := strconv.ParseFloat(percent, 64)
+		if !ok || err != nil || value < 0 {
+			return nil, fmt.Errorf(
+				"line %d: maxempty must be a column and a percent e.g "+
+					"firstName 5%%",
+				g.Line)
+		}
+		return merge.MaxEmptyGuard(column, value), nil
+	}
+	return nil, fmt.Errorf(
+		"line %d: %s is not noduplicates, maxrecipients, or maxempty",
+		g.Line,
+		g.Name)
+}
+
+// guardList returns the guards of this preset.
+func (p *preset) guardList() (merge.GuardList, error) {
+	var result merge.GuardList
+	for i := range p.Guards {
+		guard, err := p.Guards[i].guard()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, guard)
+	}
+	return result, nil
+}
+
+// filterChain returns the filters of this preset or nil if it has
+// none.
+func (p *preset) filterChain() (merge.FilterChain, error) {
+	var result merge.FilterChain
+	for i := range p.Filters {
+		filter, err := p.Filters[i].filter()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, filter)
+	}
+	return result, nil
+}
+
+// flagValues returns the values of this preset keyed by flag name with
+// paths resolved against dir.
+func (p *preset) flagValues(dir string) map[string][]string {
+	result := make(map[string][]string)
+	add := func(name, value string) {
+		if value != "" {
+			result[name] = append(result[name], value)
+		}
+	}
+	path := func(value string) string {
+		if value == "" || filepath.IsAbs(value) {
+			return value
+		}
+		return filepath.Join(dir, value)
+	}
+	add("template", path(p.Template))
+	add("html-template", path(p.HtmlTemplate))
+	add("layout", path(p.Layout))
+	add("csv", path(p.Csv))
+	add("subject", p.Subject)
+	add("emails", p.Emails)
+	add("noemails", p.NoEmails)
+	add("tags", p.Tags)
+	add("notags", p.NoTags)
+	add("seedlist", path(p.SeedList))
+	add("screen", p.Screen)
+	add("salutation", p.Salutation)
+	add("from", p.From)
+	add("priority", p.Priority)
+	if p.ReadReceipt {
+		add("readreceipt", strconv.FormatBool(p.ReadReceipt))
+	}
+	for _, attach := range p.Attach {
+		add("attach", path(attach))
+	}
+	add("tenant", p.Tenant)
+	add("config", path(p.Config))
+	return result
+}
+
+// withVars returns csvFile with a column for each var that csvFile
+// doesn't already have.
+func (p *preset) withVars(csvFile *merge.CsvFile) *merge.CsvFile {
+	for name, value := range p.Vars {
+		if slices.Contains(csvFile.Headers, name) {
+			continue
+		}
+		csvFile = csvFile.WithColumn(name, -1, func(merge.CsvRow) string {
+			return value
+		})
+	}
+	return csvFile
+}
+
+// applyPreset reads the preset called name from campaignsPath and sets
+// each flag that the command line didn't set to the preset's value.
+func applyPreset(campaignsPath, name string) (*preset, error) {
+	presets, err := readPresets(campaignsPath)
+	if err != nil {
+		return nil, err
+	}
+	result, ok := presets[name]
+	if !ok || result == nil {
+		return nil, fmt.Errorf("%s: no preset called %s", campaignsPath, name)
+	}
+	if _, err := result.filterChain(); err != nil {
+		return nil, fmt.Errorf("%s: %v", campaignsPath, err)
+	}
+	if _, err := result.guardList(); err != nil {
+		return nil, fmt.Errorf("%s: %v", campaignsPath, err)
+	}
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	values := result.flagValues(filepath.Dir(campaignsPath))
+	for name, flagValues := range values {
+		if explicit[name] {
+			continue
+		}
+		for _, value := range flagValues {
+			if err := flag.Set(name, value); err != nil {
+				return nil, fmt.Errorf("%s: %s: %v", campaignsPath, name, err)
+			}
+		}
+	}
+	return result, nil
+}
+
+func readPresets(campaignsPath string) (map[string]*preset, error) {
+	f, err := os.Open(campaignsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]*preset
+	if err := decodeYaml(content, &result); err != nil {
+		return nil, fmt.Errorf("%s:\n  %v", campaignsPath, yamlError(err))
+	}
+	return result, nil
+}
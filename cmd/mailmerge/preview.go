@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"html/template"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/keep94/mailmerge/mailer"
+	"github.com/keep94/mailmerge/merge"
+)
+
+// servePreview serves previews of the emails at addr until it fails.
+func servePreview(addr string, campaign *preset) error {
+	plugins, err := loadPlugins(fPlugin)
+	if err != nil {
+		return err
+	}
+	fmt.Printf(tr("Previewing on http://%s")+"\n", addr)
+	return http.ListenAndServe(addr, &previewServer{
+		campaign: campaign,
+		plugins:  plugins,
+		lastGood: make(map[int]*mailer.Email),
+		pages:    make(map[int]*previewPage),
+	})
+}
+
+// previewServer shows the email for one recipient at / with a list to
+// pick the recipient. The list holds the same recipients, in the same
+// order, as sending would, seeds included, so the numbers match -index.
+// previewServer reads the files again when one of them changes so that
+// the page shows the latest edits, and the page reloads itself when that
+// happens. Until then, previewServer serves the pages it already
+// rendered. If a row no longer
+// renders, say because of a typo in a template, the page shows the error
+// along with the last email that row rendered.
+type previewServer struct {
+	campaign *preset
+	plugins  *plugins
+	mu       sync.Mutex
+	lastGood map[int]*mailer.Email
+	pages    map[int]*previewPage
+
+	// The files that previewServer watches and their modification
+	// times in nanoseconds when it last checked.
+	paths   []string
+	mtimes  []int64
+	version string
+}
+
+// previewPage is what the preview page shows.
+type previewPage struct {
+	Rows     []string
+	Selected int
+	Email    *mailer.Email
+	Err      error
+	Stale    bool
+	Version  string
+}
+
+func (p *previewServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/version":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, p.latestVersion())
+	case "/":
+		index, _ := strconv.Atoi(r.FormValue("row"))
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		kPreviewTemplate.Execute(w, p.render(index))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// render returns the page showing the email for the row at index. render
+// renders the page again only if a file changed since it last did.
+func (p *previewServer) render(index int) *previewPage {
+	version := p.latestVersion()
+	p.mu.Lock()
+	page, ok := p.pages[index]
+	p.mu.Unlock()
+	if ok && page.Version == version {
+		return page
+	}
+	result := p.renderLatest(index)
+	result.Version = version
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pages[index] = result
+	if result.Err == nil {
+		p.lastGood[index] = result.Email
+	} else if result.Email == nil && p.lastGood[index] != nil {
+		result.Email = p.lastGood[index]
+		result.Stale = true
+	}
+	return result
+}
+
+// renderLatest returns the page showing the email for the row at index
+// from the files as they are now.
+func (p *previewServer) renderLatest(index int) *previewPage {
+	result := &previewPage{Selected: index}
+	campaign, err := p.latestCampaign()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	csvFile, filters, err := readRecipients(campaign, p.plugins)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	csvFile, seedStart, err := selectRecipients(csvFile, filters, nil)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	for i, row := range csvFile.Rows {
+		label := fmt.Sprintf("%d %s %s", i, row.Email(), row.Name())
+		if i >= seedStart {
+			label += " (seed)"
+		}
+		result.Rows = append(result.Rows, label)
+	}
+	if index < 0 || index >= len(csvFile.Rows) {
+		result.Err = fmt.Errorf("no recipient %d", index)
+		return result
+	}
+	template, err := readTemplate(fTemplate, p.plugins)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	var htmlTemplate *merge.Template
+	if fHtmlTemplate != "" {
+		htmlTemplate, err = readHtmlTemplate(fHtmlTemplate, p.plugins)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+	}
+	subject, err := readSubject(fSubject, p.plugins)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Email, result.Err = createEmail(
+		template, htmlTemplate, csvFile.Rows[index], subject)
+	return result
+}
+
+// latestCampaign returns the preset as it is now in the campaigns file
+// so that edits to its vars and filters show up or nil if there is no
+// preset. Flags the preset set when the preview started stay as they
+// were.
+func (p *previewServer) latestCampaign() (*preset, error) {
+	if p.campaign == nil {
+		return nil, nil
+	}
+	presets, err := readPresets(fCampaigns)
+	if err != nil {
+		return nil, err
+	}
+	result, ok := presets[fPreset]
+	if !ok || result == nil {
+		return nil, fmt.Errorf("%s: no preset called %s", fCampaigns, fPreset)
+	}
+	return result, nil
+}
+
+// latestVersion returns a string that changes when the CSV file, a
+// template, the seed list, the campaigns file, or a config file changes.
+// latestVersion only stats the files it watches unless one of them
+// changed since it last checked. Then it reads the config again to learn
+// which config files to watch.
+func (p *previewServer) latestVersion() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paths != nil && slices.Equal(modTimes(p.paths), p.mtimes) {
+		return p.version
+	}
+	p.paths = previewPaths()
+	p.mtimes = modTimes(p.paths)
+	h := fnv.New64a()
+	for i, path := range p.paths {
+		fmt.Fprintf(h, "%s\x00%d\x00", path, p.mtimes[i])
+	}
+	p.version = strconv.FormatUint(h.Sum64(), 16)
+	return p.version
+}
+
+// previewPaths returns the files that the preview depends on.
+func previewPaths() []string {
+	paths := []string{fCsv, fTemplate, fHtmlTemplate, fLayout, fSeedList}
+	if fPreset != "" {
+		paths = append(paths, fCampaigns)
+	}
+	if _, files, err := loadConfig(fConfig, fTenant, false); err == nil {
+		paths = append(paths, files...)
+	} else {
+		paths = append(paths, kSystemConfigPath, configPath(), fConfig)
+	}
+	return slices.DeleteFunc(paths, func(path string) bool {
+		return path == ""
+	})
+}
+
+// modTimes returns the modification time of each file in paths in
+// nanoseconds or 0 for files that don't exist.
+func modTimes(paths []string) []int64 {
+	result := make([]int64, len(paths))
+	for i, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			result[i] = info.ModTime().UnixNano()
+		}
+	}
+	return result
+}
+
+var kPreviewTemplate = template.Must(template.New("preview").Parse(`
+<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>mailmerge preview</title>
+<style>
+iframe { width: 100%; height: 30em; border: 1px solid #ccc; }
+.error { color: #b00; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<form method="get" action="/">
+<select name="row" onchange="this.form.submit()">
+{{range $i, $r := .Rows}}<option value="{{$i}}"
+{{- if eq $i $.Selected}} selected{{end}}>{{$r}}</option>
+{{end}}</select>
+</form>
+{{with .Err}}<p class="error">{{.}}</p>{{end}}
+{{if .Stale}}<p>Showing the last version that rendered.</p>{{end}}
+{{with .Email}}<p>To: {{range .To}}{{.}} {{end}}<br>Subject: {{.Subject}}</p>
+<pre>{{.Body}}</pre>
+{{if .HtmlBody}}<iframe sandbox srcdoc="{{.HtmlBody}}"></iframe>{{end}}
+{{end}}
+<script>
+setInterval(function() {
+  fetch("/version").then(function(r) { return r.text(); }).then(function(v) {
+    if (v !== "{{.Version}}") { location.reload(); }
+  });
+}, 1000);
+</script>
+</body>
+</html>
+`))
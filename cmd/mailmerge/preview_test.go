@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/keep94/mailmerge/mailer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreviewVersion(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{
+		".mailmerge.yaml": "emailId: me@gmail.com\n",
+		"people.csv":      "email,name\nalice@gmail.com,Alice\n",
+		"template.txt":    "Hi {{.name}}",
+	})
+	oldCsv, oldTemplate, oldSubject := fCsv, fTemplate, fSubject
+	t.Cleanup(func() {
+		fCsv, fTemplate, fSubject = oldCsv, oldTemplate, oldSubject
+	})
+	fCsv = filepath.Join(dir, "people.csv")
+	fTemplate = filepath.Join(dir, "template.txt")
+	fSubject = "Picnic"
+	plugins, err := loadPlugins(nil)
+	assert.NoError(t, err)
+	p := &previewServer{
+		plugins:  plugins,
+		lastGood: make(map[int]*mailer.Email),
+		pages:    make(map[int]*previewPage),
+	}
+	version := p.latestVersion()
+	assert.Contains(t, p.paths, filepath.Join(dir, ".mailmerge.yaml"))
+	assert.Equal(t, version, p.latestVersion())
+	page := p.render(0)
+	assert.NoError(t, page.Err)
+	assert.Equal(t, "Hi Alice", page.Email.Body)
+	assert.Same(t, page, p.render(0))
+
+	touch := func(name string, when time.Time) {
+		assert.NoError(t, os.Chtimes(filepath.Join(dir, name), when, when))
+	}
+	past := time.Now().Add(-time.Hour)
+	touch("template.txt", past)
+	changed := p.latestVersion()
+	assert.NotEqual(t, version, changed)
+	assert.NotSame(t, page, p.render(0))
+	touch(".mailmerge.yaml", past.Add(-time.Hour))
+	assert.NotEqual(t, changed, p.latestVersion())
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/keep94/mailmerge/mailer"
+	"github.com/keep94/mailmerge/merge"
+)
+
+// outgoing is an email ready to send along with the row it is for.
+type outgoing struct {
+	Index int           `json:"index"`
+	Row   merge.CsvRow  `json:"row"`
+	Seed  bool          `json:"seed"`
+	Email *mailer.Email `json:"email"`
+
+	// Where the email is queued if it came from -flush
+	path string
+}
+
+// writeQueue saves emails in dir for -flush to send later without
+// needing the network or credentials now. Each email goes in its own
+// JSON file named after its index along with a copy of its attachments
+// so that dir can be moved to another machine. Attachments that don't
+// exist yet, because the pre send hook makes them, keep their full
+// path. writeQueue refuses to add to a dir that still holds queued
+// emails.
+func writeQueue(dir string, emails []*outgoing) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	queued, err := queuedPaths(dir)
+	if err != nil {
+		return err
+	}
+	if len(queued) > 0 {
+		return fmt.Errorf(
+			"%s already holds queued emails; send them with -flush first", dir)
+	}
+	for _, o := range emails {
+		if err := queueOne(dir, o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func queueOne(dir string, o *outgoing) error {
+	name := fmt.Sprintf("%06d", o.Index)
+	email := *o.Email
+	email.Attachments = nil
+	for i, path := range o.Email.Attachments {
+		if !isFile(path) {
+			// The pre send hook will make it at -flush.
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+			email.Attachments = append(email.Attachments, abs)
+			continue
+		}
+		rel := filepath.Join(name, strconv.Itoa(i), filepath.Base(path))
+		if err := copyFile(filepath.Join(dir, rel), path); err != nil {
+			return err
+		}
+		email.Attachments = append(email.Attachments, filepath.ToSlash(rel))
+	}
+	queued := *o
+	queued.Email = &email
+	content, err := json.MarshalIndent(&queued, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write the JSON file last and all at once so that a half queued
+	// email never looks queued.
+	tempPath := filepath.Join(dir, name+".tmp")
+	if err := os.WriteFile(tempPath, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, filepath.Join(dir, name+".json"))
+}
+
+func copyFile(dest, src string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// readQueue reads the emails in dir that writeQueue saved and that
+// -flush hasn't sent yet in index order.
+func readQueue(dir string) ([]*outgoing, error) {
+	paths, err := queuedPaths(dir)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*outgoing, 0, len(paths))
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var o outgoing
+		if err := json.Unmarshal(content, &o); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if o.Email == nil {
+			return nil, fmt.Errorf("%s: no email", path)
+		}
+		for i, rel := range o.Email.Attachments {
+			if !filepath.IsAbs(rel) {
+				o.Email.Attachments[i] = filepath.Join(
+					dir, filepath.FromSlash(rel))
+			}
+		}
+		o.path = path
+		result = append(result, &o)
+	}
+	return result, nil
+}
+
+// dequeue removes a sent email from its queue.
+func dequeue(o *outgoing) error {
+	if err := os.Remove(o.path); err != nil {
+		return err
+	}
+	return os.RemoveAll(strings.TrimSuffix(o.path, ".json"))
+}
+
+// queuedPaths returns the paths of the JSON files in dir sorted.
+func queuedPaths(dir string) ([]string, error) {
+	result, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(result)
+	return result, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/keep94/mailmerge/mailer"
+	"github.com/keep94/mailmerge/merge"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueue(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pdf")
+	assert.NoError(t, os.WriteFile(certPath, []byte("certificate"), 0600))
+	hookPath := filepath.Join(dir, "later.pdf")
+	queueDir := filepath.Join(dir, "queue")
+	emails := []*outgoing{
+		{
+			Index: 12,
+			Row:   merge.CsvRow{"email": "bob@gmail.com"},
+			Email: &mailer.Email{
+				To:          []string{"bob@gmail.com"},
+				Subject:     "Picnic",
+				Body:        "Hi Bob",
+				Attachments: []string{certPath, hookPath},
+			},
+		},
+		{
+			Index: 3,
+			Row:   merge.CsvRow{"email": "alice@gmail.com"},
+			Email: &mailer.Email{
+				To:      []string{"alice@gmail.com"},
+				Subject: "Picnic",
+				Body:    "Hi Alice",
+			},
+		},
+	}
+	assert.NoError(t, writeQueue(queueDir, emails))
+
+	// The queue keeps its own copy of each attachment.
+	assert.NoError(t, os.Remove(certPath))
+	assert.Error(t, writeQueue(queueDir, emails))
+
+	queued, err := readQueue(queueDir)
+	assert.NoError(t, err)
+	assert.Len(t, queued, 2)
+	assert.Equal(t, 3, queued[0].Index)
+	assert.Equal(t, "Hi Alice", queued[0].Email.Body)
+	assert.Equal(t, 12, queued[1].Index)
+	assert.Equal(t, emails[0].Row, queued[1].Row)
+	attachments := queued[1].Email.Attachments
+	assert.Len(t, attachments, 2)
+	content, err := os.ReadFile(attachments[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "certificate", string(content))
+	assert.Equal(t, hookPath, attachments[1])
+
+	assert.NoError(t, dequeue(queued[1]))
+	assert.NoFileExists(t, attachments[0])
+	queued, err = readQueue(queueDir)
+	assert.NoError(t, err)
+	assert.Len(t, queued, 1)
+	assert.Equal(t, 3, queued[0].Index)
+	assert.NoError(t, dequeue(queued[0]))
+	queued, err = readQueue(queueDir)
+	assert.NoError(t, err)
+	assert.Empty(t, queued)
+}
+
+func TestReadQueueBadEmail(t *testing.T) {
+	dir := t.TempDir()
+	testCases := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"no email", `{"index": 1}`, "no email"},
+		{"bad json", `{"index": `, "unexpected end of JSON input"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, "000001.json")
+			err := os.WriteFile(path, []byte(tc.content), 0600)
+			assert.NoError(t, err)
+			_, err = readQueue(dir)
+			assert.EqualError(t, err, path+": "+tc.want)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/keep94/mailmerge/filelock"
+)
+
+// kResendWindow is how long mailmerge remembers a run.
+const kResendWindow = 7 * 24 * time.Hour
+
+// runHistory catches the same run twice. A run is the same if it has
+// the same template, subject, and recipients. The state file at path,
+// shared by all accounts, remembers when each account sent each run.
+type runHistory struct {
+	path        string
+	account     string
+	fingerprint string
+	now         time.Time
+}
+
+func newRunHistory(
+	path, account, fingerprint string, now time.Time) *runHistory {
+	return &runHistory{
+		path:        path,
+		account:     account,
+		fingerprint: fingerprint,
+		now:         now,
+	}
+}
+
+// LastSent returns when the account last sent this run or the zero time
+// if it hasn't within kResendWindow.
+func (r *runHistory) LastSent() (result time.Time, err error) {
+	err = r.update(func(runs map[string]time.Time) bool {
+		result = runs[r.fingerprint]
+		return false
+	})
+	return
+}
+
+// Record remembers that the account sent this run now.
+func (r *runHistory) Record() error {
+	return r.update(func(runs map[string]time.Time) bool {
+		runs[r.fingerprint] = r.now
+		return true
+	})
+}
+
+// update calls f with the account's runs while holding a lock on the
+// state file. update forgets runs older than kResendWindow and saves
+// the state if f returns true.
+func (r *runHistory) update(f func(runs map[string]time.Time) bool) error {
+	file, err := os.OpenFile(r.path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := filelock.Lock(file); err != nil {
+		return err
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	accounts := make(map[string]map[string]time.Time)
+	if len(content) > 0 {
+		if err := json.Unmarshal(content, &accounts); err != nil {
+			return err
+		}
+	}
+	runs := accounts[r.account]
+	if runs == nil {
+		runs = make(map[string]time.Time)
+		accounts[r.account] = runs
+	}
+	for fingerprint, sent := range runs {
+		if r.now.Sub(sent) > kResendWindow {
+			delete(runs, fingerprint)
+		}
+	}
+	if !f(runs) {
+		return nil
+	}
+	content, err = json.MarshalIndent(accounts, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	_, err = file.WriteAt(append(content, '\n'), 0)
+	return err
+}
+
+// runFingerprint hashes the template files at templatePaths, subject,
+// and the recipients of list ignoring order and case.
+func runFingerprint(
+	templatePaths []string, subject string, list []*outgoing) (
+	string, error) {
+	h := sha256.New()
+	for _, templatePath := range templatePaths {
+		content, err := os.ReadFile(templatePath)
+		if err != nil {
+			return "", err
+		}
+		h.Write(content)
+		h.Write([]byte{0})
+	}
+	h.Write([]byte(subject))
+	h.Write([]byte{0})
+	emails := make([]string, 0, len(list))
+	for _, o := range list {
+		emails = append(
+			emails, strings.ToLower(strings.TrimSpace(o.Row.Email())))
+	}
+	slices.Sort(emails)
+	for _, email := range emails {
+		h.Write([]byte(email))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
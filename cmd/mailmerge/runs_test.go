@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/keep94/mailmerge/merge"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runs.json")
+	sent := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	assert.NoError(t, newRunHistory(path, "me@gmail.com", "abc", sent).Record())
+	testCases := []struct {
+		name        string
+		account     string
+		fingerprint string
+		after       time.Duration
+		want        time.Time
+	}{
+		{"same run", "me@gmail.com", "abc", time.Hour, sent},
+		{"last day", "me@gmail.com", "abc", kResendWindow, sent},
+		{
+			"expired",
+			"me@gmail.com",
+			"abc",
+			kResendWindow + time.Minute,
+			time.Time{},
+		},
+		{"other run", "me@gmail.com", "def", time.Hour, time.Time{}},
+		{"other account", "you@gmail.com", "abc", time.Hour, time.Time{}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			history := newRunHistory(
+				path, tc.account, tc.fingerprint, sent.Add(tc.after))
+			lastSent, err := history.LastSent()
+			assert.NoError(t, err)
+			assert.True(t, tc.want.Equal(lastSent), lastSent)
+		})
+	}
+}
+
+func TestRunHistoryForgets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runs.json")
+	sent := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	assert.NoError(t, newRunHistory(path, "me@gmail.com", "abc", sent).Record())
+	later := sent.Add(kResendWindow + time.Hour)
+	assert.NoError(
+		t, newRunHistory(path, "me@gmail.com", "def", later).Record())
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(content), `"abc"`)
+	assert.Contains(t, string(content), `"def"`)
+}
+
+func TestRunFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "template.txt")
+	assert.NoError(t, os.WriteFile(templatePath, []byte("Hi {{.name}}"), 0600))
+	list := func(emails ...string) []*outgoing {
+		var result []*outgoing
+		for _, email := range emails {
+			result = append(
+				result, &outgoing{Row: merge.CsvRow{"email": email}})
+		}
+		return result
+	}
+	fingerprint := func(subject string, l []*outgoing) string {
+		result, err := runFingerprint([]string{templatePath}, subject, l)
+		assert.NoError(t, err)
+		return result
+	}
+	base := fingerprint("Picnic", list("a@b.com", "c@d.com"))
+	assert.Equal(t, base, fingerprint("Picnic", list("C@d.com ", "a@b.com")))
+	assert.NotEqual(t, base, fingerprint("Picnic!", list("a@b.com", "c@d.com")))
+	assert.NotEqual(t, base, fingerprint("Picnic", list("a@b.com")))
+	assert.NoError(
+		t, os.WriteFile(templatePath, []byte("Hello {{.name}}"), 0600))
+	assert.NotEqual(t, base, fingerprint("Picnic", list("a@b.com", "c@d.com")))
+	_, err := runFingerprint(
+		[]string{filepath.Join(dir, "missing.txt")}, "Picnic", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/keep94/mailmerge/mailer"
+)
+
+// simulatedMailer stands in for the mailer with -dryrun -simulate. It
+// sends nothing but takes as long as sending would and fails some emails
+// so that people can rehearse a run, including -keepgoing and the
+// failure report, before sending for real.
+type simulatedMailer struct {
+	pause     time.Duration
+	delay     time.Duration
+	errorRate float64
+	rand      *rand.Rand
+}
+
+// newSimulatedMailer parses spec, a comma separated list of:
+//
+//	rate: wait sendWaitTime from config between emails like the mailer
+//	delay=DURATION: take this long to send each email e.g delay=200ms
+//	errors=N%: fail N percent of emails e.g errors=2%
+//
+// The same spec fails the same emails each run.
+func newSimulatedMailer(
+	spec string, config *config) (*simulatedMailer, error) {
+	result := &simulatedMailer{rand: rand.New(rand.NewPCG(1, 2))}
+	for _, part := range strings.Split(spec, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		var err error
+		switch name {
+		case "rate":
+			result.pause = config.SendWaitTime
+		case "delay":
+			result.delay, err = time.ParseDuration(value)
+			if err == nil && result.delay < 0 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "errors":
+			var percent float64
+			percent, err = strconv.ParseFloat(
+				strings.TrimSuffix(value, "%"), 64)
+			if err == nil && (percent < 0 || percent > 100) {
+				err = fmt.Errorf("must be from 0%% to 100%%")
+			}
+			result.errorRate = percent / 100
+		default:
+			return nil, fmt.Errorf(
+				"-simulate: %s is not rate, delay, or errors", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("-simulate: %s: %v", name, err)
+		}
+	}
+	return result, nil
+}
+
+func (s *simulatedMailer) SendFuture(email mailer.Email) <-chan error {
+	time.Sleep(s.delay)
+	var err error
+	if s.rand.Float64() < s.errorRate {
+		err = &textproto.Error{
+			Code: 421, Msg: "4.7.0 Try again later (simulated)"}
+	}
+	time.Sleep(s.pause)
+	result := make(chan error, 1)
+	result <- err
+	close(result)
+	return result
+}
+
+func (s *simulatedMailer) Check(from string) error {
+	return nil
+}
+
+func (s *simulatedMailer) Shutdown() {
+}
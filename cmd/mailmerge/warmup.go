@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/keep94/mailmerge/filelock"
+)
+
+const kDateFormat = "2006-01-02"
+
+// warmupAccount is the warm-up state of one sending account.
+type warmupAccount struct {
+
+	// The first day the account sent under warm-up
+	Start string `json:"start"`
+
+	// The number of emails sent each day keyed by date
+	Sent map[string]int `json:"sent"`
+}
+
+// warmup caps how many emails a new account sends each day. The state
+// file at path, shared by all accounts, remembers what each account has
+// sent.
+type warmup struct {
+	path     string
+	account  string
+	schedule []int
+	today    string
+}
+
+func newWarmup(path, account string, schedule []int, now time.Time) *warmup {
+	return &warmup{
+		path:     path,
+		account:  account,
+		schedule: schedule,
+		today:    now.Format(kDateFormat),
+	}
+}
+
+// Remaining returns the number of emails the account may still send
+// today and the day's cap. Remaining returns -1 for both if the warm-up
+// is over.
+func (w *warmup) Remaining() (remaining, limit int, err error) {
+	remaining, limit = -1, -1
+	err = w.update(func(a *warmupAccount) bool {
+		start, err := time.Parse(kDateFormat, a.Start)
+		if err != nil {
+			return false
+		}
+		today, _ := time.Parse(kDateFormat, w.today)
+		day := int(today.Sub(start).Hours() / 24)
+		if day >= len(w.schedule) {
+			return false
+		}
+		limit = w.schedule[day]
+		remaining = max(limit-a.Sent[w.today], 0)
+		return false
+	})
+	return
+}
+
+// Record counts one more email sent today.
+func (w *warmup) Record() error {
+	return w.update(func(a *warmupAccount) bool {
+		a.Sent[w.today]++
+		return true
+	})
+}
+
+// update calls f with the account's state while holding a lock on the
+// state file. update saves the state if f returns true forgetting days
+// further back than the schedule is long since only today's count
+// matters.
+func (w *warmup) update(f func(a *warmupAccount) bool) error {
+	file, err := os.OpenFile(w.path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := filelock.Lock(file); err != nil {
+		return err
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	accounts := make(map[string]*warmupAccount)
+	if len(content) > 0 {
+		if err := json.Unmarshal(content, &accounts); err != nil {
+			return err
+		}
+	}
+	account, ok := accounts[w.account]
+	if !ok {
+		account = &warmupAccount{Start: w.today}
+		accounts[w.account] = account
+	}
+	if account.Sent == nil {
+		account.Sent = make(map[string]int)
+	}
+	if !f(account) && ok {
+		return nil
+	}
+	w.forgetOldDays(account)
+	content, err = json.MarshalIndent(accounts, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	_, err = file.WriteAt(append(content, '\n'), 0)
+	return err
+}
+
+// forgetOldDays removes the counts for days further back than the
+// schedule is long from a.
+func (w *warmup) forgetOldDays(a *warmupAccount) {
+	today, err := time.Parse(kDateFormat, w.today)
+	if err != nil {
+		return
+	}
+	oldest := today.AddDate(0, 0, -len(w.schedule))
+	for date := range a.Sent {
+		day, err := time.Parse(kDateFormat, date)
+		if err == nil && day.Before(oldest) {
+			delete(a.Sent, date)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarmup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "warmup.json")
+	schedule := []int{2, 5}
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name          string
+		account       string
+		day           int
+		record        int
+		wantRemaining int
+		wantLimit     int
+	}{
+		{"first day", "me@gmail.com", 0, 1, 2, 2},
+		{"first day later", "me@gmail.com", 0, 3, 1, 2},
+		{"first day capped", "me@gmail.com", 0, 0, 0, 2},
+		{"second day", "me@gmail.com", 1, 4, 5, 5},
+		{"second day later", "me@gmail.com", 1, 0, 1, 5},
+		{"warm-up over", "me@gmail.com", 2, 0, -1, -1},
+		{"other account", "you@gmail.com", 2, 0, 2, 2},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := newWarmup(
+				path,
+				tc.account,
+				schedule,
+				start.Add(time.Duration(tc.day)*24*time.Hour))
+			remaining, limit, err := w.Remaining()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantRemaining, remaining)
+			assert.Equal(t, tc.wantLimit, limit)
+			for i := 0; i < tc.record; i++ {
+				assert.NoError(t, w.Record())
+			}
+		})
+	}
+}
+
+func TestWarmupForgetsOldDays(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "warmup.json")
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	for day := 0; day < 10; day++ {
+		w := newWarmup(
+			path,
+			"me@gmail.com",
+			[]int{5, 10},
+			start.AddDate(0, 0, day))
+		assert.NoError(t, w.Record())
+	}
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	var accounts map[string]*warmupAccount
+	assert.NoError(t, json.Unmarshal(content, &accounts))
+	assert.Equal(
+		t,
+		&warmupAccount{
+			Start: "2024-03-01",
+			Sent: map[string]int{
+				"2024-03-08": 1, "2024-03-09": 1, "2024-03-10": 1},
+		},
+		accounts["me@gmail.com"])
+}
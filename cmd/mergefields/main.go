@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/keep94/mailmerge/merge"
+	"github.com/keep94/toolbox/build"
+)
+
+var (
+	fCsv     string
+	fVersion bool
+)
+
+// column describes a column that templates may use.
+type column struct {
+	Name string           `json:"name"`
+	Type merge.ColumnType `json:"type"`
+
+	// True for the firstName, lastName, and title columns that mailmerge
+	// adds.
+	Computed bool `json:"computed,omitempty"`
+}
+
+// fields is what mergefields writes.
+type fields struct {
+	Columns   []column             `json:"columns"`
+	Functions []merge.TemplateFunc `json:"functions"`
+}
+
+func main() {
+	flag.Parse()
+	if fVersion {
+		version, _ := build.MainVersion()
+		fmt.Println(build.BuildId(version))
+		return
+	}
+	if fCsv == "" {
+		fmt.Println("-csv flag required.")
+		flag.Usage()
+		os.Exit(2)
+	}
+	csvFile, err := merge.ReadCsv(fCsv)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(describe(csvFile)); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// describe returns the columns of csvFile along with the columns that
+// mailmerge computes and the template functions.
+func describe(csvFile *merge.CsvFile) *fields {
+	result := &fields{Functions: merge.TemplateFuncs()}
+	for _, c := range merge.InferSchema(csvFile).Columns {
+		result.Columns = append(
+			result.Columns, column{Name: c.Name, Type: c.Type})
+	}
+	computed := []string{merge.FirstName, merge.LastName, merge.Title}
+	for _, name := range computed {
+		if !slices.Contains(csvFile.Headers, name) {
+			result.Columns = append(
+				result.Columns,
+				column{Name: name, Type: merge.TextColumn, Computed: true})
+		}
+	}
+	return result
+}
+
+func init() {
+	flag.StringVar(&fCsv, "csv", "", "Path to CSV file")
+	flag.BoolVar(&fVersion, "version", false, "Show version")
+}
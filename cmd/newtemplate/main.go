@@ -0,0 +1,134 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/keep94/toolbox/build"
+)
+
+var (
+	fKind    string
+	fDir     string
+	fList    bool
+	fExtract string
+	fVersion bool
+)
+
+var kKinds = []string{"invite", "newsletter", "reminder"}
+
+//go:embed templates
+var kTemplates embed.FS
+
+func main() {
+	flag.Parse()
+	if fVersion {
+		version, _ := build.MainVersion()
+		fmt.Println(build.BuildId(version))
+		return
+	}
+	if fList {
+		if err := list(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	if fExtract != "" {
+		names := strings.Split(fExtract, ",")
+		for i := range names {
+			names[i] = strings.TrimSpace(names[i])
+		}
+		if err := scaffold(fDir, names); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for _, name := range names {
+			fmt.Println("Created", filepath.Join(fDir, name))
+		}
+		return
+	}
+	if !slices.Contains(kKinds, fKind) {
+		fmt.Println("-kind must be invite, newsletter, or reminder.")
+		flag.Usage()
+		os.Exit(2)
+	}
+	names := []string{fKind + ".txt", fKind + ".html", fKind + ".csv"}
+	if err := scaffold(fDir, names); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	for _, name := range names {
+		fmt.Println("Created", filepath.Join(fDir, name))
+	}
+	fmt.Println("Try it with:")
+	fmt.Printf(
+		"mailmerge -template %s -csv %s -subject %q -dryrun\n",
+		filepath.Join(fDir, names[0]),
+		filepath.Join(fDir, names[2]),
+		fKind)
+}
+
+// list prints the names of the embedded files.
+func list() error {
+	entries, err := kTemplates.ReadDir("templates")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		fmt.Println(entry.Name())
+	}
+	return nil
+}
+
+// scaffold copies the embedded files called names to dir. scaffold
+// writes nothing if any of them are not embedded or already exist in
+// dir.
+func scaffold(dir string, names []string) error {
+	for _, name := range names {
+		if _, err := fs.Stat(kTemplates, "templates/"+name); err != nil {
+			return fmt.Errorf(
+				"%s is not a built in file; -list shows them", name)
+		}
+		_, err := os.Stat(filepath.Join(dir, name))
+		if err == nil {
+			return fmt.Errorf("%s already exists", filepath.Join(dir, name))
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, name := range names {
+		content, err := kTemplates.ReadFile("templates/" + name)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(filepath.Join(dir, name), content, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	flag.StringVar(&fKind, "kind", "", "invite, newsletter, or reminder")
+	flag.StringVar(&fDir, "dir", ".", "Directory to create files in")
+	flag.BoolVar(&fList, "list", false, "List the built in files")
+	flag.StringVar(
+		&fExtract,
+		"extract",
+		"",
+		"Comma separated built in files to copy e.g mailmerge.yaml")
+	flag.BoolVar(&fVersion, "version", false, "Show version")
+}
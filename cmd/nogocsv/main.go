@@ -3,8 +3,11 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"slices"
 
+	"github.com/keep94/mailmerge/filelock"
 	"github.com/keep94/mailmerge/merge"
 	"github.com/keep94/toolbox/build"
 )
@@ -13,6 +16,11 @@ var (
 	fCsv     string
 	fNoGo    string
 	fVersion bool
+	fExcel   bool
+	fCRLF    bool
+	fQuote   bool
+	fBOM     bool
+	fLowMem  bool
 )
 
 func main() {
@@ -27,19 +35,127 @@ func main() {
 		flag.Usage()
 		os.Exit(2)
 	}
+	if err := checkDistinct(fCsv, fNoGo); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	var options []merge.WriteOption
+	if fCRLF || fExcel {
+		options = append(options, merge.CRLF())
+	}
+	if fQuote || fExcel {
+		options = append(options, merge.AlwaysQuote())
+	}
+	if fBOM || fExcel {
+		options = append(options, merge.BOM())
+	}
+	if fLowMem {
+		if err := streamNoGo(fCsv, fNoGo, options...); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
 	csvFile, err := merge.ReadCsv(fCsv)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
-	if err := csvFile.SelectGoing().WithNotGoing().Write(fNoGo); err != nil {
+	nogo := csvFile.SelectGoing().WithNotGoing()
+	if err := nogo.Write(fNoGo, options...); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
 
+// checkDistinct returns an error if nogoPath is the same file as
+// csvPath. Writing the nogo file would otherwise wipe out the source, or
+// with -lowmem, wait forever on the lock nogocsv itself holds on it.
+func checkDistinct(csvPath, nogoPath string) error {
+	csvInfo, err := os.Stat(csvPath)
+	if err != nil {
+		return err
+	}
+	nogoInfo, err := os.Stat(nogoPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if os.SameFile(csvInfo, nogoInfo) {
+		return fmt.Errorf(
+			"-nogo %s is the same file as -csv %s", nogoPath, csvPath)
+	}
+	return nil
+}
+
+// streamNoGo writes the nogo file one row at a time so that memory use
+// stays the same no matter how big the source file is.
+func streamNoGo(
+	csvPath, nogoPath string, options ...merge.WriteOption) error {
+	in, err := os.Open(csvPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := filelock.RLock(in); err != nil {
+		return err
+	}
+	reader, err := merge.NewCsvReader(in)
+	if err != nil {
+		return err
+	}
+	headers := reader.Headers()
+	if !slices.Contains(headers, merge.Going) {
+		headers = append(slices.Clone(headers), merge.Going)
+	}
+	out, err := os.OpenFile(nogoPath, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := filelock.Lock(out); err != nil {
+		return err
+	}
+	if err := out.Truncate(0); err != nil {
+		return err
+	}
+	writer, err := merge.NewCsvWriter(out, headers, options...)
+	if err != nil {
+		return err
+	}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if row.Going() {
+			if err := writer.Write(row.WithNotGoing()); err != nil {
+				return err
+			}
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
 func init() {
 	flag.StringVar(&fCsv, "csv", "", "Path to source CSV file")
 	flag.StringVar(&fNoGo, "nogo", "", "Path to nogo CSV file being created")
 	flag.BoolVar(&fVersion, "version", false, "Show version")
+	flag.BoolVar(&fExcel, "excel", false, "Same as -crlf -quote -bom")
+	flag.BoolVar(&fCRLF, "crlf", false, "End lines with CRLF")
+	flag.BoolVar(&fQuote, "quote", false, "Quote every value")
+	flag.BoolVar(&fBOM, "bom", false, "Start with a UTF-8 byte order mark")
+	flag.BoolVar(
+		&fLowMem,
+		"lowmem",
+		false,
+		"Process one row at a time for files too big for memory")
 }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/keep94/mailmerge/smtpdev"
+	"github.com/keep94/toolbox/build"
+)
+
+var (
+	fSmtp    string
+	fHttp    string
+	fVersion bool
+)
+
+func main() {
+	flag.Parse()
+	if fVersion {
+		version, _ := build.MainVersion()
+		fmt.Println(build.BuildId(version))
+		return
+	}
+	server := smtpdev.NewServer()
+	listener, err := net.Listen("tcp", fSmtp)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	go func() {
+		fmt.Println(server.Serve(listener))
+		os.Exit(1)
+	}()
+	fmt.Printf("SMTP on %s, viewer on http://%s\n", fSmtp, fHttp)
+	if err := http.ListenAndServe(fHttp, server); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	flag.StringVar(&fSmtp, "smtp", "localhost:2525", "SMTP listen address")
+	flag.StringVar(&fHttp, "http", "localhost:8025", "Web viewer address")
+	flag.BoolVar(&fVersion, "version", false, "Show version")
+}
@@ -0,0 +1,33 @@
+// Package filelock provides advisory locks on open files so that two
+// mailmerge commands running at once don't corrupt a file they share.
+// Locks are advisory: they only keep out processes that also lock.
+package filelock
+
+import (
+	"os"
+)
+
+// Lock blocks until it gets an exclusive lock on f. Use Lock before
+// writing to f.
+func Lock(f *os.File) error {
+	return lock(f, true)
+}
+
+// RLock blocks until it gets a shared lock on f. Any number of processes
+// may hold a shared lock at once, but not while one holds an exclusive
+// lock. Use RLock before reading f.
+func RLock(f *os.File) error {
+	return lock(f, false)
+}
+
+// Unlock releases the lock on f. Closing f also releases the lock.
+func Unlock(f *os.File) error {
+	return unlock(f)
+}
+
+func wrap(op string, f *os.File, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &os.PathError{Op: op, Path: f.Name(), Err: err}
+}
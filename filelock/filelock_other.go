@@ -0,0 +1,17 @@
+//go:build !unix && !windows
+
+package filelock
+
+import (
+	"os"
+)
+
+// Platforms without file locks get no protection.
+
+func lock(f *os.File, exclusive bool) error {
+	return nil
+}
+
+func unlock(f *os.File) error {
+	return nil
+}
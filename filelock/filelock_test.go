@@ -0,0 +1,56 @@
+//go:build unix || windows
+
+package filelock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockExcludes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	first := create(t, path)
+	defer first.Close()
+	second := create(t, path)
+	defer second.Close()
+	assert.NoError(t, Lock(first))
+	locked := make(chan struct{})
+	go func() {
+		assert.NoError(t, RLock(second))
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("Got shared lock while exclusive lock held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.NoError(t, Unlock(first))
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Never got shared lock")
+	}
+	assert.NoError(t, Unlock(second))
+}
+
+func TestSharedLocks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	first := create(t, path)
+	defer first.Close()
+	second := create(t, path)
+	defer second.Close()
+	assert.NoError(t, RLock(first))
+	assert.NoError(t, RLock(second))
+}
+
+func create(t *testing.T, path string) *os.File {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
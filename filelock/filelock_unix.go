@@ -0,0 +1,25 @@
+//go:build unix
+
+package filelock
+
+import (
+	"os"
+	"syscall"
+)
+
+func lock(f *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	for {
+		err := syscall.Flock(int(f.Fd()), how)
+		if err != syscall.EINTR {
+			return wrap("lock", f, err)
+		}
+	}
+}
+
+func unlock(f *os.File) error {
+	return wrap("unlock", f, syscall.Flock(int(f.Fd()), syscall.LOCK_UN))
+}
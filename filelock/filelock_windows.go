@@ -0,0 +1,53 @@
+//go:build windows
+
+package filelock
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	kLockfileExclusiveLock = 2
+	kAllBytes              = ^uint32(0)
+)
+
+var (
+	kKernel32       = syscall.NewLazyDLL("kernel32.dll")
+	kProcLockFileEx = kKernel32.NewProc("LockFileEx")
+	kProcUnlockFile = kKernel32.NewProc("UnlockFileEx")
+)
+
+func lock(f *os.File, exclusive bool) error {
+	var flags uintptr
+	if exclusive {
+		flags = kLockfileExclusiveLock
+	}
+	var overlapped syscall.Overlapped
+	r, _, err := kProcLockFileEx.Call(
+		f.Fd(),
+		flags,
+		0,
+		uintptr(kAllBytes),
+		uintptr(kAllBytes),
+		uintptr(unsafe.Pointer(&overlapped)))
+	if r == 0 {
+		return wrap("lock", f, err)
+	}
+	return nil
+}
+
+func unlock(f *os.File) error {
+	var overlapped syscall.Overlapped
+	r, _, err := kProcUnlockFile.Call(
+		f.Fd(),
+		0,
+		uintptr(kAllBytes),
+		uintptr(kAllBytes),
+		uintptr(unsafe.Pointer(&overlapped)))
+	if r == 0 {
+		return wrap("unlock", f, err)
+	}
+	return nil
+}
@@ -7,9 +7,11 @@ require gopkg.in/yaml.v3 v3.0.1
 require (
 	github.com/keep94/toolbox v0.14.0
 	github.com/stretchr/testify v1.7.0
+	golang.org/x/net v0.38.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/text v0.23.0 // indirect
 )
@@ -0,0 +1,105 @@
+package mailer
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// ASCIIDomain returns addr with its domain converted to its ASCII
+// (punycode) form, e.g. "bob@bücher.example" becomes
+// "bob@xn--bcher-kva.example". ASCIIDomain leaves the local part alone
+// and returns an error if addr is not of the form local@domain or if the
+// domain is not a valid internationalized domain name.
+func ASCIIDomain(addr string) (string, error) {
+	at := strings.LastIndex(addr, "@")
+	if at <= 0 || at == len(addr)-1 {
+		return "", fmt.Errorf("%s: invalid email address", addr)
+	}
+	domain, err := idna.Lookup.ToASCII(addr[at+1:])
+	if err != nil {
+		return "", fmt.Errorf("%s: invalid domain: %v", addr, err)
+	}
+	return addr[:at+1] + domain, nil
+}
+
+// FormatAddress returns addr with name as its display name in a form
+// suitable for Email.To, e.g "\"Bob Smith\" <bob@example.com>". name may
+// contain any UTF-8. If name is empty, FormatAddress returns addr.
+func FormatAddress(name, addr string) string {
+	if name == "" {
+		return addr
+	}
+	quoted := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name)
+	return fmt.Sprintf("\"%s\" <%s>", quoted, addr)
+}
+
+// NeedsSMTPUTF8 returns true if addr has a non-ASCII local part. Such
+// addresses can only be sent to through servers supporting SMTPUTF8.
+func NeedsSMTPUTF8(addr string) bool {
+	at := strings.LastIndex(addr, "@")
+	if at < 0 {
+		at = len(addr)
+	}
+	return !isASCII(addr[:at])
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// parseAddress parses addr which may include a display name, e.g.
+// "Bob <bob@example.com>" and converts its domain to ASCII.
+func parseAddress(addr string) (*mail.Address, error) {
+	result, err := mail.ParseAddress(addr)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", addr, err)
+	}
+	result.Address, err = ASCIIDomain(result.Address)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// envelope holds the SMTP envelope for one email along with the sender
+// and recipients as they go in the message headers.
+type envelope struct {
+	From       string
+	To         []string
+	SMTPUTF8   bool
+	FromHeader *mail.Address
+	ToHeader   []*mail.Address
+}
+
+func newEnvelope(from string, to []string) (*envelope, error) {
+	fromAddr, err := parseAddress(from)
+	if err != nil {
+		return nil, err
+	}
+	result := &envelope{
+		From:       fromAddr.Address,
+		To:         make([]string, 0, len(to)),
+		SMTPUTF8:   NeedsSMTPUTF8(fromAddr.Address),
+		FromHeader: fromAddr,
+		ToHeader:   make([]*mail.Address, 0, len(to)),
+	}
+	for _, addr := range to {
+		toAddr, err := parseAddress(addr)
+		if err != nil {
+			return nil, err
+		}
+		result.To = append(result.To, toAddr.Address)
+		result.ToHeader = append(result.ToHeader, toAddr)
+		result.SMTPUTF8 = result.SMTPUTF8 || NeedsSMTPUTF8(toAddr.Address)
+	}
+	return result, nil
+}
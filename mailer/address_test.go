@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestASCIIDomain(t *testing.T) {
+	addr, err := ASCIIDomain("bob@bücher.example")
+	assert.NoError(t, err)
+	assert.Equal(t, "bob@xn--bcher-kva.example", addr)
+	addr, err = ASCIIDomain("björn@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "björn@example.com", addr)
+	addr, err = ASCIIDomain("alice@gmail.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice@gmail.com", addr)
+	_, err = ASCIIDomain("alice")
+	assert.Error(t, err)
+	_, err = ASCIIDomain("alice@")
+	assert.Error(t, err)
+	_, err = ASCIIDomain("alice@exa mple.com")
+	assert.Error(t, err)
+}
+
+func TestNeedsSMTPUTF8(t *testing.T) {
+	assert.False(t, NeedsSMTPUTF8("bob@bücher.example"))
+	assert.True(t, NeedsSMTPUTF8("björn@example.com"))
+	assert.False(t, NeedsSMTPUTF8("alice@gmail.com"))
+}
+
+func TestFormatAddress(t *testing.T) {
+	assert.Equal(t, "bob@gmail.com", FormatAddress("", "bob@gmail.com"))
+	addr := FormatAddress(`Smith, "Bob" José`, "bob@gmail.com")
+	assert.Equal(t, `"Smith, \"Bob\" José" <bob@gmail.com>`, addr)
+	parsed, err := parseAddress(addr)
+	assert.NoError(t, err)
+	assert.Equal(t, `Smith, "Bob" José`, parsed.Name)
+	assert.Equal(t, "bob@gmail.com", parsed.Address)
+}
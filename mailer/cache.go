@@ -0,0 +1,62 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// kMaxCachedBytes is how many bytes of encoded attachments to keep.
+const kMaxCachedBytes = 100 << 20
+
+var kAttachmentCache = &attachmentCache{
+	maxBytes: kMaxCachedBytes, parts: make(map[attachmentKey][]byte)}
+
+// attachmentKey identifies a version of a file.
+type attachmentKey struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// attachmentCache holds encoded attachment parts so that a file sent to
+// many people gets read and encoded once. A file that changes gets
+// encoded again. Once the cache holds maxBytes, it stops adding parts.
+type attachmentCache struct {
+	maxBytes int
+	mu       sync.Mutex
+	parts    map[attachmentKey][]byte
+	size     int
+}
+
+// Get returns the encoded attachment part for the file at path.
+func (c *attachmentCache) Get(path string) ([]byte, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, err
+	}
+	key := attachmentKey{
+		Path: absPath, Size: info.Size(), ModTime: info.ModTime()}
+	c.mu.Lock()
+	part, ok := c.parts[key]
+	c.mu.Unlock()
+	if ok {
+		return part, nil
+	}
+	part, err = encodeAttachment(path)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size+len(part) <= c.maxBytes {
+		c.parts[key] = part
+		c.size += len(part)
+	}
+	return part, nil
+}
@@ -0,0 +1,45 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachmentCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ticket.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("Admit one"), 0644))
+	cache := &attachmentCache{
+		maxBytes: 1000, parts: make(map[attachmentKey][]byte)}
+	first, err := cache.Get(path)
+	assert.NoError(t, err)
+	expected, err := encodeAttachment(path)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, first)
+	assert.Len(t, cache.parts, 1)
+	second, err := cache.Get(path)
+	assert.NoError(t, err)
+	assert.Same(t, &first[0], &second[0])
+
+	assert.NoError(t, os.WriteFile(path, []byte("Admit two"), 0644))
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(path, later, later))
+	third, err := cache.Get(path)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, third)
+	assert.Contains(t, string(third), "QWRtaXQgdHdv")
+}
+
+func TestAttachmentCacheFull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ticket.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("Admit one"), 0644))
+	cache := &attachmentCache{
+		maxBytes: 10, parts: make(map[attachmentKey][]byte)}
+	part, err := cache.Get(path)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, part)
+	assert.Empty(t, cache.parts)
+}
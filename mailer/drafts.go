@@ -0,0 +1,100 @@
+package mailer
+
+import (
+	"log"
+)
+
+// Drafter saves emails as drafts in a mailbox over IMAP instead of
+// sending them so that people can review each one and send it from
+// their own mail program. Drafter accepts the same options as Mailer
+// but uses only BufferSize, ImapServer, Mailbox, and Logger.
+type Drafter struct {
+	emailCh chan *emailJob
+	emailId string
+	mailbox string
+	session *imapSession
+	done    chan struct{}
+}
+
+// NewDrafter creates a new Drafter. emailId and password are the
+// sender address and the IMAP credentials.
+func NewDrafter(emailId, password string, options ...Option) *Drafter {
+	settings := newMailerSettings(options)
+	var emailCh chan *emailJob
+	if settings.BufferSize > 0 {
+		emailCh = make(chan *emailJob, settings.BufferSize)
+	} else {
+		emailCh = make(chan *emailJob)
+	}
+	result := &Drafter{
+		emailCh: emailCh,
+		emailId: emailId,
+		mailbox: settings.Mailbox,
+		session: newImapSession(&settings, emailId, password),
+		done:    make(chan struct{}),
+	}
+	go result.loop()
+	return result
+}
+
+// Send saves one email asynchronously returning immediately. When it
+// eventually saves the email, it reports any errors to stderr.
+func (d *Drafter) Send(email Email) {
+	responseCh := d.SendFuture(email)
+	go func() {
+		if err := <-responseCh; err != nil {
+			log.Println(err)
+		}
+	}()
+}
+
+// SendFuture saves one email asynchronously returning immediately.
+// Caller must use returned channel to get the result.
+func (d *Drafter) SendFuture(email Email) <-chan error {
+	emailJob := &emailJob{Email: email, Response: make(chan error, 1)}
+	d.emailCh <- emailJob
+	return emailJob.Response
+}
+
+// Check logs in to the IMAP server to make sure that this Drafter can
+// save drafts. from is unused as saving drafts doesn't involve the
+// sender. Check waits for emails already queued.
+func (d *Drafter) Check(from string) error {
+	emailJob := &emailJob{Check: true, Response: make(chan error, 1)}
+	d.emailCh <- emailJob
+	return <-emailJob.Response
+}
+
+// Shutdown waits for pending emails to be saved and then logs out. It
+// is an error to call Send or SendFuture after calling Shutdown.
+func (d *Drafter) Shutdown() {
+	close(d.emailCh)
+	<-d.done
+}
+
+func (d *Drafter) loop() {
+	for emailJob := range d.emailCh {
+		if emailJob.Check {
+			emailJob.SetResponse(d.session.Check())
+			continue
+		}
+		emailJob.SetResponse(d.save(&emailJob.Email))
+	}
+	d.session.Close()
+	close(d.done)
+}
+
+func (d *Drafter) save(email *Email) error {
+	env, msg, err := email.build(d.emailId)
+	if err != nil {
+		return err
+	}
+	err = d.session.Append(d.mailbox, `\Draft`, msg)
+	if err != nil {
+		d.session.logger.Info(
+			"saving draft failed", "to", env.To, "bytes", len(msg), "err", err)
+	} else {
+		d.session.logger.Info("saved draft", "to", env.To, "bytes", len(msg))
+	}
+	return err
+}
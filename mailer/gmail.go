@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	kDefaultGmailApiUrl = "https://gmail.googleapis.com"
+	kDefaultGoogleToken = "https://oauth2.googleapis.com/token"
+)
+
+// NewGmailApi returns a Mailer that sends through the Gmail REST API
+// instead of SMTP, so no app password is needed and sent emails land in
+// the account's own Sent folder. emailId is the sender address.
+// NewGmailApi accepts the same options as NewWithOptions but ignores
+// Server, NoopInterval, MaxEmailsPerSession, and CopyToMailbox. Errors
+// from the Gmail API are *ApiError.
+func NewGmailApi(
+	emailId string, credentials OAuthCredentials, options ...Option) *Mailer {
+	settings := newMailerSettings(options)
+	client := &http.Client{}
+	result := newMailer(emailId, &restApi{
+		name:        "gmail api",
+		url:         settings.GmailApiUrl + "/gmail/v1/users/me/messages/send",
+		contentType: "application/json",
+		encode:      encodeGmail,
+		token: &oauthToken{
+			credentials: credentials,
+			tokenUrl:    settings.GoogleTokenUrl,
+			client:      client,
+		},
+		client: client,
+		logger: settings.Logger,
+	}, &settings)
+	go result.loop()
+	return result
+}
+
+// encodeGmail encodes msg for the users.messages.send method.
+func encodeGmail(msg []byte) ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"raw": base64.URLEncoding.EncodeToString(msg),
+	})
+}
@@ -0,0 +1,186 @@
+package mailer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGmailApi(t *testing.T) {
+	server := newFakeGmailServer()
+	defer server.Close()
+	m := newTestGmailApi(server, "refresh")
+	for _, to := range []string{"bob@example.com", "carol@example.com"} {
+		assert.NoError(t, <-m.SendFuture(Email{
+			To: []string{to}, Subject: "Hello", Body: "Hi"}))
+	}
+	m.Shutdown()
+	assert.Equal(t, 1, server.Refreshes())
+	sent := server.Sent()
+	assert.Len(t, sent, 2)
+	assert.Contains(t, sent[1], "To: <carol@example.com>\r\n")
+	assert.Contains(t, sent[1], "From: <alice@example.com>\r\n")
+}
+
+func TestGmailApiExpiredToken(t *testing.T) {
+	server := newFakeGmailServer()
+	defer server.Close()
+	m := newTestGmailApi(server, "refresh")
+	assert.NoError(t, <-m.SendFuture(Email{
+		To: []string{"bob@example.com"}, Subject: "Hello", Body: "Hi"}))
+	server.ExpireTokens()
+	assert.NoError(t, <-m.SendFuture(Email{
+		To: []string{"carol@example.com"}, Subject: "Hello", Body: "Hi"}))
+	m.Shutdown()
+	assert.Equal(t, 2, server.Refreshes())
+	assert.Len(t, server.Sent(), 2)
+}
+
+func TestGmailApiBadRefreshToken(t *testing.T) {
+	server := newFakeGmailServer()
+	defer server.Close()
+	m := newTestGmailApi(server, "revoked")
+	err := <-m.SendFuture(Email{
+		To: []string{"bob@example.com"}, Subject: "Hello", Body: "Hi"})
+	m.Shutdown()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid_grant")
+	assert.Empty(t, server.Sent())
+}
+
+func TestGmailApiCheck(t *testing.T) {
+	server := newFakeGmailServer()
+	defer server.Close()
+	m := newTestGmailApi(server, "refresh")
+	assert.NoError(t, m.Check(""))
+	m.Shutdown()
+	revoked := newTestGmailApi(server, "revoked")
+	assert.Error(t, revoked.Check(""))
+	revoked.Shutdown()
+	assert.Equal(t, 1, server.Refreshes())
+	assert.Empty(t, server.Sent())
+}
+
+func TestGmailApiError(t *testing.T) {
+	server := newFakeGmailServer()
+	defer server.Close()
+	m := newTestGmailApi(server, "refresh")
+	err := <-m.SendFuture(Email{
+		To: []string{"full@example.com"}, Subject: "Hello", Body: "Hi"})
+	m.Shutdown()
+	assert.Equal(
+		t,
+		&ApiError{
+			Api: "gmail api", StatusCode: 429, Message: "Rate limit exceeded"},
+		err)
+}
+
+func newTestGmailApi(server *fakeGmailServer, refreshToken string) *Mailer {
+	return NewGmailApi(
+		"alice@example.com",
+		OAuthCredentials{
+			ClientId:     "id",
+			ClientSecret: "secret",
+			RefreshToken: refreshToken,
+		},
+		SendWaitTime(0),
+		optionFunc(func(m *mailerSettings) {
+			m.GmailApiUrl = server.URL
+			m.GoogleTokenUrl = server.URL + "/token"
+		}))
+}
+
+// fakeGmailServer fakes the Google token endpoint and the Gmail send
+// method. It accepts only the refresh token "refresh".
+type fakeGmailServer struct {
+	*httptest.Server
+	mu        sync.Mutex
+	refreshes int
+	valid     string
+	sent      []string
+}
+
+func newFakeGmailServer() *fakeGmailServer {
+	result := &fakeGmailServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", result.handleToken)
+	mux.HandleFunc(
+		"POST /gmail/v1/users/me/messages/send", result.handleSend)
+	result.Server = httptest.NewServer(mux)
+	return result
+}
+
+func (f *fakeGmailServer) Refreshes() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.refreshes
+}
+
+func (f *fakeGmailServer) Sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.sent
+}
+
+// ExpireTokens makes the server reject the access tokens it already
+// handed out.
+func (f *fakeGmailServer) ExpireTokens() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.valid = ""
+}
+
+func (f *fakeGmailServer) handleToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.FormValue("grant_type") != "refresh_token" ||
+		r.FormValue("refresh_token") != "refresh" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(
+			w,
+			`{"error": "invalid_grant", `+
+				`"error_description": "Token has been expired or revoked."}`)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.refreshes++
+	f.valid = fmt.Sprintf("access%d", f.refreshes)
+	fmt.Fprintf(w, `{"access_token": %q, "expires_in": 3599}`, f.valid)
+}
+
+func (f *fakeGmailServer) handleSend(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if f.valid == "" || r.Header.Get("Authorization") != "Bearer "+f.valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(
+			w, `{"error": {"code": 401, "message": "Invalid Credentials"}}`)
+		return
+	}
+	var body struct {
+		Raw string `json:"raw"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	msg, err := base64.URLEncoding.DecodeString(body.Raw)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": {"code": 400, "message": "Bad raw"}}`)
+		return
+	}
+	if strings.Contains(string(msg), "full@example.com") {
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(
+			w, `{"error": {"code": 429, "message": "Rate limit exceeded"}}`)
+		return
+	}
+	f.sent = append(f.sent, string(msg))
+	fmt.Fprint(w, `{"id": "1", "threadId": "1", "labelIds": ["SENT"]}`)
+}
@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+)
+
+const (
+	kDefaultGraphApiUrl       = "https://graph.microsoft.com"
+	kDefaultMicrosoftLoginUrl = "https://login.microsoftonline.com"
+)
+
+// NewGraphApi returns a Mailer that sends through the sendMail method
+// of Microsoft Graph for Microsoft 365 accounts where SMTP AUTH is
+// turned off. emailId is the sender address; tenantId is the
+// directory (tenant) ID of the organization. If credentials has a
+// refresh token, the Mailer sends as the user who granted it the
+// Mail.Send permission. Otherwise, the Mailer signs in as the app
+// itself, which needs the Mail.Send application permission, and sends
+// as emailId. NewGraphApi accepts the same options as NewWithOptions
+// but ignores Server, NoopInterval, MaxEmailsPerSession, and
+// CopyToMailbox. Errors from Microsoft Graph are *ApiError.
+func NewGraphApi(
+	emailId, tenantId string,
+	credentials OAuthCredentials,
+	options ...Option) *Mailer {
+	settings := newMailerSettings(options)
+	sendUrl := settings.GraphApiUrl + "/v1.0/me/sendMail"
+	scope := "https://graph.microsoft.com/Mail.Send offline_access"
+	if credentials.RefreshToken == "" {
+		sendUrl = settings.GraphApiUrl + "/v1.0/users/" +
+			url.PathEscape(emailId) + "/sendMail"
+		scope = "https://graph.microsoft.com/.default"
+	}
+	client := &http.Client{}
+	result := newMailer(emailId, &restApi{
+		name:        "graph api",
+		url:         sendUrl,
+		contentType: "text/plain",
+		encode:      encodeGraph,
+		token: &oauthToken{
+			credentials: credentials,
+			tokenUrl: settings.MicrosoftLoginUrl + "/" +
+				url.PathEscape(tenantId) + "/oauth2/v2.0/token",
+			scope:  scope,
+			client: client,
+		},
+		client: client,
+		logger: settings.Logger,
+	}, &settings)
+	go result.loop()
+	return result
+}
+
+// encodeGraph encodes msg for sendMail which takes MIME messages in
+// base64.
+func encodeGraph(msg []byte) ([]byte, error) {
+	return []byte(base64.StdEncoding.EncodeToString(msg)), nil
+}
@@ -0,0 +1,160 @@
+package mailer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphApiDelegated(t *testing.T) {
+	server := newFakeGraphServer()
+	defer server.Close()
+	m := newTestGraphApi(server, "refresh")
+	assert.NoError(t, <-m.SendFuture(Email{
+		To: []string{"bob@example.com"}, Subject: "Hello", Body: "Hi"}))
+	m.Shutdown()
+	assert.Equal(
+		t,
+		[]string{"refresh_token https://graph.microsoft.com/Mail.Send " +
+			"offline_access"},
+		server.Grants())
+	sent := server.Sent()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "/v1.0/me/sendMail", sent[0].Path)
+	assert.Contains(t, sent[0].Message, "To: <bob@example.com>\r\n")
+}
+
+func TestGraphApiClientCredentials(t *testing.T) {
+	server := newFakeGraphServer()
+	defer server.Close()
+	m := newTestGraphApi(server, "")
+	assert.NoError(t, <-m.SendFuture(Email{
+		To: []string{"bob@example.com"}, Subject: "Hello", Body: "Hi"}))
+	m.Shutdown()
+	assert.Equal(
+		t,
+		[]string{"client_credentials https://graph.microsoft.com/.default"},
+		server.Grants())
+	sent := server.Sent()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "/v1.0/users/alice@example.com/sendMail", sent[0].Path)
+}
+
+func TestGraphApiError(t *testing.T) {
+	server := newFakeGraphServer()
+	defer server.Close()
+	m := newTestGraphApi(server, "refresh")
+	server.Forbid()
+	err := <-m.SendFuture(Email{
+		To: []string{"bob@example.com"}, Subject: "Hello", Body: "Hi"})
+	m.Shutdown()
+	assert.Equal(
+		t,
+		&ApiError{
+			Api:        "graph api",
+			StatusCode: 403,
+			Message:    "Access is denied."},
+		err)
+}
+
+func newTestGraphApi(server *fakeGraphServer, refreshToken string) *Mailer {
+	return NewGraphApi(
+		"alice@example.com",
+		"contoso",
+		OAuthCredentials{
+			ClientId:     "id",
+			ClientSecret: "secret",
+			RefreshToken: refreshToken,
+		},
+		SendWaitTime(0),
+		optionFunc(func(m *mailerSettings) {
+			m.GraphApiUrl = server.URL
+			m.MicrosoftLoginUrl = server.URL
+		}))
+}
+
+type graphMessage struct {
+	Path    string
+	Message string
+}
+
+// fakeGraphServer fakes the Microsoft token endpoint of the contoso
+// tenant and the sendMail method of Microsoft Graph.
+type fakeGraphServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	grants []string
+	forbid bool
+	sent   []graphMessage
+}
+
+func newFakeGraphServer() *fakeGraphServer {
+	result := &fakeGraphServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /contoso/oauth2/v2.0/token", result.handleToken)
+	mux.HandleFunc("POST /v1.0/me/sendMail", result.handleSend)
+	mux.HandleFunc("POST /v1.0/users/{id}/sendMail", result.handleSend)
+	result.Server = httptest.NewServer(mux)
+	return result
+}
+
+func (f *fakeGraphServer) Grants() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.grants
+}
+
+func (f *fakeGraphServer) Sent() []graphMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.sent
+}
+
+// Forbid makes the server refuse to send.
+func (f *fakeGraphServer) Forbid() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.forbid = true
+}
+
+func (f *fakeGraphServer) handleToken(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.grants = append(
+		f.grants, r.FormValue("grant_type")+" "+r.FormValue("scope"))
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, `{"access_token": "access", "expires_in": 3599}`)
+}
+
+func (f *fakeGraphServer) handleSend(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer access" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if f.forbid {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(
+			w,
+			`{"error": {"code": "ErrorAccessDenied", `+
+				`"message": "Access is denied."}}`)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	msg, err := base64.StdEncoding.DecodeString(string(body))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.sent = append(
+		f.sent, graphMessage{Path: r.URL.Path, Message: string(msg)})
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,237 @@
+package mailer
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	kDefaultImapHost = "imap.gmail.com"
+	kDefaultImapPort = 993
+)
+
+// ImapError is a NO or BAD response from an IMAP server.
+type ImapError struct {
+
+	// The command that failed e.g APPEND
+	Command string
+
+	// The server's response without the tag
+	Response string
+}
+
+func (e *ImapError) Error() string {
+	return fmt.Sprintf("imap: %s: %s", e.Command, e.Response)
+}
+
+// imapSession is an IMAP session that logs in when first used and
+// logs in again after a connection error. imapSession only does what
+// mailmerge needs, namely appending messages to a mailbox.
+type imapSession struct {
+	host     string
+	addr     string
+	emailId  string
+	password string
+	logger   *slog.Logger
+	client   *imapClient
+}
+
+func newImapSession(
+	settings *mailerSettings, emailId, password string) *imapSession {
+	return &imapSession{
+		host: settings.ImapHost,
+		addr: net.JoinHostPort(
+			settings.ImapHost, strconv.Itoa(settings.ImapPort)),
+		emailId:  emailId,
+		password: password,
+		logger:   settings.Logger,
+	}
+}
+
+// Check logs in if needed.
+func (s *imapSession) Check() error {
+	if s.client != nil {
+		return nil
+	}
+	client, err := s.dial()
+	if err != nil {
+		return err
+	}
+	s.client = client
+	return nil
+}
+
+// Append appends msg to mailbox with flags e.g `\Draft`.
+func (s *imapSession) Append(mailbox, flags string, msg []byte) error {
+	if err := s.Check(); err != nil {
+		return err
+	}
+	err := s.client.Append(mailbox, flags, msg)
+	var imapErr *ImapError
+	if err != nil && !errors.As(err, &imapErr) {
+		s.logger.Debug("dropping imap session", "err", err)
+		s.client.conn.Close()
+		s.client = nil
+	}
+	return err
+}
+
+// Close logs out.
+func (s *imapSession) Close() {
+	if s.client == nil {
+		return
+	}
+	s.client.Logout()
+	s.client = nil
+}
+
+// dial connects with TLS and logs in. dial connects without TLS only to
+// a server on localhost on a port other than 993, which is what local
+// test servers do.
+func (s *imapSession) dial() (*imapClient, error) {
+	s.logger.Debug("opening imap session", "addr", s.addr)
+	var conn net.Conn
+	var err error
+	encrypted := !isLocalhost(s.host) || strings.HasSuffix(s.addr, ":993")
+	if encrypted {
+		conn, err = tls.Dial("tcp", s.addr, &tls.Config{ServerName: s.host})
+	} else {
+		conn, err = net.Dial("tcp", s.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	tconn := &traceConn{
+		Conn: conn, logger: s.logger, encrypted: encrypted, protocol: "imap"}
+	client := &imapClient{conn: tconn, reader: bufio.NewReader(tconn)}
+	greeting, err := client.readLine()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		conn.Close()
+		return nil, fmt.Errorf("imap: unexpected greeting: %s", greeting)
+	}
+	if err := client.Login(s.emailId, s.password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+func isLocalhost(host string) bool {
+	return host == "localhost" || net.ParseIP(host).IsLoopback()
+}
+
+// imapClient speaks just enough IMAP4rev1 to log in and append.
+type imapClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	tag    int
+}
+
+// Login logs in with LOGIN.
+func (c *imapClient) Login(user, password string) error {
+	quotedUser, err := quote(user)
+	if err != nil {
+		return err
+	}
+	quotedPassword, err := quote(password)
+	if err != nil {
+		return err
+	}
+	tag, err := c.send("LOGIN " + quotedUser + " " + quotedPassword)
+	if err != nil {
+		return err
+	}
+	return c.wait(tag, "LOGIN")
+}
+
+// Append appends msg to mailbox with flags.
+func (c *imapClient) Append(mailbox, flags string, msg []byte) error {
+	quotedMailbox, err := quote(mailbox)
+	if err != nil {
+		return err
+	}
+	tag, err := c.send(fmt.Sprintf(
+		"APPEND %s (%s) {%d}", quotedMailbox, flags, len(msg)))
+	if err != nil {
+		return err
+	}
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(line, "+") {
+			break
+		}
+		if strings.HasPrefix(line, tag+" ") {
+			return &ImapError{
+				Command: "APPEND", Response: strings.TrimPrefix(line, tag+" ")}
+		}
+	}
+	if _, err := c.conn.Write(append(msg, '\r', '\n')); err != nil {
+		return err
+	}
+	return c.wait(tag, "APPEND")
+}
+
+// Logout logs out and closes the connection.
+func (c *imapClient) Logout() {
+	if tag, err := c.send("LOGOUT"); err == nil {
+		c.wait(tag, "LOGOUT")
+	}
+	c.conn.Close()
+}
+
+// send sends command with a new tag and returns the tag.
+func (c *imapClient) send(command string) (string, error) {
+	c.tag++
+	tag := "a" + strconv.Itoa(c.tag)
+	_, err := c.conn.Write([]byte(tag + " " + command + "\r\n"))
+	return tag, err
+}
+
+// wait reads responses until the one tagged tag returning an *ImapError
+// if it isn't OK.
+func (c *imapClient) wait(tag, command string) error {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		response, ok := strings.CutPrefix(line, tag+" ")
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(strings.ToUpper(response), "OK") {
+			return nil
+		}
+		return &ImapError{Command: command, Response: response}
+	}
+}
+
+func (c *imapClient) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// quote returns s as an IMAP quoted string.
+func quote(s string) (string, error) {
+	if !isASCII(s) || strings.ContainsAny(s, "\r\n") {
+		return "", fmt.Errorf("imap: can't quote %q", s)
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`,
+		nil
+}
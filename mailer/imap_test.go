@@ -0,0 +1,195 @@
+package mailer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var kAppendCommand = regexp.MustCompile(`^APPEND "(.*)" \((.*)\) \{(\d+)\}$`)
+
+func TestDrafter(t *testing.T) {
+	server := newFakeImapServer(t)
+	defer server.Close()
+	d := newTestDrafter(server, "secret", Mailbox("[Gmail]/Drafts"))
+	for _, to := range []string{"bob@example.com", "carol@example.com"} {
+		assert.NoError(t, <-d.SendFuture(Email{
+			To: []string{to}, Subject: "Hello", Body: "Hi"}))
+	}
+	d.Shutdown()
+	assert.Equal(t, 1, server.Logins())
+	appended := server.Appended()
+	assert.Len(t, appended, 2)
+	assert.Equal(t, "[Gmail]/Drafts", appended[0].Mailbox)
+	assert.Equal(t, `\Draft`, appended[0].Flags)
+	assert.Contains(t, appended[1].Message, "To: <carol@example.com>\r\n")
+	assert.Contains(t, appended[1].Message, "\r\nDate: ")
+	assert.Contains(t, appended[1].Message, "\r\nMessage-ID: <")
+	assert.True(t, strings.HasSuffix(appended[1].Message, "\r\n\r\nHi"))
+}
+
+func TestDrafterMissingMailbox(t *testing.T) {
+	server := newFakeImapServer(t)
+	defer server.Close()
+	d := newTestDrafter(server, "secret", Mailbox("Missing"))
+	err := <-d.SendFuture(Email{
+		To: []string{"bob@example.com"}, Subject: "Hello", Body: "Hi"})
+	d.Shutdown()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "TRYCREATE")
+	assert.Empty(t, server.Appended())
+}
+
+func TestDrafterBadPassword(t *testing.T) {
+	server := newFakeImapServer(t)
+	defer server.Close()
+	d := newTestDrafter(server, "wrong")
+	err := <-d.SendFuture(Email{
+		To: []string{"bob@example.com"}, Subject: "Hello", Body: "Hi"})
+	d.Shutdown()
+	assert.Error(t, err)
+	assert.Empty(t, server.Appended())
+}
+
+func TestDrafterCheck(t *testing.T) {
+	server := newFakeImapServer(t)
+	defer server.Close()
+	d := newTestDrafter(server, "secret")
+	assert.NoError(t, d.Check(""))
+	assert.NoError(t, <-d.SendFuture(Email{
+		To: []string{"bob@example.com"}, Subject: "Hello", Body: "Hi"}))
+	d.Shutdown()
+	assert.Equal(t, 1, server.Logins())
+	bad := newTestDrafter(server, "wrong")
+	assert.Error(t, bad.Check(""))
+	bad.Shutdown()
+}
+
+func TestQuote(t *testing.T) {
+	quoted, err := quote(`pass"word\`)
+	assert.NoError(t, err)
+	assert.Equal(t, `"pass\"word\\"`, quoted)
+	_, err = quote("Entwürfe")
+	assert.Error(t, err)
+}
+
+func newTestDrafter(
+	server *fakeImapServer, password string, options ...Option) *Drafter {
+	_, port, _ := net.SplitHostPort(server.Addr())
+	portNum, _ := strconv.Atoi(port)
+	options = append(options, ImapServer("127.0.0.1", portNum))
+	return NewDrafter("alice@example.com", password, options...)
+}
+
+type appendedMessage struct {
+	Mailbox string
+	Flags   string
+	Message string
+}
+
+// fakeImapServer is a minimal IMAP server for testing. Its one user is
+// alice@example.com with password secret. It has every mailbox except
+// Missing.
+type fakeImapServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	logins   int
+	appended []appendedMessage
+}
+
+func newFakeImapServer(t *testing.T) *fakeImapServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &fakeImapServer{listener: listener}
+	go result.serve()
+	return result
+}
+
+func (f *fakeImapServer) Addr() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeImapServer) Close() {
+	f.listener.Close()
+}
+
+func (f *fakeImapServer) Logins() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.logins
+}
+
+func (f *fakeImapServer) Appended() []appendedMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.appended
+}
+
+func (f *fakeImapServer) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeImapServer) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	fmt.Fprint(conn, "* OK fake IMAP ready\r\n")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		tag, command, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		switch {
+		case strings.HasPrefix(command, "LOGIN "):
+			if command != `LOGIN "alice@example.com" "secret"` {
+				fmt.Fprintf(conn, "%s NO [AUTHENTICATIONFAILED] bad\r\n", tag)
+				continue
+			}
+			f.mu.Lock()
+			f.logins++
+			f.mu.Unlock()
+			fmt.Fprintf(conn, "%s OK logged in\r\n", tag)
+		case strings.HasPrefix(command, "APPEND "):
+			match := kAppendCommand.FindStringSubmatch(command)
+			if match[1] == "Missing" {
+				fmt.Fprintf(conn, "%s NO [TRYCREATE] no such mailbox\r\n", tag)
+				continue
+			}
+			fmt.Fprint(conn, "+ go ahead\r\n")
+			length, _ := strconv.Atoi(match[3])
+			message := make([]byte, length+2)
+			if _, err := io.ReadFull(reader, message); err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.appended = append(f.appended, appendedMessage{
+				Mailbox: match[1],
+				Flags:   match[2],
+				Message: string(message[:length]),
+			})
+			f.mu.Unlock()
+			fmt.Fprintf(conn, "%s OK appended\r\n", tag)
+		case command == "LOGOUT":
+			fmt.Fprintf(conn, "* BYE\r\n%s OK bye\r\n", tag)
+			return
+		default:
+			fmt.Fprintf(conn, "%s BAD unknown command\r\n", tag)
+		}
+	}
+}
@@ -0,0 +1,706 @@
+// Package mailer sends emails via SMTP asynchronously. Unlike sending
+// each email over its own connection, a Mailer keeps one SMTP session open
+// across emails, checking it with NOOP when it has been idle and
+// reconnecting when it has gone stale.
+package mailer
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	kDefaultHost = "smtp.gmail.com"
+	kDefaultPort = 587
+)
+
+var errNoSMTPUTF8 = errors.New(
+	"smtp: server doesn't support SMTPUTF8 needed for non-ASCII addresses")
+
+// Option represents an option for the NewWithOptions method.
+type Option interface {
+	mutate(m *mailerSettings)
+}
+
+// SendWaitTime sets the time to wait between email sends.
+func SendWaitTime(timeToWait time.Duration) Option {
+	return optionFunc(func(m *mailerSettings) {
+		m.SendWaitTime = timeToWait
+	})
+}
+
+// BufferSize sets the maximum number of pending emails before Send or
+// SendFuture block.
+func BufferSize(bufferSize int) Option {
+	return optionFunc(func(m *mailerSettings) {
+		m.BufferSize = bufferSize
+	})
+}
+
+// Server sets the SMTP server. The default is smtp.gmail.com port 587.
+// The mailer uses STARTTLS if the server offers it. Without STARTTLS, the
+// mailer only sends credentials to a server on localhost.
+func Server(host string, port int) Option {
+	return optionFunc(func(m *mailerSettings) {
+		m.Host = host
+		m.Port = port
+	})
+}
+
+// ImapServer sets the IMAP server that a Drafter saves drafts to and
+// that CopyToMailbox copies sent emails to. The
+// default is imap.gmail.com port 993. The IMAP connection uses TLS
+// unless the server is on localhost on a port other than 993.
+func ImapServer(host string, port int) Option {
+	return optionFunc(func(m *mailerSettings) {
+		m.ImapHost = host
+		m.ImapPort = port
+	})
+}
+
+// Mailbox sets the IMAP mailbox that a Drafter saves drafts to. The
+// default is Drafts. Gmail calls it [Gmail]/Drafts.
+func Mailbox(name string) Option {
+	return optionFunc(func(m *mailerSettings) {
+		m.Mailbox = name
+	})
+}
+
+// CopyToMailbox makes a Mailer append a copy of each email it sends to
+// mailbox over IMAP, marked as read, for SMTP servers that don't keep
+// sent emails, e.g CopyToMailbox("Sent"). The IMAP credentials are the
+// same as the SMTP credentials. Failing to copy an email doesn't fail
+// sending it; the Mailer logs the failure at warn level instead. The
+// default is not to copy. Gmail keeps sent emails on its own.
+func CopyToMailbox(mailbox string) Option {
+	return optionFunc(func(m *mailerSettings) {
+		m.CopyToMailbox = mailbox
+	})
+}
+
+// Logger sets the logger. The mailer logs each email sent at info level,
+// SMTP session events at debug level, and the SMTP conversation itself
+// at LevelTrace. The conversation is only logged up until STARTTLS. The
+// default is to log nothing.
+func Logger(logger *slog.Logger) Option {
+	return optionFunc(func(m *mailerSettings) {
+		m.Logger = logger
+	})
+}
+
+// NoopInterval sets how long the SMTP session may sit idle before the
+// mailer checks it with a NOOP command before reusing it. If the NOOP
+// fails, the mailer reconnects.
+func NoopInterval(interval time.Duration) Option {
+	return optionFunc(func(m *mailerSettings) {
+		m.NoopInterval = interval
+	})
+}
+
+// MaxEmailsPerSession sets the maximum number of emails sent over one
+// SMTP session before the mailer reconnects. Zero or negative means no
+// limit.
+func MaxEmailsPerSession(count int) Option {
+	return optionFunc(func(m *mailerSettings) {
+		m.MaxEmailsPerSession = count
+	})
+}
+
+// Priority is the priority of an email.
+type Priority int
+
+const (
+
+	// NormalPriority adds no priority headers.
+	NormalPriority Priority = iota
+
+	// HighPriority marks an email urgent.
+	HighPriority
+
+	// LowPriority marks an email not urgent.
+	LowPriority
+)
+
+// ParsePriority parses "high", "normal", or "low".
+func ParsePriority(s string) (Priority, error) {
+	switch strings.ToLower(s) {
+	case "high":
+		return HighPriority, nil
+	case "normal", "":
+		return NormalPriority, nil
+	case "low":
+		return LowPriority, nil
+	default:
+		return NormalPriority, fmt.Errorf(
+			"priority must be high, normal, or low: %s", s)
+	}
+}
+
+// Email represents a single email.
+type Email struct {
+
+	// The sender. May include a display name. If empty, the sender is the
+	// emailId passed to New.
+	From string
+
+	// Recipients. Each may include a display name,
+	// e.g "Bob <bob@example.com>". Display names may contain any UTF-8.
+	To []string
+
+	// Subject may contain any UTF-8.
+	Subject string
+
+	// Body is plain text.
+	Body string
+
+	// HtmlBody, if not empty, is the HTML version of Body. Mail programs
+	// show whichever version they handle best.
+	HtmlBody string
+
+	// Priority sets the X-Priority and Importance headers.
+	Priority Priority
+
+	// ReadReceipt requests a read receipt sent to the sender.
+	ReadReceipt bool
+
+	// Attachments are paths to files to attach.
+	Attachments []string
+
+	// Headers are extra headers. The keys are header names; the values
+	// are header values which may contain any UTF-8. Headers may not
+	// replace the headers the mailer writes itself such as Subject.
+	Headers map[string]string
+}
+
+// Mailer sends emails asynchronously via SMTP or, if created with
+// NewGmailApi or NewGraphApi, via the Gmail API or Microsoft Graph.
+// Mailer does not use SMTP pipelining as net/smtp does not support it.
+type Mailer struct {
+	emailCh   chan *emailJob
+	emailId   string
+	transport transport
+	copies    *imapSession
+	copyTo    string
+	logger    *slog.Logger
+	pause     time.Duration
+	done      chan struct{}
+}
+
+// transport delivers built messages for a Mailer.
+type transport interface {
+
+	// Send delivers msg to the recipients in env.
+	Send(env *envelope, msg []byte) error
+
+	// Check makes sure that transport can send from the sender in env
+	// as far as it can tell without sending anything.
+	Check(env *envelope) error
+
+	// Close releases any connection that transport holds.
+	Close()
+}
+
+// New creates a new instance. emailId and password are the gmail
+// sender address and password respectively, and they double as the
+// credentials for the SMTP server. The created Mailer has a
+// buffer size of 100 and a send wait time of 1s. It checks its SMTP session
+// with NOOP after 30s of idle time and never reconnects just because it
+// sent many emails.
+func New(emailId, password string) *Mailer {
+	return NewWithOptions(emailId, password)
+}
+
+// NewWithOptions works like New, but allows creation to be configured with
+// options. The defaults for each option are the same as New.
+func NewWithOptions(emailId, password string, options ...Option) *Mailer {
+	settings := newMailerSettings(options)
+	port := strconv.Itoa(settings.Port)
+	result := newMailer(emailId, &session{
+		host:         settings.Host,
+		addr:         net.JoinHostPort(settings.Host, port),
+		auth:         smtp.PlainAuth("", emailId, password, settings.Host),
+		noopInterval: settings.NoopInterval,
+		maxEmails:    settings.MaxEmailsPerSession,
+		tlsConfig:    settings.TLSConfig,
+		logger:       settings.Logger,
+	}, &settings)
+	if settings.CopyToMailbox != "" {
+		result.copies = newImapSession(&settings, emailId, password)
+		result.copyTo = settings.CopyToMailbox
+	}
+	go result.loop()
+	return result
+}
+
+func newMailer(
+	emailId string, transport transport, settings *mailerSettings) *Mailer {
+	var emailCh chan *emailJob
+	if settings.BufferSize > 0 {
+		emailCh = make(chan *emailJob, settings.BufferSize)
+	} else {
+		emailCh = make(chan *emailJob)
+	}
+	result := &Mailer{
+		emailCh:   emailCh,
+		emailId:   emailId,
+		transport: transport,
+		logger:    settings.Logger,
+		pause:     settings.SendWaitTime,
+		done:      make(chan struct{}),
+	}
+	return result
+}
+
+// Send sends one email asynchronously returning immediately. When it
+// eventually sends the email, it reports any errors to stderr.
+func (m *Mailer) Send(email Email) {
+	responseCh := m.SendFuture(email)
+	go func() {
+		err := <-responseCh
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+}
+
+// SendFuture sends one email asynchronously returning immediately. Caller
+// must use returned channel to get the result of the send.
+func (m *Mailer) SendFuture(email Email) <-chan error {
+	emailJob := &emailJob{Email: email, Response: make(chan error, 1)}
+	m.emailCh <- emailJob
+	return emailJob.Response
+}
+
+// Check makes sure that this mailer can send from the address from as
+// far as it can tell without sending anything. With SMTP, Check logs
+// in and asks the server to accept from as the sender; with the Gmail
+// API and Microsoft Graph, Check gets an access token. An empty from
+// means the sender address. Check waits for emails already queued.
+func (m *Mailer) Check(from string) error {
+	emailJob := &emailJob{
+		Email: Email{From: from}, Check: true, Response: make(chan error, 1)}
+	m.emailCh <- emailJob
+	return <-emailJob.Response
+}
+
+// Shutdown shuts down this mailer. Shutdown waits to return until all
+// pending emails have been sent and then closes the SMTP session. It is an
+// error to call Send or SendFuture after calling Shutdown.
+func (m *Mailer) Shutdown() {
+	close(m.emailCh)
+	<-m.done
+}
+
+func (m *Mailer) loop() {
+	for emailJob := range m.emailCh {
+		if emailJob.Check {
+			emailJob.SetResponse(m.check(emailJob.From))
+			continue
+		}
+		emailJob.SetResponse(m.send(&emailJob.Email))
+		if m.pause > 0 {
+			time.Sleep(m.pause)
+		}
+	}
+	m.transport.Close()
+	if m.copies != nil {
+		m.copies.Close()
+	}
+	close(m.done)
+}
+
+func (m *Mailer) check(from string) error {
+	if from == "" {
+		from = m.emailId
+	}
+	env, err := newEnvelope(from, nil)
+	if err != nil {
+		return err
+	}
+	if err := m.transport.Check(env); err != nil {
+		return err
+	}
+	if m.copies != nil {
+		return m.copies.Check()
+	}
+	return nil
+}
+
+func (m *Mailer) send(email *Email) error {
+	env, msg, err := email.build(m.emailId)
+	if err != nil {
+		return err
+	}
+	err = m.transport.Send(env, msg)
+	if err != nil {
+		m.logger.Info(
+			"send failed", "to", env.To, "bytes", len(msg), "err", err)
+	} else {
+		m.logger.Info("sent", "to", env.To, "bytes", len(msg))
+		m.copy(env, msg)
+	}
+	return err
+}
+
+// copy appends a copy of a sent msg to the mailbox that CopyToMailbox
+// set if any.
+func (m *Mailer) copy(env *envelope, msg []byte) {
+	if m.copies == nil {
+		return
+	}
+	if err := m.copies.Append(m.copyTo, `\Seen`, msg); err != nil {
+		m.logger.Warn(
+			"copying sent email failed",
+			"to", env.To, "mailbox", m.copyTo, "err", err)
+	}
+}
+
+// session is a reusable SMTP session.
+type session struct {
+	host         string
+	addr         string
+	auth         smtp.Auth
+	noopInterval time.Duration
+	maxEmails    int
+	tlsConfig    *tls.Config
+	logger       *slog.Logger
+	client       *smtp.Client
+	lastUsed     time.Time
+	emailCount   int
+}
+
+// Send sends msg reusing the current SMTP session if it is still good.
+// If the server drops a reused session before accepting the sender, Send
+// reconnects and tries once more.
+func (s *session) Send(env *envelope, msg []byte) error {
+	reused := s.client != nil
+	if err := s.ensureClient(); err != nil {
+		return err
+	}
+	err := s.client.Mail(env.From)
+	if err != nil && reused && !isProtocolError(err) {
+		s.logger.Debug("smtp session dropped; reconnecting", "err", err)
+		s.Close()
+		if err := s.ensureClient(); err != nil {
+			return err
+		}
+		err = s.client.Mail(env.From)
+	}
+	if err == nil {
+		err = s.checkSMTPUTF8(env)
+	}
+	if err == nil {
+		err = s.sendData(env.To, msg)
+	}
+	if err != nil {
+		s.abort(err)
+		return err
+	}
+	s.lastUsed = time.Now()
+	s.emailCount++
+	return nil
+}
+
+// Check logs in if needed and then starts and resets a transaction
+// from the sender in env.
+func (s *session) Check(env *envelope) error {
+	if err := s.ensureClient(); err != nil {
+		return err
+	}
+	err := s.client.Mail(env.From)
+	if err == nil {
+		err = s.checkSMTPUTF8(env)
+	}
+	if err != nil {
+		s.abort(err)
+		return err
+	}
+	return s.client.Reset()
+}
+
+// Close ends the SMTP session if there is one.
+func (s *session) Close() {
+	if s.client == nil {
+		return
+	}
+	s.logger.Debug("closing smtp session", "emails", s.emailCount)
+	s.client.Quit()
+	s.client.Close()
+	s.client = nil
+}
+
+// checkSMTPUTF8 returns an error if env needs SMTPUTF8 and the server
+// doesn't support it. When the server does support it, net/smtp asks for
+// SMTPUTF8 on its own.
+func (s *session) checkSMTPUTF8(env *envelope) error {
+	if !env.SMTPUTF8 {
+		return nil
+	}
+	if ok, _ := s.client.Extension("SMTPUTF8"); !ok {
+		return errNoSMTPUTF8
+	}
+	return nil
+}
+
+func (s *session) sendData(to []string, msg []byte) error {
+	for _, addr := range to {
+		if err := s.client.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := s.client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// abort leaves the session ready for the next email after a failed send.
+// A server rejection only needs the transaction reset; anything else
+// means the connection is suspect.
+func (s *session) abort(err error) {
+	if s.client == nil {
+		return
+	}
+	transactionOnly := isProtocolError(err) || err == errNoSMTPUTF8
+	if transactionOnly && s.client.Reset() == nil {
+		return
+	}
+	s.client.Close()
+	s.client = nil
+}
+
+func (s *session) ensureClient() error {
+	if s.client != nil && s.isStale() {
+		s.Close()
+	}
+	if s.client != nil {
+		return nil
+	}
+	client, err := s.dial()
+	if err != nil {
+		return err
+	}
+	s.client = client
+	s.lastUsed = time.Now()
+	s.emailCount = 0
+	return nil
+}
+
+func (s *session) isStale() bool {
+	if s.maxEmails > 0 && s.emailCount >= s.maxEmails {
+		s.logger.Debug("smtp session reached max emails")
+		return true
+	}
+	if time.Since(s.lastUsed) >= s.noopInterval {
+		if err := s.client.Noop(); err != nil {
+			s.logger.Debug("smtp session went stale", "err", err)
+			return true
+		}
+	}
+	return false
+}
+
+// dial connects and logs in. If the server offers STARTTLS, dial
+// switches to TLS itself rather than through smtp.Client.StartTLS so
+// that the trace sits inside TLS and sees the whole conversation.
+func (s *session) dial() (*smtp.Client, error) {
+	s.logger.Debug("opening smtp session", "addr", s.addr)
+	conn, err := net.Dial("tcp", s.addr)
+	if err != nil {
+		return nil, err
+	}
+	greeting, tlsConn, err := s.startTLS(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	auth := s.auth
+	if tlsConn != nil {
+		conn = tlsConn
+		auth = encryptedAuth{Auth: auth}
+	}
+	tconn := &traceConn{Conn: conn, logger: s.logger}
+	client, err := smtp.NewClient(
+		&replayConn{Conn: tconn, pending: greeting}, s.host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if ok, _ := client.Extension("AUTH"); !ok {
+		client.Close()
+		return nil, errors.New("smtp: server doesn't support AUTH")
+	}
+	if err := client.Auth(auth); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// startTLS reads the greeting on conn and switches conn to TLS if the
+// server offers STARTTLS. startTLS returns the greeting for net/smtp to
+// read and the TLS connection or nil if the server doesn't offer
+// STARTTLS.
+func (s *session) startTLS(conn net.Conn) (
+	greeting []byte, tlsConn *tls.Conn, err error) {
+	text := textproto.NewConn(&traceConn{Conn: conn, logger: s.logger})
+	_, message, err := text.ReadResponse(220)
+	if err != nil {
+		return nil, nil, err
+	}
+	lines := strings.Split(message, "\n")
+	for i, line := range lines {
+		separator := "-"
+		if i == len(lines)-1 {
+			separator = " "
+		}
+		greeting = fmt.Appendf(greeting, "220%s%s\r\n", separator, line)
+	}
+	if err := text.PrintfLine("EHLO localhost"); err != nil {
+		return nil, nil, err
+	}
+	_, message, err = text.ReadResponse(250)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !hasExtension(message, "STARTTLS") {
+		return greeting, nil, nil
+	}
+	if err := text.PrintfLine("STARTTLS"); err != nil {
+		return nil, nil, err
+	}
+	if _, _, err := text.ReadResponse(220); err != nil {
+		return nil, nil, err
+	}
+	config := &tls.Config{ServerName: s.host}
+	if s.tlsConfig != nil {
+		config = s.tlsConfig.Clone()
+	}
+	tlsConn = tls.Client(conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, nil, err
+	}
+	s.logger.Debug("smtp session encrypted with STARTTLS")
+	return greeting, tlsConn, nil
+}
+
+// hasExtension returns true if the reply to EHLO in message lists
+// extension.
+func hasExtension(message, extension string) bool {
+	lines := strings.Split(message, "\n")
+	for _, line := range lines[1:] {
+		name, _, _ := strings.Cut(line, " ")
+		if strings.EqualFold(name, extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// replayConn returns pending before reading from Conn.
+type replayConn struct {
+	net.Conn
+	pending []byte
+}
+
+func (r *replayConn) Read(b []byte) (int, error) {
+	if len(r.pending) > 0 {
+		n := copy(b, r.pending)
+		r.pending = r.pending[n:]
+		return n, nil
+	}
+	return r.Conn.Read(b)
+}
+
+// encryptedAuth tells Auth that the session is encrypted, which net/smtp
+// can't see for itself when dial does the STARTTLS.
+type encryptedAuth struct {
+	smtp.Auth
+}
+
+func (e encryptedAuth) Start(server *smtp.ServerInfo) (
+	string, []byte, error) {
+	info := *server
+	info.TLS = true
+	return e.Auth.Start(&info)
+}
+
+func isProtocolError(err error) bool {
+	var protocolErr *textproto.Error
+	return errors.As(err, &protocolErr)
+}
+
+type emailJob struct {
+	Email
+	Check    bool
+	Response chan error
+}
+
+func (e *emailJob) SetResponse(err error) {
+	e.Response <- err
+	close(e.Response)
+}
+
+type mailerSettings struct {
+	SendWaitTime        time.Duration
+	BufferSize          int
+	NoopInterval        time.Duration
+	MaxEmailsPerSession int
+	Host                string
+	Port                int
+	ImapHost            string
+	ImapPort            int
+	Mailbox             string
+	CopyToMailbox       string
+	GmailApiUrl         string
+	GoogleTokenUrl      string
+	GraphApiUrl         string
+	MicrosoftLoginUrl   string
+	TLSConfig           *tls.Config
+	Logger              *slog.Logger
+}
+
+type optionFunc func(m *mailerSettings)
+
+func (o optionFunc) mutate(m *mailerSettings) {
+	o(m)
+}
+
+func newMailerSettings(options []Option) mailerSettings {
+	result := mailerSettings{
+		BufferSize:        100,
+		SendWaitTime:      time.Second,
+		NoopInterval:      30 * time.Second,
+		Host:              kDefaultHost,
+		Port:              kDefaultPort,
+		ImapHost:          kDefaultImapHost,
+		ImapPort:          kDefaultImapPort,
+		Mailbox:           "Drafts",
+		GmailApiUrl:       kDefaultGmailApiUrl,
+		GoogleTokenUrl:    kDefaultGoogleToken,
+		GraphApiUrl:       kDefaultGraphApiUrl,
+		MicrosoftLoginUrl: kDefaultMicrosoftLoginUrl,
+		Logger:            slog.New(slog.DiscardHandler),
+	}
+	mutateSettings(options, &result)
+	return result
+}
+
+func mutateSettings(options []Option, settings *mailerSettings) {
+	for _, option := range options {
+		option.mutate(settings)
+	}
+}
@@ -0,0 +1,431 @@
+package mailer
+
+import (
+	"bufio"
+	"crypto/tls"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReusesSession(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.Close()
+	m := newTestMailer(server)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, <-m.SendFuture(Email{
+			To:      []string{"bob@example.com"},
+			Subject: "Hello",
+			Body:    "Hi Bob",
+		}))
+	}
+	m.Shutdown()
+	assert.Equal(t, 1, server.Connections())
+	assert.Len(t, server.Messages(), 3)
+	assert.Contains(t, server.Messages()[0], "Subject: Hello\r\n")
+}
+
+func TestMaxEmailsPerSession(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.Close()
+	m := newTestMailer(server, MaxEmailsPerSession(2))
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, <-m.SendFuture(Email{
+			To: []string{"bob@example.com"}, Subject: "Hello", Body: "Hi"}))
+	}
+	m.Shutdown()
+	assert.Equal(t, 3, server.Connections())
+	assert.Len(t, server.Messages(), 5)
+}
+
+func TestReconnectsWhenServerDropsSession(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.Close()
+	m := newTestMailer(server)
+	assert.NoError(t, <-m.SendFuture(Email{
+		To: []string{"bob@example.com"}, Subject: "Hello", Body: "Hi"}))
+	server.DropConnections()
+	assert.NoError(t, <-m.SendFuture(Email{
+		To: []string{"bob@example.com"}, Subject: "Hello", Body: "Hi"}))
+	m.Shutdown()
+	assert.Equal(t, 2, server.Connections())
+	assert.Len(t, server.Messages(), 2)
+}
+
+func TestRejectedRecipientKeepsSession(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.Close()
+	m := newTestMailer(server)
+	assert.Error(t, <-m.SendFuture(Email{
+		To: []string{"reject@example.com"}, Subject: "Hello", Body: "Hi"}))
+	assert.NoError(t, <-m.SendFuture(Email{
+		To: []string{"bob@example.com"}, Subject: "Hello", Body: "Hi"}))
+	m.Shutdown()
+	assert.Equal(t, 1, server.Connections())
+	assert.Len(t, server.Messages(), 1)
+}
+
+func TestNoopAfterIdle(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.Close()
+	m := newTestMailer(server, NoopInterval(0))
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, <-m.SendFuture(Email{
+			To: []string{"bob@example.com"}, Subject: "Hello", Body: "Hi"}))
+	}
+	m.Shutdown()
+	assert.Equal(t, 1, server.Connections())
+	assert.Equal(t, 1, server.Noops())
+}
+
+func TestInternationalAddresses(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.Close()
+	m := newTestMailer(server)
+	assert.NoError(t, <-m.SendFuture(Email{
+		To: []string{"bob@bücher.example"}, Subject: "Hello", Body: "Hi"}))
+	assert.Error(t, <-m.SendFuture(Email{
+		To: []string{"björn@example.com"}, Subject: "Hello", Body: "Hi"}))
+	server.SetSMTPUTF8(true)
+	server.DropConnections()
+	assert.NoError(t, <-m.SendFuture(Email{
+		To: []string{"björn@example.com"}, Subject: "Hello", Body: "Hi"}))
+	m.Shutdown()
+	messages := server.Messages()
+	assert.Len(t, messages, 2)
+	assert.Contains(t, messages[0], "To: <bob@xn--bcher-kva.example>\r\n")
+	assert.Equal(
+		t,
+		[]string{
+			"RCPT TO:<bob@xn--bcher-kva.example>",
+			"RCPT TO:<björn@example.com>",
+		},
+		server.Recipients())
+}
+
+func TestFrom(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.Close()
+	m := newTestMailer(server)
+	assert.NoError(t, <-m.SendFuture(Email{
+		From:    "Garden Club <club@example.com>",
+		To:      []string{"bob@example.com"},
+		Subject: "Hello",
+		Body:    "Hi"}))
+	m.Shutdown()
+	assert.Contains(
+		t, server.Messages()[0], "From: \"Garden Club\" <club@example.com>\r\n")
+	assert.Equal(t, []string{"FROM:<club@example.com>"}, server.Senders())
+}
+
+func TestBadHeader(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.Close()
+	m := newTestMailer(server)
+	assert.Error(t, <-m.SendFuture(Email{
+		To:      []string{"bob@example.com"},
+		Subject: "Hello",
+		Body:    "Hi",
+		Headers: map[string]string{"To": "eve@example.com"}}))
+	m.Shutdown()
+	assert.Empty(t, server.Messages())
+}
+
+func TestCheck(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.Close()
+	m := newTestMailer(server)
+	assert.NoError(t, m.Check(""))
+	assert.NoError(t, m.Check("Garden Club <club@example.com>"))
+	assert.Error(t, m.Check("spoof@example.com"))
+	assert.NoError(t, <-m.SendFuture(Email{
+		To: []string{"bob@example.com"}, Subject: "Hello", Body: "Hi"}))
+	m.Shutdown()
+	assert.Equal(t, 1, server.Connections())
+	assert.Equal(
+		t,
+		[]string{
+			"FROM:<alice@example.com>",
+			"FROM:<club@example.com>",
+			"FROM:<alice@example.com>",
+		},
+		server.Senders())
+	assert.Len(t, server.Messages(), 1)
+}
+
+func TestCheckBadServer(t *testing.T) {
+	server := newFakeServer(t)
+	addr := server.Addr()
+	server.Close()
+	_, port, _ := net.SplitHostPort(addr)
+	portNum, _ := strconv.Atoi(port)
+	m := NewWithOptions(
+		"alice@example.com", "secret", Server("127.0.0.1", portNum))
+	assert.Error(t, m.Check(""))
+	m.Shutdown()
+}
+
+func TestCopyToMailbox(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.Close()
+	imapServer := newFakeImapServer(t)
+	defer imapServer.Close()
+	_, port, _ := net.SplitHostPort(imapServer.Addr())
+	portNum, _ := strconv.Atoi(port)
+	m := newTestMailer(
+		server, CopyToMailbox("Sent"), ImapServer("127.0.0.1", portNum))
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, <-m.SendFuture(Email{
+			To: []string{"bob@example.com"}, Subject: "Hello", Body: "Hi"}))
+	}
+	m.Shutdown()
+	assert.Equal(t, 1, imapServer.Logins())
+	copies := imapServer.Appended()
+	assert.Len(t, copies, 2)
+	assert.Equal(t, "Sent", copies[0].Mailbox)
+	assert.Equal(t, `\Seen`, copies[0].Flags)
+	assert.Equal(t, server.Messages()[0], copies[0].Message+"\r\n")
+	assert.Equal(t, server.Messages()[1], copies[1].Message+"\r\n")
+	ids := make([]string, 0, len(copies))
+	for _, c := range copies {
+		msg, err := readMessage([]byte(c.Message))
+		assert.NoError(t, err)
+		_, err = msg.Header.Date()
+		assert.NoError(t, err)
+		ids = append(ids, msg.Header.Get("Message-ID"))
+	}
+	assert.NotEmpty(t, ids[0])
+	assert.NotEqual(t, ids[0], ids[1])
+}
+
+func TestCopyToMailboxFailureKeepsSend(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.Close()
+	imapServer := newFakeImapServer(t)
+	defer imapServer.Close()
+	_, port, _ := net.SplitHostPort(imapServer.Addr())
+	portNum, _ := strconv.Atoi(port)
+	m := newTestMailer(
+		server, CopyToMailbox("Missing"), ImapServer("127.0.0.1", portNum))
+	assert.NoError(t, <-m.SendFuture(Email{
+		To: []string{"bob@example.com"}, Subject: "Hello", Body: "Hi"}))
+	m.Shutdown()
+	assert.Len(t, server.Messages(), 1)
+	assert.Empty(t, imapServer.Appended())
+}
+
+func newTestMailer(server *fakeServer, options ...Option) *Mailer {
+	_, port, _ := net.SplitHostPort(server.Addr())
+	portNum, _ := strconv.Atoi(port)
+	options = append(options, SendWaitTime(0), Server("127.0.0.1", portNum))
+	return NewWithOptions("alice@example.com", "secret", options...)
+}
+
+// fakeServer is a minimal SMTP server for testing.
+type fakeServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	conns    []net.Conn
+	count    int
+	noops    int
+	messages []string
+	rcpts    []string
+	senders  []string
+	smtpUTF8 bool
+
+	// If set, the server offers STARTTLS.
+	tlsConfig *tls.Config
+
+	// Whether each MAIL command came over TLS.
+	encrypted []bool
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &fakeServer{listener: listener}
+	go result.serve()
+	return result
+}
+
+func (f *fakeServer) Addr() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeServer) Close() {
+	f.listener.Close()
+	f.DropConnections()
+}
+
+func (f *fakeServer) Connections() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.count
+}
+
+func (f *fakeServer) Noops() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.noops
+}
+
+func (f *fakeServer) Messages() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.messages
+}
+
+func (f *fakeServer) Recipients() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rcpts
+}
+
+func (f *fakeServer) Senders() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.senders
+}
+
+func (f *fakeServer) Encrypted() []bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.encrypted
+}
+
+// SetTLS makes the server offer STARTTLS with config.
+func (f *fakeServer) SetTLS(config *tls.Config) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tlsConfig = config
+}
+
+func (f *fakeServer) SetSMTPUTF8(on bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.smtpUTF8 = on
+}
+
+func (f *fakeServer) DropConnections() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, conn := range f.conns {
+		conn.Close()
+	}
+	f.conns = nil
+	// Give the client side a moment to see the closed connection.
+	time.Sleep(10 * time.Millisecond)
+}
+
+func (f *fakeServer) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.conns = append(f.conns, conn)
+		f.count++
+		f.mu.Unlock()
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(s string) {
+		conn.Write([]byte(s + "\r\n"))
+	}
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO":
+			reply("250-localhost")
+			f.mu.Lock()
+			if f.smtpUTF8 {
+				reply("250-SMTPUTF8")
+			}
+			if _, ok := conn.(*tls.Conn); !ok && f.tlsConfig != nil {
+				reply("250-STARTTLS")
+			}
+			f.mu.Unlock()
+			reply("250 AUTH PLAIN")
+		case "STARTTLS":
+			f.mu.Lock()
+			config := f.tlsConfig
+			f.mu.Unlock()
+			reply("220 Ready to start TLS")
+			tlsConn := tls.Server(conn, config)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			r = bufio.NewReader(conn)
+		case "AUTH":
+			reply("235 OK")
+		case "MAIL":
+			if strings.Contains(line, "spoof@") {
+				reply("553 Sender not allowed")
+				continue
+			}
+			_, encrypted := conn.(*tls.Conn)
+			f.mu.Lock()
+			f.senders = append(f.senders, strings.Fields(line)[1])
+			f.encrypted = append(f.encrypted, encrypted)
+			f.mu.Unlock()
+			reply("250 OK")
+		case "RSET":
+			reply("250 OK")
+		case "NOOP":
+			f.mu.Lock()
+			f.noops++
+			f.mu.Unlock()
+			reply("250 OK")
+		case "RCPT":
+			if strings.Contains(line, "reject@") {
+				reply("550 No such user")
+			} else {
+				f.mu.Lock()
+				f.rcpts = append(f.rcpts, line)
+				f.mu.Unlock()
+				reply("250 OK")
+			}
+		case "DATA":
+			reply("354 Go ahead")
+			var msg strings.Builder
+			for {
+				dataLine, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				msg.WriteString(dataLine)
+			}
+			f.mu.Lock()
+			f.messages = append(f.messages, msg.String())
+			f.mu.Unlock()
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Unknown command")
+		}
+	}
+}
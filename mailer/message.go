@@ -0,0 +1,302 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var kReservedHeaders = map[string]struct{}{
+	"Bcc":                         {},
+	"Cc":                          {},
+	"Content-Transfer-Encoding":   {},
+	"Content-Type":                {},
+	"Date":                        {},
+	"Disposition-Notification-To": {},
+	"From":                        {},
+	"Importance":                  {},
+	"Message-Id":                  {},
+	"Mime-Version":                {},
+	"Subject":                     {},
+	"To":                          {},
+	"X-Priority":                  {},
+}
+
+const (
+	kMaxHeaderLineLength = 78
+	kMaxLineLength       = 78
+	kBase64LineLength    = 76
+)
+
+// Message returns this email as it would be sent. defaultFrom is the
+// sender if this email has no From. Message returns an error if the
+// addresses or headers in this email are invalid.
+func (e *Email) Message(defaultFrom string) ([]byte, error) {
+	_, msg, err := e.build(defaultFrom)
+	return msg, err
+}
+
+func (e *Email) build(defaultFrom string) (*envelope, []byte, error) {
+	from := e.From
+	if from == "" {
+		from = defaultFrom
+	}
+	env, err := newEnvelope(from, e.To)
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, value := range e.Headers {
+		if err := ValidateHeader(name, value); err != nil {
+			return nil, nil, err
+		}
+	}
+	msg, err := e.message(env, time.Now(), newMessageId(env.From))
+	if err != nil {
+		return nil, nil, err
+	}
+	return env, msg, nil
+}
+
+// message returns this email as a message from the sender in env sent
+// at date with messageId as its Message-ID. message encodes non-ASCII in
+// the subject and display names per RFC 2047.
+func (e *Email) message(
+	env *envelope, date time.Time, messageId string) ([]byte, error) {
+	var buf bytes.Buffer
+	writeHeader(&buf, "From", env.FromHeader.String())
+	writeHeader(&buf, "To", joinAddresses(env.ToHeader))
+	writeHeader(&buf, "Subject", mime.QEncoding.Encode("utf-8", e.Subject))
+	writeHeader(&buf, "Date", date.Format(time.RFC1123Z))
+	writeHeader(&buf, "Message-ID", messageId)
+	switch e.Priority {
+	case HighPriority:
+		writeHeader(&buf, "X-Priority", "1 (Highest)")
+		writeHeader(&buf, "Importance", "high")
+	case LowPriority:
+		writeHeader(&buf, "X-Priority", "5 (Lowest)")
+		writeHeader(&buf, "Importance", "low")
+	}
+	if e.ReadReceipt {
+		writeHeader(
+			&buf, "Disposition-Notification-To", env.FromHeader.String())
+	}
+	for _, name := range slices.Sorted(maps.Keys(e.Headers)) {
+		writeHeader(&buf, name, mime.QEncoding.Encode("utf-8", e.Headers[name]))
+	}
+	writeHeader(&buf, "MIME-Version", "1.0")
+	if len(e.Attachments) == 0 {
+		e.writeBody(&buf)
+		return buf.Bytes(), nil
+	}
+	boundary := multipart.NewWriter(nil).Boundary()
+	writeMultipartHeader(&buf, "multipart/mixed", boundary)
+	buf.WriteString("\r\n--" + boundary + "\r\n")
+	e.writeBody(&buf)
+	for _, path := range e.Attachments {
+		buf.WriteString("\r\n--" + boundary + "\r\n")
+		if err := writeAttachment(&buf, path); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteString("\r\n--" + boundary + "--\r\n")
+	return buf.Bytes(), nil
+}
+
+// newMessageId returns a new Message-ID for an email from the address
+// from, e.g "<3f2a...@gmail.com>". Drafts and copies in the Sent folder
+// need one since only some servers add it.
+func newMessageId(from string) string {
+	random := make([]byte, 16)
+	rand.Read(random)
+	domain := from[strings.LastIndex(from, "@")+1:]
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(random), domain)
+}
+
+// writeBody writes the body of this email as a text/plain part or, if
+// this email has an HTML body, as a multipart/alternative part with the
+// plain text first as RFC 2046 asks.
+func (e *Email) writeBody(buf *bytes.Buffer) {
+	if e.HtmlBody == "" {
+		writePart(buf, "text/plain; charset=utf-8", e.Body)
+		return
+	}
+	boundary := multipart.NewWriter(nil).Boundary()
+	writeMultipartHeader(buf, "multipart/alternative", boundary)
+	buf.WriteString("\r\n--" + boundary + "\r\n")
+	writePart(buf, "text/plain; charset=utf-8", e.Body)
+	buf.WriteString("\r\n--" + boundary + "\r\n")
+	writePart(buf, "text/html; charset=utf-8", e.HtmlBody)
+	buf.WriteString("\r\n--" + boundary + "--\r\n")
+}
+
+func writeMultipartHeader(buf *bytes.Buffer, mediaType, boundary string) {
+	writeHeader(
+		buf,
+		"Content-Type",
+		mime.FormatMediaType(mediaType, map[string]string{
+			"boundary": boundary,
+		}))
+}
+
+// writeAttachment writes the file at path as a base64 encoded attachment
+// part. Files sent to many people are read and encoded only once.
+func writeAttachment(buf *bytes.Buffer, path string) error {
+	part, err := kAttachmentCache.Get(path)
+	if err != nil {
+		return err
+	}
+	buf.Write(part)
+	return nil
+}
+
+// encodeAttachment returns the file at path as a base64 encoded
+// attachment part.
+func encodeAttachment(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	filename := filepath.Base(path)
+	contentType := mime.TypeByExtension(filepath.Ext(filename))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "application/octet-stream", nil
+	}
+	if params == nil {
+		params = make(map[string]string)
+	}
+	params["name"] = filename
+	writeHeader(&buf, "Content-Type", mime.FormatMediaType(mediaType, params))
+	writeHeader(
+		&buf,
+		"Content-Disposition",
+		mime.FormatMediaType(
+			"attachment", map[string]string{"filename": filename}))
+	writeHeader(&buf, "Content-Transfer-Encoding", "base64")
+	buf.WriteString("\r\n")
+	writeBase64(&buf, data)
+	return buf.Bytes(), nil
+}
+
+// writePart writes the Content-Type and Content-Transfer-Encoding headers
+// followed by text encoded so that no line exceeds 78 characters.
+// Text that is ASCII with short lines goes as is; text that is mostly
+// non-ASCII goes as base64; everything else goes as quoted-printable.
+func writePart(buf *bytes.Buffer, contentType, text string) {
+	writeHeader(buf, "Content-Type", contentType)
+	switch transferEncoding(text) {
+	case "7bit":
+		writeHeader(buf, "Content-Transfer-Encoding", "7bit")
+		buf.WriteString("\r\n")
+		buf.WriteString(text)
+	case "base64":
+		writeHeader(buf, "Content-Transfer-Encoding", "base64")
+		buf.WriteString("\r\n")
+		writeBase64(buf, []byte(text))
+	default:
+		writeHeader(buf, "Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		w := quotedprintable.NewWriter(buf)
+		w.Write([]byte(text))
+		w.Close()
+	}
+}
+
+func transferEncoding(text string) string {
+	nonASCII := 0
+	for i := 0; i < len(text); i++ {
+		if text[i] >= utf8.RuneSelf {
+			nonASCII++
+		}
+	}
+	if nonASCII > len(text)/3 {
+		return "base64"
+	}
+	if nonASCII > 0 {
+		return "quoted-printable"
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if len(strings.TrimSuffix(line, "\r")) > kMaxLineLength {
+			return "quoted-printable"
+		}
+	}
+	return "7bit"
+}
+
+// writeBase64 writes data as base64 in lines of 76 characters.
+func writeBase64(buf *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > kBase64LineLength {
+		buf.WriteString(encoded[:kBase64LineLength])
+		buf.WriteString("\r\n")
+		encoded = encoded[kBase64LineLength:]
+	}
+	buf.WriteString(encoded)
+	buf.WriteString("\r\n")
+}
+
+// ValidateHeader returns an error if name and value can't be used in
+// Email.Headers.
+func ValidateHeader(name, value string) error {
+	if name == "" {
+		return errors.New("empty header name")
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] <= ' ' || name[i] > '~' || name[i] == ':' {
+			return fmt.Errorf("%q: invalid header name", name)
+		}
+	}
+	if _, ok := kReservedHeaders[textproto.CanonicalMIMEHeaderKey(name)]; ok {
+		return fmt.Errorf("%s: header is set by mailmerge", name)
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("%s: header value has a line break", name)
+	}
+	return nil
+}
+
+func joinAddresses(addrs []*mail.Address) string {
+	strs := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		strs = append(strs, addr.String())
+	}
+	return strings.Join(strs, ", ")
+}
+
+// writeHeader writes a header folding it at spaces to keep lines under
+// 78 characters where possible.
+func writeHeader(buf *bytes.Buffer, name, value string) {
+	lineLength := len(name) + 1
+	buf.WriteString(name)
+	buf.WriteString(":")
+	for _, word := range strings.Split(value, " ") {
+		if lineLength > len(name)+1 &&
+			lineLength+1+len(word) > kMaxHeaderLineLength {
+			buf.WriteString("\r\n")
+			lineLength = 0
+		}
+		buf.WriteString(" ")
+		buf.WriteString(word)
+		lineLength += 1 + len(word)
+	}
+	buf.WriteString("\r\n")
+}
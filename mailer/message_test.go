@@ -0,0 +1,382 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageASCII(t *testing.T) {
+	env, err := newEnvelope(
+		"alice@gmail.com", []string{"Bob Smith <bob@gmail.com>"})
+	assert.NoError(t, err)
+	email := Email{Subject: "Hello", Body: "Hi Bob"}
+	expected := "From: <alice@gmail.com>\r\n" +
+		"To: \"Bob Smith\" <bob@gmail.com>\r\n" +
+		"Subject: Hello\r\n" +
+		"Date: Fri, 01 Mar 2024 09:30:00 +0000\r\n" +
+		"Message-ID: <1234@gmail.com>\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: 7bit\r\n" +
+		"\r\n" +
+		"Hi Bob"
+	assert.Equal(t, expected, string(buildMessage(t, &email, env)))
+	assert.Equal(t, []string{"bob@gmail.com"}, env.To)
+}
+
+func TestMessagePriority(t *testing.T) {
+	env, err := newEnvelope(
+		"Alice <alice@gmail.com>", []string{"bob@gmail.com"})
+	assert.NoError(t, err)
+	email := Email{
+		Subject:     "Urgent",
+		Body:        "Hi",
+		Priority:    HighPriority,
+		ReadReceipt: true,
+	}
+	msg, err := readMessage(buildMessage(t, &email, env))
+	assert.NoError(t, err)
+	assert.Equal(t, "1 (Highest)", msg.Header.Get("X-Priority"))
+	assert.Equal(t, "high", msg.Header.Get("Importance"))
+	assert.Equal(
+		t,
+		`"Alice" <alice@gmail.com>`,
+		msg.Header.Get("Disposition-Notification-To"))
+	email = Email{Subject: "FYI", Body: "Hi", Priority: LowPriority}
+	msg, err = readMessage(buildMessage(t, &email, env))
+	assert.NoError(t, err)
+	assert.Equal(t, "5 (Lowest)", msg.Header.Get("X-Priority"))
+	assert.Equal(t, "low", msg.Header.Get("Importance"))
+	assert.Empty(t, msg.Header.Get("Disposition-Notification-To"))
+	email = Email{Subject: "Hello", Body: "Hi"}
+	msg, err = readMessage(buildMessage(t, &email, env))
+	assert.NoError(t, err)
+	assert.Empty(t, msg.Header.Get("X-Priority"))
+	assert.Empty(t, msg.Header.Get("Importance"))
+}
+
+func TestMessageHeaders(t *testing.T) {
+	env, err := newEnvelope("alice@gmail.com", []string{"bob@gmail.com"})
+	assert.NoError(t, err)
+	email := Email{
+		Subject: "Hello",
+		Body:    "Hi",
+		Headers: map[string]string{
+			"X-Ticket-Id": "1234",
+			"X-Note":      "Müller",
+		},
+	}
+	raw := string(buildMessage(t, &email, env))
+	assert.Less(
+		t,
+		strings.Index(raw, "X-Note:"),
+		strings.Index(raw, "X-Ticket-Id:"))
+	msg, err := readMessage([]byte(raw))
+	assert.NoError(t, err)
+	assert.Equal(t, "1234", msg.Header.Get("X-Ticket-Id"))
+	note, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("X-Note"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Müller", note)
+}
+
+func TestMessage(t *testing.T) {
+	email := Email{To: []string{"bob@gmail.com"}, Subject: "Hi", Body: "Hi"}
+	msg, err := email.Message("alice@gmail.com")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(msg), "From: <alice@gmail.com>"))
+	email.From = "club@example.com"
+	msg, err = email.Message("alice@gmail.com")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(msg), "From: <club@example.com>"))
+	email.Headers = map[string]string{"From": "eve@example.com"}
+	_, err = email.Message("alice@gmail.com")
+	assert.Error(t, err)
+	email = Email{To: []string{"bob"}, Subject: "Hi", Body: "Hi"}
+	_, err = email.Message("alice@gmail.com")
+	assert.Error(t, err)
+}
+
+func TestMessageDateAndId(t *testing.T) {
+	email := Email{To: []string{"bob@gmail.com"}, Subject: "Hi", Body: "Hi"}
+	before := time.Now().Truncate(time.Second)
+	raw, err := email.Message("Alice <alice@bücher.example>")
+	assert.NoError(t, err)
+	msg, err := readMessage(raw)
+	assert.NoError(t, err)
+	date, err := msg.Header.Date()
+	assert.NoError(t, err)
+	assert.False(t, date.Before(before))
+	assert.Regexp(
+		t,
+		`^<[0-9a-f]{32}@xn--bcher-kva\.example>$`,
+		msg.Header.Get("Message-ID"))
+	raw, err = email.Message("alice@gmail.com")
+	assert.NoError(t, err)
+	other, err := readMessage(raw)
+	assert.NoError(t, err)
+	assert.NotEqual(
+		t, msg.Header.Get("Message-ID"), other.Header.Get("Message-ID"))
+	email.Headers = map[string]string{"Message-ID": "<mine@gmail.com>"}
+	_, err = email.Message("alice@gmail.com")
+	assert.Error(t, err)
+}
+
+func TestMessageAttachments(t *testing.T) {
+	dir := t.TempDir()
+	pdfPath := filepath.Join(dir, "certificate.pdf")
+	assert.NoError(t, os.WriteFile(pdfPath, []byte("%PDF-1.4 fake"), 0644))
+	txtPath := filepath.Join(dir, "notes für bob")
+	assert.NoError(t, os.WriteFile(txtPath, []byte{0, 1, 2}, 0644))
+	env, err := newEnvelope("alice@gmail.com", []string{"bob@gmail.com"})
+	assert.NoError(t, err)
+	email := Email{
+		Subject:     "Your certificate",
+		Body:        "Attached.",
+		Attachments: []string{pdfPath, txtPath},
+	}
+	msg, err := readMessage(buildMessage(t, &email, env))
+	assert.NoError(t, err)
+	mediaType, params, err := mime.ParseMediaType(
+		msg.Header.Get("Content-Type"))
+	assert.NoError(t, err)
+	assert.Equal(t, "multipart/mixed", mediaType)
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	part, err := r.NextPart()
+	assert.NoError(t, err)
+	body, err := io.ReadAll(part)
+	assert.NoError(t, err)
+	assert.Equal(t, "Attached.", string(body))
+	part, err = r.NextPart()
+	assert.NoError(t, err)
+	assert.Equal(t, "certificate.pdf", part.FileName())
+	assert.Equal(t, "application/pdf", part.Header.Get("Content-Type")[:15])
+	assert.Equal(t, "%PDF-1.4 fake", decodeBase64Part(t, part))
+	part, err = r.NextPart()
+	assert.NoError(t, err)
+	assert.Equal(t, "notes für bob", part.FileName())
+	assert.Equal(t, "\x00\x01\x02", decodeBase64Part(t, part))
+	_, err = r.NextPart()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestMessageHtml(t *testing.T) {
+	env, err := newEnvelope("alice@gmail.com", []string{"bob@gmail.com"})
+	assert.NoError(t, err)
+	email := Email{
+		Subject:  "Party",
+		Body:     "Come to the party.",
+		HtmlBody: "<p>Come to the <b>party</b>.</p>",
+	}
+	msg, err := readMessage(buildMessage(t, &email, env))
+	assert.NoError(t, err)
+	assertAlternative(t, msg.Header.Get("Content-Type"), msg.Body)
+}
+
+func TestMessageHtmlAttachments(t *testing.T) {
+	pdfPath := filepath.Join(t.TempDir(), "map.pdf")
+	assert.NoError(t, os.WriteFile(pdfPath, []byte("%PDF-1.4 fake"), 0644))
+	env, err := newEnvelope("alice@gmail.com", []string{"bob@gmail.com"})
+	assert.NoError(t, err)
+	email := Email{
+		Subject:     "Party",
+		Body:        "Come to the party.",
+		HtmlBody:    "<p>Come to the <b>party</b>.</p>",
+		Attachments: []string{pdfPath},
+	}
+	msg, err := readMessage(buildMessage(t, &email, env))
+	assert.NoError(t, err)
+	mediaType, params, err := mime.ParseMediaType(
+		msg.Header.Get("Content-Type"))
+	assert.NoError(t, err)
+	assert.Equal(t, "multipart/mixed", mediaType)
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	part, err := r.NextPart()
+	assert.NoError(t, err)
+	assertAlternative(t, part.Header.Get("Content-Type"), part)
+	part, err = r.NextPart()
+	assert.NoError(t, err)
+	assert.Equal(t, "map.pdf", part.FileName())
+	_, err = r.NextPart()
+	assert.Equal(t, io.EOF, err)
+}
+
+// assertAlternative asserts that body is the multipart/alternative body
+// of the party email in TestMessageHtml.
+func assertAlternative(t *testing.T, contentType string, body io.Reader) {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	assert.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+	r := multipart.NewReader(body, params["boundary"])
+	part, err := r.NextPart()
+	assert.NoError(t, err)
+	assert.Equal(
+		t, "text/plain; charset=utf-8", part.Header.Get("Content-Type"))
+	text, err := io.ReadAll(part)
+	assert.NoError(t, err)
+	assert.Equal(t, "Come to the party.", string(text))
+	part, err = r.NextPart()
+	assert.NoError(t, err)
+	assert.Equal(
+		t, "text/html; charset=utf-8", part.Header.Get("Content-Type"))
+	html, err := io.ReadAll(part)
+	assert.NoError(t, err)
+	assert.Equal(t, "<p>Come to the <b>party</b>.</p>", string(html))
+	_, err = r.NextPart()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestMessageMissingAttachment(t *testing.T) {
+	email := Email{
+		To:          []string{"bob@gmail.com"},
+		Subject:     "Your certificate",
+		Body:        "Attached.",
+		Attachments: []string{filepath.Join(t.TempDir(), "missing.pdf")},
+	}
+	_, err := email.Message("alice@gmail.com")
+	assert.Error(t, err)
+}
+
+func TestValidateHeader(t *testing.T) {
+	assert.NoError(t, ValidateHeader("X-Ticket-Id", "1234"))
+	assert.Error(t, ValidateHeader("", "1234"))
+	assert.Error(t, ValidateHeader("X Ticket", "1234"))
+	assert.Error(t, ValidateHeader("X-Ticket:", "1234"))
+	assert.Error(t, ValidateHeader("subject", "Hello"))
+	assert.Error(t, ValidateHeader("MIME-Version", "1.0"))
+	assert.Error(t, ValidateHeader("X-Ticket-Id", "1\r\nBcc: eve@evil.com"))
+}
+
+func TestParsePriority(t *testing.T) {
+	priority, err := ParsePriority("High")
+	assert.NoError(t, err)
+	assert.Equal(t, HighPriority, priority)
+	priority, err = ParsePriority("")
+	assert.NoError(t, err)
+	assert.Equal(t, NormalPriority, priority)
+	priority, err = ParsePriority("low")
+	assert.NoError(t, err)
+	assert.Equal(t, LowPriority, priority)
+	_, err = ParsePriority("urgent")
+	assert.Error(t, err)
+}
+
+func TestMessageNonASCII(t *testing.T) {
+	env, err := newEnvelope(
+		"alice@gmail.com",
+		[]string{
+			"José Müller <jose@gmail.com>",
+			"山田太郎 <taro@gmail.com>",
+		})
+	assert.NoError(t, err)
+	email := Email{Subject: "Réunion annuelle — 年次総会", Body: "Bonjour"}
+	msg, err := readMessage(buildMessage(t, &email, env))
+	assert.NoError(t, err)
+	subject := msg.Header.Get("Subject")
+	assert.True(t, isASCII(subject))
+	decoded, err := new(mime.WordDecoder).DecodeHeader(subject)
+	assert.NoError(t, err)
+	assert.Equal(t, "Réunion annuelle — 年次総会", decoded)
+	assert.True(t, isASCII(msg.Header.Get("To")))
+	to, err := msg.Header.AddressList("To")
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		[]*mail.Address{
+			{Name: "José Müller", Address: "jose@gmail.com"},
+			{Name: "山田太郎", Address: "taro@gmail.com"},
+		},
+		to)
+}
+
+func TestMessageFoldsLongSubject(t *testing.T) {
+	env, err := newEnvelope("alice@gmail.com", []string{"bob@gmail.com"})
+	assert.NoError(t, err)
+	email := Email{
+		Subject: strings.Repeat("Ça va très bien merci ", 10),
+		Body:    "Hi",
+	}
+	message := string(buildMessage(t, &email, env))
+	header, _, _ := strings.Cut(message, "\r\n\r\n")
+	lines := strings.Split(header, "\r\n")
+	assert.Greater(t, len(lines), 6)
+	for _, line := range lines[1:] {
+		if strings.HasPrefix(line, " ") {
+			assert.LessOrEqual(t, len(line), kMaxHeaderLineLength)
+		}
+	}
+	msg, err := readMessage([]byte(message))
+	assert.NoError(t, err)
+	subject := msg.Header.Get("Subject")
+	decoded, err := new(mime.WordDecoder).DecodeHeader(subject)
+	assert.NoError(t, err)
+	assert.Equal(t, email.Subject, decoded)
+}
+
+func TestMessageBodyEncoding(t *testing.T) {
+	env, err := newEnvelope("alice@gmail.com", []string{"bob@gmail.com"})
+	assert.NoError(t, err)
+	bodies := map[string]string{
+		"Hi Bob,\nSee you there.\n":                         "7bit",
+		"Hi José,\nSee you there.\n":                        "quoted-printable",
+		"Hi Bob,\n" + strings.Repeat("long line ", 20):      "quoted-printable",
+		"山田さん、\nパーティーでお会いしましょう。\n":                          "base64",
+		strings.Repeat("Très long paragraphe. ", 50) + "\n": "quoted-printable",
+	}
+	for body, encoding := range bodies {
+		email := Email{Subject: "Hello", Body: body}
+		raw := buildMessage(t, &email, env)
+		msg, err := readMessage(raw)
+		assert.NoError(t, err)
+		assert.Equal(
+			t, encoding, msg.Header.Get("Content-Transfer-Encoding"))
+		for _, line := range strings.Split(string(raw), "\r\n") {
+			assert.LessOrEqual(t, len(line), kMaxLineLength, body)
+		}
+		assert.Equal(t, body, decodeBody(t, msg))
+	}
+}
+
+var kTestDate = time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+
+func buildMessage(t *testing.T, email *Email, env *envelope) []byte {
+	result, err := email.message(env, kTestDate, "<1234@gmail.com>")
+	assert.NoError(t, err)
+	return result
+}
+
+func decodeBase64Part(t *testing.T, part *multipart.Part) string {
+	assert.Equal(t, "base64", part.Header.Get("Content-Transfer-Encoding"))
+	data, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func readMessage(raw []byte) (*mail.Message, error) {
+	return mail.ReadMessage(bytes.NewReader(raw))
+}
+
+func decodeBody(t *testing.T, msg *mail.Message) string {
+	var r io.Reader = msg.Body
+	switch msg.Header.Get("Content-Transfer-Encoding") {
+	case "quoted-printable":
+		r = quotedprintable.NewReader(r)
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, r)
+	}
+	body, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	return strings.ReplaceAll(string(body), "\r\n", "\n")
+}
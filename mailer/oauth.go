@@ -0,0 +1,112 @@
+package mailer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OAuthCredentials are what a Mailer needs to get OAuth access tokens
+// on its own.
+type OAuthCredentials struct {
+
+	// The OAuth client ID and secret from the provider's developer
+	// console.
+	ClientId     string
+	ClientSecret string
+
+	// The long lived token from when the account owner first granted
+	// access. The Mailer trades it for short lived access tokens. If
+	// empty, the Mailer gets access tokens with the client credentials
+	// alone, which only NewGraphApi supports.
+	RefreshToken string
+}
+
+// OAuthError is an error that an OAuth token endpoint returns, for
+// instance when a refresh token has been revoked.
+type OAuthError struct {
+
+	// The error code e.g invalid_grant
+	Code string
+
+	// The human readable description, if any
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return fmt.Sprintf("oauth: %s %s", e.Code, e.Description)
+}
+
+// oauthToken hands out access tokens refreshing them as they expire.
+type oauthToken struct {
+	credentials OAuthCredentials
+	tokenUrl    string
+	scope       string
+	client      *http.Client
+	access      string
+	expiry      time.Time
+}
+
+// Get returns a current access token refreshing it if it has expired
+// or is about to.
+func (o *oauthToken) Get() (string, error) {
+	if o.access != "" && time.Until(o.expiry) > time.Minute {
+		return o.access, nil
+	}
+	if err := o.refresh(); err != nil {
+		return "", err
+	}
+	return o.access, nil
+}
+
+// Invalidate forgets the current access token, for instance when the
+// server rejects it.
+func (o *oauthToken) Invalidate() {
+	o.access = ""
+}
+
+func (o *oauthToken) refresh() error {
+	form := url.Values{
+		"client_id":     {o.credentials.ClientId},
+		"client_secret": {o.credentials.ClientSecret},
+	}
+	if o.credentials.RefreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", o.credentials.RefreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	if o.scope != "" {
+		form.Set("scope", o.scope)
+	}
+	resp, err := o.client.Post(
+		o.tokenUrl,
+		"application/x-www-form-urlencoded",
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("oauth: %s: %v", resp.Status, err)
+	}
+	if token.Error != "" {
+		return &OAuthError{
+			Code: token.Error, Description: token.ErrorDescription}
+	}
+	if token.AccessToken == "" {
+		return fmt.Errorf("oauth: %s: no access token", resp.Status)
+	}
+	o.access = token.AccessToken
+	o.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return nil
+}
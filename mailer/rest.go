@@ -0,0 +1,101 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// ApiError is an error that a REST API such as the Gmail API returns.
+type ApiError struct {
+
+	// The API e.g "gmail api"
+	Api string
+
+	// The HTTP status code e.g 403
+	StatusCode int
+
+	// The error message from the API
+	Message string
+}
+
+func (e *ApiError) Error() string {
+	return fmt.Sprintf("%s: %d %s", e.Api, e.StatusCode, e.Message)
+}
+
+// restApi sends messages by posting them to a REST API that takes an
+// OAuth access token.
+type restApi struct {
+	name        string
+	url         string
+	contentType string
+	encode      func(msg []byte) ([]byte, error)
+	token       *oauthToken
+	client      *http.Client
+	logger      *slog.Logger
+}
+
+// Send sends msg. The API takes the recipients from the headers of msg
+// rather than from env. If the API rejects the access token, Send gets
+// a new one and tries once more.
+func (r *restApi) Send(env *envelope, msg []byte) error {
+	body, err := r.encode(msg)
+	if err != nil {
+		return err
+	}
+	err = r.send(body)
+	if apiErr, ok := err.(*ApiError); ok &&
+		apiErr.StatusCode == http.StatusUnauthorized {
+		r.logger.Debug(r.name + " access token rejected; refreshing")
+		r.token.Invalidate()
+		err = r.send(body)
+	}
+	return err
+}
+
+// Check gets an access token. REST APIs don't say whether the sender
+// is allowed without sending.
+func (r *restApi) Check(env *envelope) error {
+	_, err := r.token.Get()
+	return err
+}
+
+// Close does nothing as REST APIs are stateless.
+func (r *restApi) Close() {
+}
+
+func (r *restApi) send(body []byte) error {
+	accessToken, err := r.token.Get()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", r.contentType)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	var apiErr struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&apiErr)
+	message := apiErr.Error.Message
+	if message == "" {
+		message = http.StatusText(resp.StatusCode)
+	}
+	return &ApiError{Api: r.name, StatusCode: resp.StatusCode, Message: message}
+}
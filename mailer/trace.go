@@ -0,0 +1,65 @@
+package mailer
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+)
+
+// LevelTrace is the slog level at which the mailer logs the SMTP
+// conversation line by line.
+const LevelTrace = slog.LevelDebug - 4
+
+// traceConn logs the SMTP conversation passing through it at LevelTrace.
+// traceConn must sit inside TLS to see the conversation. If it wraps an
+// encrypted connection, traceConn logs nothing. traceConn redacts
+// credentials.
+type traceConn struct {
+	net.Conn
+	logger    *slog.Logger
+	encrypted bool
+
+	// The protocol to log the conversation as. The default is smtp.
+	protocol string
+}
+
+func (t *traceConn) Read(b []byte) (int, error) {
+	n, err := t.Conn.Read(b)
+	t.trace("S", b[:n])
+	return n, err
+}
+
+func (t *traceConn) Write(b []byte) (int, error) {
+	t.trace("C", b)
+	return t.Conn.Write(b)
+}
+
+func (t *traceConn) trace(direction string, b []byte) {
+	if t.encrypted || len(b) == 0 {
+		return
+	}
+	if !t.logger.Enabled(context.Background(), LevelTrace) {
+		return
+	}
+	lines := strings.Split(strings.TrimRight(string(b), "\r\n"), "\r\n")
+	for _, line := range lines {
+		if strings.HasPrefix(strings.ToUpper(line), "AUTH ") {
+			line = "AUTH <redacted>"
+		}
+		if tag, command, ok := strings.Cut(line, " "); ok &&
+			strings.HasPrefix(strings.ToUpper(command), "LOGIN ") {
+			line = tag + " LOGIN <redacted>"
+		}
+		protocol := t.protocol
+		if protocol == "" {
+			protocol = "smtp"
+		}
+		t.logger.Log(
+			context.Background(),
+			LevelTrace,
+			protocol,
+			"dir", direction,
+			"line", line)
+	}
+}
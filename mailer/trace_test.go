@@ -0,0 +1,78 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrace(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.Close()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(
+		&buf, &slog.HandlerOptions{Level: LevelTrace}))
+	m := newTestMailer(server, Logger(logger))
+	assert.NoError(t, <-m.SendFuture(Email{
+		To: []string{"bob@example.com"}, Subject: "Hello", Body: "Hi"}))
+	m.Shutdown()
+	log := buf.String()
+	assert.Contains(t, log, `msg="opening smtp session"`)
+	assert.Contains(t, log, `dir=C line="MAIL FROM:<alice@example.com>`)
+	assert.Contains(t, log, `dir=S line="250 OK"`)
+	assert.Contains(t, log, `line="AUTH <redacted>"`)
+	assert.NotContains(t, log, "AUTH PLAIN ")
+	assert.Contains(t, log, `msg=sent to=[bob@example.com]`)
+}
+
+func TestTraceSTARTTLS(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.Close()
+	tlsServer := httptest.NewTLSServer(nil)
+	defer tlsServer.Close()
+	server.SetTLS(tlsServer.TLS)
+	roots := x509.NewCertPool()
+	roots.AddCert(tlsServer.Certificate())
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(
+		&buf, &slog.HandlerOptions{Level: LevelTrace}))
+	m := newTestMailer(
+		server,
+		Logger(logger),
+		optionFunc(func(s *mailerSettings) {
+			s.TLSConfig = &tls.Config{ServerName: "127.0.0.1", RootCAs: roots}
+		}))
+	assert.NoError(t, <-m.SendFuture(Email{
+		To: []string{"bob@example.com"}, Subject: "Hello", Body: "Hi"}))
+	m.Shutdown()
+	assert.Equal(t, []bool{true}, server.Encrypted())
+	log := buf.String()
+	assert.Contains(t, log, `dir=C line=STARTTLS`)
+	assert.Contains(t, log, `msg="smtp session encrypted with STARTTLS"`)
+	assert.Contains(t, log, `dir=C line="MAIL FROM:<alice@example.com>`)
+	assert.Contains(t, log, `dir=C line="RCPT TO:<bob@example.com>"`)
+	assert.Contains(t, log, `dir=C line=DATA`)
+	assert.Contains(t, log, `dir=S line="250 OK"`)
+	assert.Contains(t, log, `line="AUTH <redacted>"`)
+	assert.NotContains(t, log, "AUTH PLAIN ")
+}
+
+func TestTraceOffAtInfo(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.Close()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	m := newTestMailer(server, Logger(logger))
+	assert.NoError(t, <-m.SendFuture(Email{
+		To: []string{"bob@example.com"}, Subject: "Hello", Body: "Hi"}))
+	m.Shutdown()
+	log := buf.String()
+	assert.Contains(t, log, `msg=sent`)
+	assert.NotContains(t, log, "dir=")
+	assert.NotContains(t, log, "opening smtp session")
+}
@@ -0,0 +1,51 @@
+package merge
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Anonymize returns a CsvFile like this instance but with the name and
+// email columns and any columns in others replaced with pseudonyms.
+// Equal values get equal pseudonyms, ignoring case, so duplicates stay
+// duplicates. Pseudonyms come from an HMAC of each value with key, so
+// the same key always gives the same pseudonyms. If keepDomains is true,
+// emails keep their real domains; otherwise they end in example.com.
+// Empty values stay empty.
+func (c *CsvFile) Anonymize(
+	key []byte, keepDomains bool, others ...string) *CsvFile {
+	result := *c
+	result.Rows = make([]CsvRow, 0, len(c.Rows))
+	for _, row := range c.Rows {
+		newRow := row.Clone()
+		if name := row.Name(); name != "" {
+			newRow[Name] = "Person " + pseudonym(key, name)
+		}
+		if email := row.Email(); email != "" {
+			newRow[Email] = anonymizeEmail(key, email, keepDomains)
+		}
+		for _, column := range others {
+			if value := row[column]; value != "" {
+				newRow[column] = column + "-" + pseudonym(key, value)
+			}
+		}
+		result.Rows = append(result.Rows, newRow)
+	}
+	return &result
+}
+
+func anonymizeEmail(key []byte, email string, keepDomains bool) string {
+	domain := "example.com"
+	if at := strings.LastIndex(email, "@"); keepDomains && at != -1 {
+		domain = email[at+1:]
+	}
+	return "user-" + pseudonym(key, email) + "@" + domain
+}
+
+func pseudonym(key []byte, value string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(value))))
+	return hex.EncodeToString(mac.Sum(nil))[:10]
+}
@@ -0,0 +1,38 @@
+package merge
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnonymize(t *testing.T) {
+	r := strings.NewReader(`email,name,pet
+alice@gmail.com,Alice,Rufus
+bob@club.org,Bob,Rufus
+Alice@Gmail.com,Alice,
+`)
+	csv, err := readCsv(r)
+	assert.NoError(t, err)
+	key := []byte("secret")
+	anon := csv.Anonymize(key, false, "pet")
+	assert.Len(t, anon.Rows, 3)
+	assert.Equal(t, anon.Rows[0].Name(), anon.Rows[2].Name())
+	assert.Equal(t, anon.Rows[0].Email(), anon.Rows[2].Email())
+	assert.Regexp(t, `^user-[0-9a-f]{10}@example\.com$`, anon.Rows[0].Email())
+	assert.Regexp(t, `^Person [0-9a-f]{10}$`, anon.Rows[0].Name())
+	assert.NotEqual(t, anon.Rows[0].Name(), anon.Rows[1].Name())
+	assert.Equal(t, "", anon.Rows[2]["pet"])
+	assert.Equal(t, anon.Rows[0]["pet"], anon.Rows[1]["pet"])
+	assert.NotEqual(t, "Rufus", anon.Rows[0]["pet"])
+	assert.Equal(t, "alice@gmail.com", csv.Rows[0].Email())
+
+	kept := csv.Anonymize(key, true)
+	assert.True(t, strings.HasSuffix(kept.Rows[1].Email(), "@club.org"))
+	assert.Equal(t, "Rufus", kept.Rows[0]["pet"])
+	assert.Equal(t, anon.Rows[0].Name(), kept.Rows[0].Name())
+
+	other := csv.Anonymize([]byte("other"), false)
+	assert.NotEqual(t, anon.Rows[0].Email(), other.Rows[0].Email())
+}
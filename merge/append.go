@@ -0,0 +1,86 @@
+package merge
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/keep94/mailmerge/filelock"
+)
+
+// AppendTo appends the rows of this instance to the CSV file at path
+// creating the file if needed. The file must have the same columns as
+// this instance, but they may be in a different order; AppendTo writes
+// the rows in the file's column order. AppendTo ignores the BOM option
+// when the file already has content. AppendTo holds an exclusive lock on
+// the file while appending. Like Write, AppendTo returns an error without
+// touching the file if a header appears twice, in this instance or in the
+// file, or if a row has no name or email.
+func (c *CsvFile) AppendTo(path string, options ...WriteOption) error {
+	if err := c.check(); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := filelock.Lock(f); err != nil {
+		return err
+	}
+	headers, err := csv.NewReader(skipBOM(f)).Read()
+	if err == io.EOF {
+		return c.write(f, options...)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if err := checkHeaders(headers); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if !sameColumns(headers, c.Headers) {
+		return fmt.Errorf(
+			"%s: columns %v don't match %v", path, headers, c.Headers)
+	}
+	if err := endWithNewline(f); err != nil {
+		return err
+	}
+	settings := newWriteSettings(options)
+	settings.BOM = false
+	csvWriter, err := newCsvWriter(f, headers, settings)
+	if err != nil {
+		return err
+	}
+	for _, row := range c.Rows {
+		if err := csvWriter.Write(row); err != nil {
+			return err
+		}
+	}
+	return csvWriter.Flush()
+}
+
+// sameColumns returns true if x and y have the same columns in any
+// order. sameColumns expects neither x nor y to repeat a column.
+func sameColumns(x, y []string) bool {
+	return slices.Equal(
+		slices.Sorted(slices.Values(x)), slices.Sorted(slices.Values(y)))
+}
+
+// endWithNewline seeks to the end of f adding a newline if f doesn't
+// already end with one.
+func endWithNewline(f *os.File) error {
+	if _, err := f.Seek(-1, io.SeekEnd); err != nil {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err := io.ReadFull(f, last); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	_, err := f.Write([]byte{'\n'})
+	return err
+}
@@ -0,0 +1,58 @@
+package merge
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendTo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "responses.csv")
+	first, err := readCsv(strings.NewReader(`email,name
+alice@gmail.com,alice
+`))
+	assert.NoError(t, err)
+	assert.NoError(t, first.AppendTo(path))
+	second, err := readCsv(strings.NewReader(`name,email
+bob,bob@gmail.com
+`))
+	assert.NoError(t, err)
+	// Take away the final newline to make sure AppendTo adds it back.
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	content = []byte(strings.TrimSuffix(string(content), "\n"))
+	assert.NoError(t, os.WriteFile(path, content, 0644))
+	assert.NoError(t, second.AppendTo(path, BOM()))
+	content, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	expected := `email,name
+alice@gmail.com,alice
+bob@gmail.com,bob
+`
+	assert.Equal(t, expected, string(content))
+	other, err := readCsv(strings.NewReader(`email,name,going
+carl@gmail.com,carl,yes
+`))
+	assert.NoError(t, err)
+	assert.Error(t, other.AppendTo(path))
+}
+
+func TestAppendToDuplicateHeaders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "responses.csv")
+	original := "email,name,name\nalice@gmail.com,alice,al\n"
+	assert.NoError(t, os.WriteFile(path, []byte(original), 0644))
+	rows := &CsvFile{
+		Headers: []string{Email, Email, Name},
+		Rows:    []CsvRow{{Email: "bob@gmail.com", Name: "bob"}},
+	}
+	assert.EqualError(t, rows.AppendTo(path), "duplicate header: email")
+	rows.Headers = []string{Email, Name}
+	assert.EqualError(
+		t, rows.AppendTo(path), path+": duplicate header: name")
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, original, string(content))
+}
@@ -0,0 +1,64 @@
+package merge
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// kRenderCacheSize is how many bytes of rendered output each template
+// keeps.
+const kRenderCacheSize = 10 << 20
+
+// renderCache holds what a template rendered keyed by the values of the
+// columns it references. Once the cache holds maxBytes, it stops adding
+// output.
+type renderCache struct {
+	maxBytes int
+	mu       sync.Mutex
+	outputs  map[string]string
+	size     int
+}
+
+func newRenderCache(maxBytes int) *renderCache {
+	return &renderCache{maxBytes: maxBytes, outputs: make(map[string]string)}
+}
+
+// Get returns the output for key and true or false if there is none.
+func (c *renderCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	output, ok := c.outputs[key]
+	return output, ok
+}
+
+// Add adds output for key if there is room.
+func (c *renderCache) Add(key, output string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.outputs[key]; ok {
+		return
+	}
+	if c.size+len(key)+len(output) <= c.maxBytes {
+		c.outputs[key] = output
+		c.size += len(key) + len(output)
+	}
+}
+
+// cacheKey returns the cache key for the values of fields in row. A
+// missing column and an empty one get different keys because templates
+// render them differently.
+func cacheKey(fields []string, row CsvRow) string {
+	var builder strings.Builder
+	for _, field := range fields {
+		value, ok := row.Lookup(field)
+		if !ok {
+			builder.WriteString("-;")
+			continue
+		}
+		builder.WriteString(strconv.Itoa(len(value)))
+		builder.WriteByte(':')
+		builder.WriteString(value)
+	}
+	return builder.String()
+}
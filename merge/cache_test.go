@@ -0,0 +1,81 @@
+package merge
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderCache(t *testing.T) {
+	tmpl, err := ParseTemplate(
+		"cached", `{{if .vip}}Dear {{firstName .name}}{{else}}Hi{{end}}`)
+	assert.NoError(t, err)
+	assert.NotNil(t, tmpl.cache)
+	rows := []CsvRow{
+		{"name": "Alice Smith", "vip": "y", "city": "Boston"},
+		{"name": "Alice Smith", "vip": "y", "city": "Denver"},
+		{"name": "Bob Jones", "vip": "", "city": "Boston"},
+		{"name": "Bob Jones", "city": "Boston"},
+	}
+	var results []string
+	for _, row := range rows {
+		result, err := tmpl.Execute(row)
+		assert.NoError(t, err)
+		results = append(results, result)
+	}
+	assert.Equal(t, []string{"Dear Alice", "Dear Alice", "Hi", "Hi"}, results)
+
+	// city isn't referenced so the first two rows share an entry while
+	// an empty vip and a missing one get separate entries.
+	assert.Len(t, tmpl.cache.outputs, 3)
+}
+
+func TestRenderCacheNotUsed(t *testing.T) {
+	tmpl, err := ParseTemplate("simple", "Dear {{.name}}")
+	assert.NoError(t, err)
+	assert.Nil(t, tmpl.cache)
+	tmpl, err = ParseTemplate("dot", "{{salutation .}}")
+	assert.NoError(t, err)
+	assert.Nil(t, tmpl.cache)
+	count := 0
+	tmpl, err = ParseTemplate(
+		"funcs",
+		"{{if .vip}}{{next}}{{end}}",
+		WithFuncs(map[string]any{"next": func() int {
+			count++
+			return count
+		}}))
+	assert.NoError(t, err)
+	assert.Nil(t, tmpl.cache)
+	for i := 0; i < 2; i++ {
+		result, err := tmpl.Execute(CsvRow{"vip": "y"})
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprint(i+1), result)
+	}
+}
+
+func TestRenderCacheFull(t *testing.T) {
+	cache := newRenderCache(10)
+	cache.Add("a", "12345")
+	cache.Add("b", "12345")
+	_, ok := cache.Get("a")
+	assert.True(t, ok)
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+}
+
+func BenchmarkExecuteCached(b *testing.B) {
+	tmpl, err := ParseTemplate(
+		"bench",
+		"{{if .vip}}Dear {{firstName .name}}{{else}}Hello{{end}},\n"+
+			"See you in {{.city}}.\n")
+	if err != nil {
+		b.Fatal(err)
+	}
+	row := CsvRow{"name": "Alice Smith", "vip": "y", "city": "Boston"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tmpl.Execute(row)
+	}
+}
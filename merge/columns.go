@@ -0,0 +1,56 @@
+package merge
+
+import (
+	"slices"
+)
+
+// WithHeaderOrder returns a CsvFile like this instance but with the
+// headers in names first, in that order, followed by the other headers
+// in their current order. WithHeaderOrder ignores names that are not
+// headers.
+func (c *CsvFile) WithHeaderOrder(names ...string) *CsvFile {
+	headers := make([]string, 0, len(c.Headers))
+	for _, name := range names {
+		if slices.Contains(c.Headers, name) &&
+			!slices.Contains(headers, name) {
+			headers = append(headers, name)
+		}
+	}
+	for _, header := range c.Headers {
+		if !slices.Contains(headers, header) {
+			headers = append(headers, header)
+		}
+	}
+	result := *c
+	result.Headers = headers
+	return &result
+}
+
+// WithSortedHeaders returns a CsvFile like this instance but with the
+// headers sorted alphabetically.
+func (c *CsvFile) WithSortedHeaders() *CsvFile {
+	result := *c
+	result.Headers = slices.Sorted(slices.Values(c.Headers))
+	return &result
+}
+
+// WithColumn returns a CsvFile like this instance with a column called
+// name whose value in each row is value(row). The new column goes at
+// position among the headers; a position out of range puts it last. If
+// there already is a column called name, WithColumn replaces its values
+// and leaves it where it is.
+func (c *CsvFile) WithColumn(
+	name string, position int, value func(CsvRow) string) *CsvFile {
+	result := *c
+	if !slices.Contains(c.Headers, name) {
+		if position < 0 || position > len(c.Headers) {
+			position = len(c.Headers)
+		}
+		result.Headers = slices.Insert(slices.Clone(c.Headers), position, name)
+	}
+	result.Rows = make([]CsvRow, 0, len(c.Rows))
+	for _, row := range c.Rows {
+		result.Rows = append(result.Rows, row.With(name, value(row)))
+	}
+	return &result
+}
@@ -0,0 +1,51 @@
+package merge
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithHeaderOrder(t *testing.T) {
+	csv, err := readCsv(strings.NewReader(csvStr))
+	assert.NoError(t, err)
+	var builder strings.Builder
+	assert.NoError(
+		t, csv.WithHeaderOrder("going", "bogus", "name").write(&builder))
+	expected := `going,name,email
+no,alice,alice@gmail.com
+yes,bob,bob@gmail.com
+yes,charlie,charlie@gmail.com
+`
+	assert.Equal(t, expected, builder.String())
+	assert.Equal(
+		t,
+		[]string{"email", "going", "name"},
+		csv.WithSortedHeaders().Headers)
+	assert.Equal(t, []string{"email", "name", "going"}, csv.Headers)
+}
+
+func TestWithColumn(t *testing.T) {
+	csv, err := readCsv(strings.NewReader(csvStr))
+	assert.NoError(t, err)
+	upper := func(row CsvRow) string {
+		return strings.ToUpper(row.Name())
+	}
+	var builder strings.Builder
+	assert.NoError(t, csv.WithColumn("shout", 1, upper).write(&builder))
+	expected := `email,shout,name,going
+alice@gmail.com,ALICE,alice,no
+bob@gmail.com,BOB,bob,yes
+charlie@gmail.com,CHARLIE,charlie,yes
+`
+	assert.Equal(t, expected, builder.String())
+	assert.Equal(
+		t,
+		[]string{"email", "name", "going", "shout"},
+		csv.WithColumn("shout", 99, upper).Headers)
+	replaced := csv.WithColumn("name", 0, upper)
+	assert.Equal(t, csv.Headers, replaced.Headers)
+	assert.Equal(t, "ALICE", replaced.Rows[0].Name())
+	assert.Equal(t, "alice", csv.Rows[0].Name())
+}
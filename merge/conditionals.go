@@ -0,0 +1,39 @@
+package merge
+
+import (
+	"fmt"
+	"strings"
+)
+
+// kNoValues are the column values that ifCol treats as no.
+var kNoValues = toSet("", "n", "no", "false", "0")
+
+// ifCol returns true if column in row says yes, that is it isn't empty,
+// n, no, false, or 0 ignoring case and surrounding space. ifCol returns
+// an error if row has no such column so that typos don't go unnoticed.
+func ifCol(row CsvRow, column string) (bool, error) {
+	value, ok := row.Lookup(column)
+	if !ok {
+		return false, fmt.Errorf("ifCol: no column %s", column)
+	}
+	_, no := kNoValues[strings.ToLower(strings.TrimSpace(value))]
+	return !no, nil
+}
+
+// choose returns the text paired with value in cases, which alternate
+// between values and texts. If there's an extra case at the end, choose
+// returns it when no value matches; otherwise choose returns the empty
+// string. Values match ignoring case and surrounding space.
+func choose(value string, cases ...string) string {
+	value = strings.TrimSpace(value)
+	for len(cases) >= 2 {
+		if strings.EqualFold(value, strings.TrimSpace(cases[0])) {
+			return cases[1]
+		}
+		cases = cases[2:]
+	}
+	if len(cases) == 1 {
+		return cases[0]
+	}
+	return ""
+}
@@ -0,0 +1,33 @@
+package merge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConditionals(t *testing.T) {
+	tmpl, err := ParseTemplate(
+		"conditionals",
+		`{{if going .}}Come{{else}}Miss{{end}}`+
+			`{{if ifCol . "vegetarian"}} veggie{{end}}`+
+			` {{choose .size "S" "small" "M" "medium" "large"}}`)
+	assert.NoError(t, err)
+	body, err := tmpl.Execute(
+		CsvRow{"going": "y", "vegetarian": "Yes", "size": " m"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Come veggie medium", body)
+	body, err = tmpl.Execute(
+		CsvRow{"going": "no", "vegetarian": "FALSE", "size": "XL"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Miss large", body)
+	_, err = tmpl.Execute(CsvRow{"going": "y", "size": "S"})
+	assert.Error(t, err)
+}
+
+func TestChoose(t *testing.T) {
+	assert.Equal(t, "b", choose("2", "1", "a", "2", "b"))
+	assert.Equal(t, "", choose("3", "1", "a", "2", "b"))
+	assert.Equal(t, "other", choose("3", "1", "a", "other"))
+	assert.Equal(t, "", choose("3"))
+}
@@ -0,0 +1,153 @@
+package merge
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// kIsoDateLayouts are the ISO 8601 layouts formatDate accepts.
+var kIsoDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// dateNames are the names of months and weekdays in a language.
+type dateNames struct {
+	Months      [12]string
+	ShortMonths [12]string
+	Days        [7]string
+	ShortDays   [7]string
+}
+
+// kDateNames are the month and weekday names of the languages Language
+// accepts besides English.
+var kDateNames = map[string]*dateNames{
+	"es": {
+		Months: [12]string{
+			"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio",
+			"agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		ShortMonths: [12]string{
+			"ene", "feb", "mar", "abr", "may", "jun",
+			"jul", "ago", "sept", "oct", "nov", "dic"},
+		Days: [7]string{
+			"domingo", "lunes", "martes", "miércoles", "jueves", "viernes",
+			"sábado"},
+		ShortDays: [7]string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+	},
+	"fr": {
+		Months: [12]string{
+			"janvier", "février", "mars", "avril", "mai", "juin", "juillet",
+			"août", "septembre", "octobre", "novembre", "décembre"},
+		ShortMonths: [12]string{
+			"janv.", "févr.", "mars", "avr.", "mai", "juin",
+			"juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		Days: [7]string{
+			"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi",
+			"samedi"},
+		ShortDays: [7]string{
+			"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."},
+	},
+	"de": {
+		Months: [12]string{
+			"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli",
+			"August", "September", "Oktober", "November", "Dezember"},
+		ShortMonths: [12]string{
+			"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni",
+			"Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+		Days: [7]string{
+			"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag",
+			"Freitag", "Samstag"},
+		ShortDays: [7]string{"So.", "Mo.", "Di.", "Mi.", "Do.", "Fr.", "Sa."},
+	},
+}
+
+// formatDate formats the ISO 8601 date in value using layout, a Go
+// layout like "2 January 2006". If zone is given, e.g "Europe/Paris",
+// dates with an offset are converted to zone and dates without one are
+// taken to be in zone already. An empty value formats as the empty
+// string.
+func formatDate(
+	names *dateNames, value, layout string, zone ...string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	if len(zone) > 1 {
+		return "", fmt.Errorf("formatDate: at most one time zone")
+	}
+	location := time.UTC
+	if len(zone) == 1 {
+		var err error
+		location, err = time.LoadLocation(zone[0])
+		if err != nil {
+			return "", fmt.Errorf("formatDate: %v", err)
+		}
+	}
+	t, err := parseDate(value, location)
+	if err != nil {
+		return "", err
+	}
+	if len(zone) == 1 {
+		t = t.In(location)
+	}
+	return formatTime(t, layout, names), nil
+}
+
+func parseDate(value string, location *time.Location) (time.Time, error) {
+	for _, layout := range kIsoDateLayouts {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf(
+		"formatDate: %q is not a date like 2006-01-02 or 2006-01-02 15:04",
+		value)
+}
+
+// formatTime is t.Format(layout) but with month and weekday names from
+// names. names may be nil for English.
+func formatTime(t time.Time, layout string, names *dateNames) string {
+	if names == nil {
+		return t.Format(layout)
+	}
+	var builder strings.Builder
+	for layout != "" {
+		index, token := nextNameToken(layout)
+		if index < 0 {
+			builder.WriteString(t.Format(layout))
+			break
+		}
+		builder.WriteString(t.Format(layout[:index]))
+		switch token {
+		case "January":
+			builder.WriteString(names.Months[t.Month()-1])
+		case "Jan":
+			builder.WriteString(names.ShortMonths[t.Month()-1])
+		case "Monday":
+			builder.WriteString(names.Days[t.Weekday()])
+		case "Mon":
+			builder.WriteString(names.ShortDays[t.Weekday()])
+		}
+		layout = layout[index+len(token):]
+	}
+	return builder.String()
+}
+
+// nextNameToken returns where the first month or weekday name in layout
+// is and which one it is or -1 if there is none.
+func nextNameToken(layout string) (int, string) {
+	resultIndex, resultToken := -1, ""
+	// Longer tokens first so that January wins over Jan.
+	for _, token := range []string{"January", "Monday", "Jan", "Mon"} {
+		index := strings.Index(layout, token)
+		if index >= 0 && (resultIndex < 0 || index < resultIndex) {
+			resultIndex, resultToken = index, token
+		}
+	}
+	return resultIndex, resultToken
+}
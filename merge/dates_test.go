@@ -0,0 +1,53 @@
+package merge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatDate(t *testing.T) {
+	tmpl, err := ParseTemplate(
+		"date", `{{formatDate .when "Monday 2 January 2006 15:04" .zone}}`)
+	assert.NoError(t, err)
+	body, err := tmpl.Execute(
+		CsvRow{"when": "2025-07-14T18:30:00Z", "zone": "Europe/Paris"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Monday 14 July 2025 20:30", body)
+	body, err = tmpl.Execute(
+		CsvRow{"when": "2025-07-14 18:30", "zone": "Europe/Paris"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Monday 14 July 2025 18:30", body)
+	_, err = tmpl.Execute(CsvRow{"when": "July 14", "zone": "Europe/Paris"})
+	assert.Error(t, err)
+	_, err = tmpl.Execute(CsvRow{"when": "2025-07-14", "zone": "Mars/Base"})
+	assert.Error(t, err)
+}
+
+func TestFormatDateNoZone(t *testing.T) {
+	tmpl, err := ParseTemplate("date", `{{formatDate .when "Jan 2, 2006"}}`)
+	assert.NoError(t, err)
+	body, err := tmpl.Execute(CsvRow{"when": "2025-03-05"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Mar 5, 2025", body)
+	body, err = tmpl.Execute(CsvRow{"when": " "})
+	assert.NoError(t, err)
+	assert.Equal(t, "", body)
+}
+
+func TestFormatLanguage(t *testing.T) {
+	tmpl, err := ParseTemplate(
+		"date",
+		`{{formatDate .when "Monday 2 January 2006 (Mon, Jan)"}}`,
+		Language("fr"))
+	assert.NoError(t, err)
+	body, err := tmpl.Execute(CsvRow{"when": "2025-08-09"})
+	assert.NoError(t, err)
+	assert.Equal(t, "samedi 9 août 2025 (sam., août)", body)
+	tmpl, err = ParseTemplate(
+		"date", `{{formatDate .when "2. January 2006"}}`, Language("de"))
+	assert.NoError(t, err)
+	body, err = tmpl.Execute(CsvRow{"when": "2025-03-01"})
+	assert.NoError(t, err)
+	assert.Equal(t, "1. März 2025", body)
+}
@@ -0,0 +1,103 @@
+package merge
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// text/template writes locations as name:line or name:line:column with
+// the column counting bytes from 0. html/template does the same after
+// html/template: with no space.
+var kTemplateLocation = regexp.MustCompile(
+	`(?s)^(?:template: |html/template:)(.+?):(\d+)(?::(\d+))?: (.*)$`)
+
+// TemplateError is an error in a template along with where in the
+// template it happened.
+type TemplateError struct {
+
+	// The name of the template e.g invite.txt
+	Name string
+
+	// The line of the error starting at 1
+	Line int
+
+	// The column of the error starting at 1 or 0 if unknown
+	Column int
+
+	// The text of the line of the error or empty if unknown
+	Source string
+
+	// What went wrong
+	Message string
+
+	// The error from text/template
+	Err error
+}
+
+func (e *TemplateError) Error() string {
+	if e.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", e.Name, e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.Name, e.Line, e.Message)
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// Snippet returns the line of the error followed, if the column is
+// known, by a line with a caret under the column, e.g
+//
+//	Dear {{.name | titel}},
+//	       ^
+//
+// Snippet returns the empty string if the line is unknown.
+func (e *TemplateError) Snippet() string {
+	if e.Source == "" {
+		return ""
+	}
+	if e.Column == 0 || e.Column > len(e.Source)+1 {
+		return e.Source
+	}
+	var caret strings.Builder
+	for _, ch := range e.Source[:e.Column-1] {
+		if ch == '\t' {
+			caret.WriteRune('\t')
+		} else {
+			caret.WriteRune(' ')
+		}
+	}
+	caret.WriteRune('^')
+	return e.Source + "\n" + caret.String()
+}
+
+// locateError returns err as a *TemplateError if err comes from
+// text/template with a location. Otherwise locateError returns err
+// unchanged. sources holds the text of each template keyed by name.
+func locateError(err error, sources map[string]string) error {
+	if err == nil {
+		return nil
+	}
+	var located *TemplateError
+	if errors.As(err, &located) {
+		return err
+	}
+	match := kTemplateLocation.FindStringSubmatch(err.Error())
+	if match == nil {
+		return err
+	}
+	result := &TemplateError{Name: match[1], Message: match[4], Err: err}
+	result.Line, _ = strconv.Atoi(match[2])
+	lines := strings.Split(sources[result.Name], "\n")
+	if result.Line >= 1 && result.Line <= len(lines) {
+		result.Source = strings.TrimSuffix(lines[result.Line-1], "\r")
+	}
+	if match[3] != "" {
+		column, _ := strconv.Atoi(match[3])
+		result.Column = column + 1
+	}
+	return result
+}
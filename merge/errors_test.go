@@ -0,0 +1,80 @@
+package merge
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateErrorParse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invite.txt")
+	assert.NoError(t, os.WriteFile(
+		path, []byte("Hello,\n\nDear {{.name}\n"), 0644))
+	_, err := ParseTemplateFile(path)
+	var templateErr *TemplateError
+	assert.True(t, errors.As(err, &templateErr))
+	assert.Equal(t, "invite.txt", templateErr.Name)
+	assert.Equal(t, 3, templateErr.Line)
+	assert.Equal(t, 0, templateErr.Column)
+	assert.Equal(t, "Dear {{.name}", templateErr.Snippet())
+	assert.Regexp(t, `^invite.txt:3: `, err.Error())
+}
+
+func TestTemplateErrorExecute(t *testing.T) {
+	tmpl, err := ParseTemplate(
+		"invite.txt", "Hi,\n\tDear {{index .name 5}}\n")
+	assert.NoError(t, err)
+	_, err = tmpl.Execute(CsvRow{"name": "Bob"})
+	var templateErr *TemplateError
+	assert.True(t, errors.As(err, &templateErr))
+	assert.Equal(t, 2, templateErr.Line)
+	assert.Equal(t, 9, templateErr.Column)
+	assert.Regexp(t, `^invite.txt:2:9: executing`, err.Error())
+	assert.Equal(
+		t, "\tDear {{index .name 5}}\n\t       ^", templateErr.Snippet())
+}
+
+func TestTemplateErrorKeepsPanicError(t *testing.T) {
+	tmpl, err := ParseTemplate(
+		"invite.txt",
+		"{{boom}}",
+		WithFuncs(map[string]any{"boom": func() string { panic("boom") }}))
+	assert.NoError(t, err)
+	_, err = tmpl.Execute(CsvRow{})
+	var panicErr *PanicError
+	assert.True(t, errors.As(err, &panicErr))
+	var templateErr *TemplateError
+	assert.True(t, errors.As(err, &templateErr))
+}
+
+func TestTemplateErrorLayout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "layout.txt")
+	assert.NoError(t, os.WriteFile(
+		path, []byte("{{block \"body\" .}}{{end}}\n{{index .club 9}}"), 0644))
+	set, err := NewTemplateSet(path)
+	assert.NoError(t, err)
+	_, err = set.Parse("invite.txt", "{{define \"body\"}}\n{{.name}\n{{end}}")
+	var templateErr *TemplateError
+	assert.True(t, errors.As(err, &templateErr))
+	assert.Equal(t, "invite.txt", templateErr.Name)
+	assert.Equal(t, "{{.name}", templateErr.Snippet())
+	invite, err := set.Parse("invite.txt", "{{define \"body\"}}Hi{{end}}")
+	assert.NoError(t, err)
+	_, err = invite.Execute(CsvRow{"club": "Garden"})
+	assert.True(t, errors.As(err, &templateErr))
+	assert.Equal(t, "layout.txt", templateErr.Name)
+	assert.Equal(t, 2, templateErr.Line)
+	assert.Equal(t, "{{index .club 9}}\n  ^", templateErr.Snippet())
+}
+
+func TestTemplateErrorOther(t *testing.T) {
+	err := errors.New("boom")
+	assert.Same(t, err, locateError(err, nil))
+	assert.Nil(t, locateError(nil, nil))
+	_, err = ParseTemplateFile(filepath.Join(t.TempDir(), "missing.txt"))
+	var templateErr *TemplateError
+	assert.False(t, errors.As(err, &templateErr))
+}
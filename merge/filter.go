@@ -0,0 +1,271 @@
+package merge
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"strings"
+)
+
+// Filter selects rows of a CsvFile.
+type Filter interface {
+
+	// String describes this filter e.g "limit 100".
+	String() string
+
+	// Select returns a CsvFile like csvFile with only the rows this
+	// filter keeps.
+	Select(csvFile *CsvFile) (*CsvFile, error)
+}
+
+// FilterChain is a Filter that applies Filters in order.
+type FilterChain []Filter
+
+func (f FilterChain) String() string {
+	parts := make([]string, 0, len(f))
+	for _, filter := range f {
+		parts = append(parts, filter.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (f FilterChain) Select(csvFile *CsvFile) (*CsvFile, error) {
+	for _, filter := range f {
+		var err error
+		csvFile, err = filter.Select(csvFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filter, err)
+		}
+	}
+	return csvFile, nil
+}
+
+// GoingFilter keeps the rows of people going.
+func GoingFilter() Filter {
+	return rowFilter{name: "going", keep: CsvRow.Going}
+}
+
+// EmailsFilter keeps the rows with emails in emails.
+func EmailsFilter(emails EmailSet) Filter {
+	return rowFilter{
+		name: "emails " + emails.String(),
+		keep: func(row CsvRow) bool {
+			return emails.Contains(row.Email())
+		},
+	}
+}
+
+// NoEmailsFilter keeps the rows with emails not in emails.
+func NoEmailsFilter(emails EmailSet) Filter {
+	return rowFilter{
+		name: "noemails " + emails.String(),
+		keep: func(row CsvRow) bool {
+			return !emails.Contains(row.Email())
+		},
+	}
+}
+
+// TagsFilter keeps the rows with any of tags.
+func TagsFilter(tags TagSet) Filter {
+	return rowFilter{
+		name: "tags " + tags.String(),
+		keep: func(row CsvRow) bool {
+			return row.Tags().Intersects(tags)
+		},
+	}
+}
+
+// NoTagsFilter keeps the rows with none of tags.
+func NoTagsFilter(tags TagSet) Filter {
+	return rowFilter{
+		name: "notags " + tags.String(),
+		keep: func(row CsvRow) bool {
+			return !row.Tags().Intersects(tags)
+		},
+	}
+}
+
+// ScreenFilter keeps the rows with emails that pass ScreenEmail.
+func ScreenFilter() Filter {
+	return rowFilter{
+		name: "screen",
+		keep: func(row CsvRow) bool {
+			return ScreenEmail(row.Email()) == ""
+		},
+	}
+}
+
+// DedupeFilter keeps the first row for each email ignoring case.
+func DedupeFilter() Filter {
+	return dedupeFilter{}
+}
+
+// LimitFilter keeps the first n rows.
+func LimitFilter(n int) Filter {
+	return limitFilter(n)
+}
+
+// ShardFilter keeps the rows in shard of count shards so that several
+// machines can each send part of a list without overlap. shard goes
+// from 1 to count. Which shard a row is in depends only on its email
+// ignoring case, not on the order of the rows, so the same email
+// always lands in the same shard, even if the list changes.
+func ShardFilter(shard, count int) (Filter, error) {
+	if count < 1 || shard < 1 || shard > count {
+		return nil, fmt.Errorf(
+			"shard must be from 1 to %d: %d", max(count, 1), shard)
+	}
+	return rowFilter{
+		name: fmt.Sprintf("shard %d/%d", shard, count),
+		keep: func(row CsvRow) bool {
+			h := fnv.New32a()
+			h.Write([]byte(strings.ToLower(strings.TrimSpace(row.Email()))))
+			return int(h.Sum32()%uint32(count)) == shard-1
+		},
+	}, nil
+}
+
+// WhereFilter keeps the rows for which expr is true. expr is a template
+// pipeline without the braces e.g `eq .city "Boston"`. expr may use the
+// same functions as ParseTemplate.
+func WhereFilter(expr string, options ...TemplateOption) (Filter, error) {
+	tmpl, err := ParseTemplate("where", "{{"+expr+"}}", options...)
+	if err != nil {
+		return nil, err
+	}
+	return whereFilter{expr: expr, tmpl: tmpl}, nil
+}
+
+type rowFilter struct {
+	name string
+	keep func(CsvRow) bool
+}
+
+func (r rowFilter) String() string {
+	return r.name
+}
+
+func (r rowFilter) Select(csvFile *CsvFile) (*CsvFile, error) {
+	return csvFile.Select(r.keep), nil
+}
+
+type dedupeFilter struct {
+}
+
+func (d dedupeFilter) String() string {
+	return "dedupe"
+}
+
+func (d dedupeFilter) Select(csvFile *CsvFile) (*CsvFile, error) {
+	seen := make(EmailSet)
+	return csvFile.Select(func(row CsvRow) bool {
+		email := strings.ToLower(row.Email())
+		if seen.Contains(email) {
+			return false
+		}
+		seen.Add(email)
+		return true
+	}), nil
+}
+
+type limitFilter int
+
+func (l limitFilter) String() string {
+	return fmt.Sprintf("limit %d", int(l))
+}
+
+func (l limitFilter) Select(csvFile *CsvFile) (*CsvFile, error) {
+	if len(csvFile.Rows) <= int(l) {
+		return csvFile, nil
+	}
+	result := *csvFile
+	result.Rows = csvFile.Rows[:max(int(l), 0)]
+	result.Lines = csvFile.Lines[:min(max(int(l), 0), len(csvFile.Lines))]
+	return &result, nil
+}
+
+type whereFilter struct {
+	expr string
+	tmpl *Template
+}
+
+func (w whereFilter) String() string {
+	return "where " + w.expr
+}
+
+func (w whereFilter) Select(csvFile *CsvFile) (*CsvFile, error) {
+	var err error
+	result := csvFile.Select(func(row CsvRow) bool {
+		if err != nil {
+			return false
+		}
+		var value string
+		value, err = w.tmpl.Execute(row)
+		if err != nil {
+			err = fmt.Errorf("%s: %v", row.Email(), err)
+			return false
+		}
+		switch strings.TrimSpace(value) {
+		case "true":
+			return true
+		case "false":
+			return false
+		}
+		err = fmt.Errorf(
+			"%s: got %q instead of true or false", row.Email(), value)
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Explanation tells whether a FilterChain kept a row.
+type Explanation struct {
+
+	// The row
+	Row CsvRow
+
+	// The line in the source file where Row starts or 0 if unknown.
+	Line int
+
+	// The filter that removed Row or nil if the chain kept it.
+	RemovedBy Filter
+}
+
+// Explain runs csvFile through this chain and returns, for each row of
+// csvFile in order, the filter that removed it, if any. Explain expects
+// the filters to return the very rows they keep rather than copies.
+func (f FilterChain) Explain(csvFile *CsvFile) ([]Explanation, error) {
+	result := make([]Explanation, len(csvFile.Rows))
+	positions := make(map[uintptr]int, len(csvFile.Rows))
+	for i, row := range csvFile.Rows {
+		result[i].Row = row
+		result[i].Line = csvFile.Line(i)
+		positions[rowId(row)] = i
+	}
+	current := csvFile
+	for _, filter := range f {
+		next, err := filter.Select(current)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filter, err)
+		}
+		kept := make(map[uintptr]struct{}, len(next.Rows))
+		for _, row := range next.Rows {
+			kept[rowId(row)] = struct{}{}
+		}
+		for _, row := range current.Rows {
+			if _, ok := kept[rowId(row)]; !ok {
+				result[positions[rowId(row)]].RemovedBy = filter
+			}
+		}
+		current = next
+	}
+	return result, nil
+}
+
+// rowId identifies row by the address of its map.
+func rowId(row CsvRow) uintptr {
+	return reflect.ValueOf(row).Pointer()
+}
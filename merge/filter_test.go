@@ -0,0 +1,146 @@
+package merge
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const kFilterCsv = `email,name,going,city
+alice@gmail.com,alice,no,Boston
+bob@gmail.com,bob,yes,Boston
+info@club.org,club,yes,Boston
+Bob@gmail.com,bobby,yes,Boston
+carl@gmail.com,carl,yes,Denver
+dana@gmail.com,dana,yes,Boston
+`
+
+func TestFilterChain(t *testing.T) {
+	csv, err := readCsv(strings.NewReader(kFilterCsv))
+	assert.NoError(t, err)
+	where, err := WhereFilter(`eq .city "Boston"`)
+	assert.NoError(t, err)
+	chain := FilterChain{
+		GoingFilter(),
+		ScreenFilter(),
+		DedupeFilter(),
+		where,
+		NoEmailsFilter(NewEmailSet("dana@gmail.com")),
+		LimitFilter(5),
+	}
+	assert.Equal(
+		t,
+		`going, screen, dedupe, where eq .city "Boston", `+
+			`noemails dana@gmail.com, limit 5`,
+		chain.String())
+	selected, err := chain.Select(csv)
+	assert.NoError(t, err)
+	assert.Equal(t, []CsvRow{csv.Rows[1]}, selected.Rows)
+	assert.Len(t, csv.Rows, 6)
+}
+
+func TestLimitAndEmailsFilter(t *testing.T) {
+	csv, err := readCsv(strings.NewReader(kFilterCsv))
+	assert.NoError(t, err)
+	selected, err := LimitFilter(2).Select(csv)
+	assert.NoError(t, err)
+	assert.Equal(t, csv.Rows[:2], selected.Rows)
+	selected, err = EmailsFilter(
+		NewEmailSet("carl@gmail.com,dana@gmail.com")).Select(csv)
+	assert.NoError(t, err)
+	assert.Equal(t, csv.Rows[4:], selected.Rows)
+}
+
+func TestTagsFilter(t *testing.T) {
+	csv, err := readCsv(strings.NewReader(`email,name,tags
+alice@gmail.com,alice,"vip, speaker"
+bob@gmail.com,bob,speaker
+carl@gmail.com,carl,
+dana@gmail.com,dana,"VIP,declined"
+`))
+	assert.NoError(t, err)
+	tags := TagsFilter(NewTagSet("vip,speaker"))
+	assert.Equal(t, "tags speaker, vip", tags.String())
+	selected, err := tags.Select(csv)
+	assert.NoError(t, err)
+	assert.Equal(
+		t, []CsvRow{csv.Rows[0], csv.Rows[1], csv.Rows[3]}, selected.Rows)
+	selected, err = FilterChain{
+		tags, NoTagsFilter(NewTagSet("declined"))}.Select(csv)
+	assert.NoError(t, err)
+	assert.Equal(t, csv.Rows[:2], selected.Rows)
+}
+
+func TestShardFilter(t *testing.T) {
+	csv, err := readCsv(strings.NewReader(kFilterCsv))
+	assert.NoError(t, err)
+	seen := make(map[string]int)
+	for shard := 1; shard <= 3; shard++ {
+		filter, err := ShardFilter(shard, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("shard %d/3", shard), filter.String())
+		selected, err := filter.Select(csv)
+		assert.NoError(t, err)
+		for _, row := range selected.Rows {
+			seen[strings.ToLower(row.Email())] = shard
+		}
+		reversed := *csv
+		reversed.Rows = slices.Clone(csv.Rows)
+		slices.Reverse(reversed.Rows)
+		again, err := filter.Select(&reversed)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, selected.Rows, again.Rows)
+	}
+	assert.Len(t, seen, 5)
+	bob, err := ShardFilter(seen["bob@gmail.com"], 3)
+	assert.NoError(t, err)
+	selected, err := bob.Select(csv)
+	assert.NoError(t, err)
+	assert.Contains(t, selected.Rows, csv.Rows[3])
+	_, err = ShardFilter(0, 3)
+	assert.Error(t, err)
+	_, err = ShardFilter(4, 3)
+	assert.Error(t, err)
+	_, err = ShardFilter(1, 0)
+	assert.Error(t, err)
+}
+
+func TestWhereFilterErrors(t *testing.T) {
+	csv, err := readCsv(strings.NewReader(kFilterCsv))
+	assert.NoError(t, err)
+	_, err = WhereFilter(`eq .city`)
+	assert.NoError(t, err)
+	_, err = WhereFilter(`(eq .city "Boston"`)
+	assert.Error(t, err)
+	where, err := WhereFilter(`.city`)
+	assert.NoError(t, err)
+	_, err = where.Select(csv)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "instead of true or false")
+}
+
+func TestExplain(t *testing.T) {
+	csv, err := readCsv(strings.NewReader(kFilterCsv))
+	assert.NoError(t, err)
+	chain := FilterChain{GoingFilter(), DedupeFilter(), LimitFilter(2)}
+	explanations, err := chain.Explain(csv)
+	assert.NoError(t, err)
+	assert.Len(t, explanations, 6)
+	removedBy := make([]string, 0, len(explanations))
+	for i, explanation := range explanations {
+		assert.Equal(t, csv.Rows[i], explanation.Row)
+		assert.Equal(t, i+2, explanation.Line)
+		if explanation.RemovedBy == nil {
+			removedBy = append(removedBy, "")
+		} else {
+			removedBy = append(removedBy, explanation.RemovedBy.String())
+		}
+	}
+	assert.Equal(
+		t,
+		[]string{"going", "", "", "dedupe", "limit 2", "limit 2"},
+		removedBy)
+}
@@ -0,0 +1,81 @@
+package merge
+
+// TemplateFunc describes a function that templates may use beyond the
+// text/template builtins.
+type TemplateFunc struct {
+
+	// The function name
+	Name string `json:"name"`
+
+	// How to call it e.g {{firstName .name}}
+	Example string `json:"example"`
+
+	// What it does
+	Doc string `json:"doc"`
+}
+
+// kTemplateFuncs describes the functions in templateSettings.funcs in
+// the order they appear there.
+var kTemplateFuncs = []TemplateFunc{
+	{
+		Name:    "firstName",
+		Example: "{{firstName .name}}",
+		Doc:     "The first name in a full name",
+	},
+	{
+		Name:    "lastName",
+		Example: "{{lastName .name}}",
+		Doc:     "The last name in a full name",
+	},
+	{
+		Name:    "title",
+		Example: "{{title .name}}",
+		Doc:     "The title in a full name such as Dr.",
+	},
+	{
+		Name:    "salutation",
+		Example: "{{salutation .}}",
+		Doc: "The salutation column, otherwise Dear and the first name, " +
+			"otherwise the generic salutation",
+	},
+	{
+		Name:    "formatDate",
+		Example: `{{formatDate .eventDate "2 January 2006" "Europe/Paris"}}`,
+		Doc: "Formats an ISO 8601 date with a Go layout, optionally in a " +
+			"time zone",
+	},
+	{
+		Name:    "going",
+		Example: "{{if going .}}See you there{{end}}",
+		Doc:     "True if the person is going to the event",
+	},
+	{
+		Name:    "ifCol",
+		Example: `{{if ifCol . "vegetarian"}}Veggie meal{{end}}`,
+		Doc:     "True unless a column is empty, n, no, false, or 0",
+	},
+	{
+		Name:    "choose",
+		Example: `{{choose .size "S" "small" "M" "medium" "large"}}`,
+		Doc: "The text paired with a column's value with an optional " +
+			"default",
+	},
+	{
+		Name:    "number",
+		Example: "{{number .guests 0}}",
+		Doc: "Formats a number with the given decimals, optionally in " +
+			"a language",
+	},
+	{
+		Name:    "money",
+		Example: `{{money .amountDue "EUR" "de"}}`,
+		Doc: "Formats an amount in an ISO 4217 currency, optionally in " +
+			"a language",
+	},
+}
+
+// TemplateFuncs returns the functions that templates may use beyond the
+// text/template builtins. Callers must not change the returned slice.
+func TemplateFuncs() []TemplateFunc {
+	return kTemplateFuncs
+}
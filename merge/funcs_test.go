@@ -0,0 +1,20 @@
+package merge
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	var names []string
+	for _, f := range TemplateFuncs() {
+		names = append(names, f.Name)
+		assert.NotEmpty(t, f.Example)
+		assert.NotEmpty(t, f.Doc)
+	}
+	funcs := newTemplateSettings(nil).funcs()
+	assert.ElementsMatch(t, slices.Collect(maps.Keys(funcs)), names)
+}
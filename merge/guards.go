@@ -0,0 +1,138 @@
+package merge
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Guard checks a CsvFile before anything is sent as a safety net against
+// a bad export e.g one where most first names are missing.
+type Guard interface {
+
+	// String describes this guard e.g "maxrecipients 2000".
+	String() string
+
+	// Check returns an error saying what is wrong if csvFile fails this
+	// guard.
+	Check(csvFile *CsvFile) error
+}
+
+// GuardList is a Guard that checks every guard in it.
+type GuardList []Guard
+
+func (g GuardList) String() string {
+	parts := make([]string, 0, len(g))
+	for _, guard := range g {
+		parts = append(parts, guard.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Check returns an error listing every guard that csvFile fails or nil
+// if it passes them all.
+func (g GuardList) Check(csvFile *CsvFile) error {
+	var errs []error
+	for _, guard := range g {
+		if err := guard.Check(csvFile); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", guard, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MaxRecipientsGuard fails if csvFile has more than n rows.
+func MaxRecipientsGuard(n int) Guard {
+	return maxRecipientsGuard(n)
+}
+
+// MaxEmptyGuard fails if more than percent percent of the rows have an
+// empty value in column or if there is no such column. Values that are
+// only whitespace count as empty.
+func MaxEmptyGuard(column string, percent float64) Guard {
+	return maxEmptyGuard{column: column, percent: percent}
+}
+
+// NoDuplicatesGuard fails if an email appears more than once ignoring
+// case.
+func NoDuplicatesGuard() Guard {
+	return noDuplicatesGuard{}
+}
+
+type maxRecipientsGuard int
+
+func (m maxRecipientsGuard) String() string {
+	return fmt.Sprintf("maxrecipients %d", int(m))
+}
+
+func (m maxRecipientsGuard) Check(csvFile *CsvFile) error {
+	if len(csvFile.Rows) > int(m) {
+		return fmt.Errorf("%d recipients", len(csvFile.Rows))
+	}
+	return nil
+}
+
+type maxEmptyGuard struct {
+	column  string
+	percent float64
+}
+
+func (m maxEmptyGuard) String() string {
+	return fmt.Sprintf("maxempty %s %g%%", m.column, m.percent)
+}
+
+func (m maxEmptyGuard) Check(csvFile *CsvFile) error {
+	if !slices.Contains(csvFile.Headers, m.column) {
+		return fmt.Errorf("no %s column", m.column)
+	}
+	if len(csvFile.Rows) == 0 {
+		return nil
+	}
+	var empty int
+	for _, row := range csvFile.Rows {
+		if strings.TrimSpace(row[m.column]) == "" {
+			empty++
+		}
+	}
+	percent := 100.0 * float64(empty) / float64(len(csvFile.Rows))
+	if percent > m.percent {
+		return fmt.Errorf(
+			"%d of %d rows (%.1f%%) have no %s",
+			empty,
+			len(csvFile.Rows),
+			percent,
+			m.column)
+	}
+	return nil
+}
+
+type noDuplicatesGuard struct {
+}
+
+func (n noDuplicatesGuard) String() string {
+	return "noduplicates"
+}
+
+func (n noDuplicatesGuard) Check(csvFile *CsvFile) error {
+	counts := make(map[string]int)
+	var order []string
+	for _, row := range csvFile.Rows {
+		email := strings.ToLower(strings.TrimSpace(row.Email()))
+		if counts[email] == 0 {
+			order = append(order, email)
+		}
+		counts[email]++
+	}
+	var duplicates []string
+	for _, email := range order {
+		if counts[email] > 1 {
+			duplicates = append(
+				duplicates, fmt.Sprintf("%s %d times", email, counts[email]))
+		}
+	}
+	if len(duplicates) > 0 {
+		return errors.New(strings.Join(duplicates, ", "))
+	}
+	return nil
+}
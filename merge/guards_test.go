@@ -0,0 +1,58 @@
+package merge
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const kGuardCsv = `email,name,firstName
+alice@gmail.com,Alice,Alice
+bob@gmail.com,Bob,
+carl@gmail.com,Carl,Carl
+Bob@gmail.com,Bobby,
+`
+
+func TestGuardList(t *testing.T) {
+	csv, err := readCsv(strings.NewReader(kGuardCsv))
+	assert.NoError(t, err)
+	guards := GuardList{
+		MaxRecipientsGuard(3),
+		MaxEmptyGuard("firstName", 40),
+		NoDuplicatesGuard(),
+	}
+	assert.Equal(
+		t,
+		"maxrecipients 3, maxempty firstName 40%, noduplicates",
+		guards.String())
+	err = guards.Check(csv)
+	assert.EqualError(
+		t,
+		err,
+		"maxrecipients 3: 4 recipients\n"+
+			"maxempty firstName 40%: 2 of 4 rows (50.0%) have no firstName\n"+
+			"noduplicates: bob@gmail.com 2 times")
+}
+
+func TestGuardListPasses(t *testing.T) {
+	csv, err := readCsv(strings.NewReader(kGuardCsv))
+	assert.NoError(t, err)
+	csv = csv.Select(func(row CsvRow) bool {
+		return row.Email() != "Bob@gmail.com"
+	})
+	guards := GuardList{
+		MaxRecipientsGuard(3),
+		MaxEmptyGuard("firstName", 40),
+		NoDuplicatesGuard(),
+	}
+	assert.NoError(t, guards.Check(csv))
+	assert.NoError(t, GuardList{}.Check(csv))
+}
+
+func TestMaxEmptyGuardNoColumn(t *testing.T) {
+	csv, err := readCsv(strings.NewReader(kGuardCsv))
+	assert.NoError(t, err)
+	assert.EqualError(
+		t, MaxEmptyGuard("city", 5).Check(csv), "no city column")
+}
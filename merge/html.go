@@ -0,0 +1,26 @@
+package merge
+
+import (
+	htmltemplate "html/template"
+	"text/template"
+)
+
+// htmlTemplate returns tmpl and the templates associated with it as an
+// html/template so that what each action writes is escaped for where it
+// lands in the page: text, attribute, URL, script, or style. htmlTemplate
+// gives html/template copies of the parse trees because html/template
+// rewrites them and templates cloned from a layout share them.
+func htmlTemplate(
+	tmpl *template.Template, funcs template.FuncMap) (
+	*htmltemplate.Template, error) {
+	result := htmltemplate.New(tmpl.Name()).Funcs(htmltemplate.FuncMap(funcs))
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		if _, err := result.AddParseTree(t.Name(), t.Tree.Copy()); err != nil {
+			return nil, err
+		}
+	}
+	return result.Lookup(tmpl.Name()), nil
+}
@@ -0,0 +1,119 @@
+package merge
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscapeHTML(t *testing.T) {
+	tmpl, err := ParseTemplate(
+		"invite.html",
+		`<p>{{salutation .}},</p>{{$city := .city}}
+<p>{{if .city}}See you in <b>{{$city}}</b>{{else}}{{.name}}{{end}}</p>
+{{range $i, $e := .}}{{end}}{{.note | html}}`,
+		EscapeHTML())
+	assert.NoError(t, err)
+	body, err := tmpl.Execute(CsvRow{
+		"name":       "Tom & Jerry",
+		"salutation": "Dear R&D",
+		"city":       "<Paris>",
+		"note":       "a&b",
+	})
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		"<p>Dear R&amp;D,</p>\n"+
+			"<p>See you in <b>&lt;Paris&gt;</b></p>\na&amp;b",
+		body)
+	body, err = tmpl.Execute(CsvRow{
+		"name": "Tom & Jerry", "salutation": "Hi", "note": ""})
+	assert.NoError(t, err)
+	assert.Equal(t, "<p>Hi,</p>\n<p>Tom &amp; Jerry</p>\n", body)
+}
+
+func TestEscapeHTMLSimpleTemplate(t *testing.T) {
+	tmpl, err := ParseTemplate("hi.html", "<p>Hi {{.name}}</p>", EscapeHTML())
+	assert.NoError(t, err)
+	body, err := tmpl.Execute(CsvRow{"name": "Tom & Jerry"})
+	assert.NoError(t, err)
+	assert.Equal(t, "<p>Hi Tom &amp; Jerry</p>", body)
+	plain, err := ParseTemplate("hi.txt", "Hi {{.name}}")
+	assert.NoError(t, err)
+	body, err = plain.Execute(CsvRow{"name": "Tom & Jerry"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Hi Tom & Jerry", body)
+}
+
+func TestEscapeHTMLLayout(t *testing.T) {
+	dir := t.TempDir()
+	layoutPath := filepath.Join(dir, "layout.html")
+	assert.NoError(t, os.WriteFile(
+		layoutPath,
+		[]byte(`<h1>{{.club}}</h1>{{block "body" .}}{{end}}`),
+		0644))
+	set, err := NewTemplateSet(layoutPath, EscapeHTML())
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		invite, err := set.Parse(
+			"invite.html", `{{define "body"}}<p>{{.name}}</p>{{end}}`)
+		assert.NoError(t, err)
+		body, err := invite.Execute(
+			CsvRow{"club": "R&D", "name": "Tom & Jerry"})
+		assert.NoError(t, err)
+		assert.Equal(t, "<h1>R&amp;D</h1><p>Tom &amp; Jerry</p>", body)
+	}
+}
+
+func TestEscapeHTMLContexts(t *testing.T) {
+	tmpl, err := ParseTemplate(
+		"links.html",
+		`<a href="{{.site}}">{{.name}}</a>`+
+			`<script>var name = {{.name}};</script>`,
+		EscapeHTML())
+	assert.NoError(t, err)
+	body, err := tmpl.Execute(CsvRow{
+		"name": `Tom "&" Jerry`,
+		"site": "javascript:alert(1)",
+	})
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		`<a href="#ZgotmplZ">Tom &#34;&amp;&#34; Jerry</a>`+
+			`<script>var name = "Tom \"\u0026\" Jerry";</script>`,
+		body)
+	body, err = tmpl.Execute(CsvRow{
+		"name": "Al", "site": "https://example.com/?a=1&b=2"})
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		`<a href="https://example.com/?a=1&amp;b=2">Al</a>`+
+			`<script>var name = "Al";</script>`,
+		body)
+}
+
+func TestEscapeHTMLEmpty(t *testing.T) {
+	tmpl, err := ParseTemplate("empty.html", "", EscapeHTML())
+	assert.NoError(t, err)
+	body, err := tmpl.Execute(CsvRow{"name": "Al"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", body)
+}
+
+func TestEscapeHTMLError(t *testing.T) {
+	tmpl, err := ParseTemplate(
+		"bad.html",
+		"<p>\n<script>\nvar x = '{{if .a}}'{{end}};</script>",
+		EscapeHTML())
+	assert.NoError(t, err)
+	_, err = tmpl.Execute(CsvRow{"name": "Al", "a": "y"})
+	var templateErr *TemplateError
+	if assert.True(t, errors.As(err, &templateErr)) {
+		assert.Equal(t, "bad.html", templateErr.Name)
+		assert.Equal(t, 3, templateErr.Line)
+		assert.Contains(t, templateErr.Message, "different contexts")
+	}
+}
@@ -0,0 +1,60 @@
+package merge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// csvFileJson is the JSON form of a CsvFile.
+type csvFileJson struct {
+	Headers []string            `json:"headers"`
+	Rows    []map[string]string `json:"rows"`
+}
+
+// MarshalJSON encodes this instance as an object with a headers array,
+// which keeps the column order, and a rows array of objects.
+func (c *CsvFile) MarshalJSON() ([]byte, error) {
+	rows := make([]map[string]string, 0, len(c.Rows))
+	for _, row := range c.Rows {
+		rows = append(rows, row)
+	}
+	return json.Marshal(&csvFileJson{Headers: c.Headers, Rows: rows})
+}
+
+// UnmarshalJSON decodes what MarshalJSON encodes. Columns missing from a
+// row are empty. Like ReadCsv, UnmarshalJSON requires the name and email
+// of each row.
+func (c *CsvFile) UnmarshalJSON(data []byte) error {
+	var decoded csvFileJson
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	if len(decoded.Headers) == 0 {
+		return errors.New("headers missing")
+	}
+	known := make(map[string]struct{}, len(decoded.Headers))
+	for _, header := range decoded.Headers {
+		known[header] = struct{}{}
+	}
+	rows := make([]CsvRow, 0, len(decoded.Rows))
+	for index, decodedRow := range decoded.Rows {
+		row := make(CsvRow, len(decoded.Headers))
+		for _, header := range decoded.Headers {
+			row[header] = decodedRow[header]
+		}
+		for column := range decodedRow {
+			if _, ok := known[column]; !ok {
+				return fmt.Errorf("Row %d: %s is not a header", index, column)
+			}
+		}
+		if row.Name() == "" || row.Email() == "" {
+			return fmt.Errorf(
+				"Row %d: name and email columns must be present", index)
+		}
+		rows = append(rows, row)
+	}
+	c.Headers = decoded.Headers
+	c.Rows = rows
+	return nil
+}
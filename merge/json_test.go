@@ -0,0 +1,43 @@
+package merge
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	csv, err := readCsv(strings.NewReader(csvStr))
+	assert.NoError(t, err)
+	data, err := json.Marshal(csv)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(
+		string(data), `{"headers":["email","name","going"],"rows":[`))
+	var decoded CsvFile
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, csv.Headers, decoded.Headers)
+	assert.Equal(t, csv.Rows, decoded.Rows)
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	var decoded CsvFile
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"headers": ["name", "email", "pet"],
+		"rows": [{"name": "Alice", "email": "alice@gmail.com"}]}`),
+		&decoded))
+	assert.Equal(
+		t,
+		[]CsvRow{{"name": "Alice", "email": "alice@gmail.com", "pet": ""}},
+		decoded.Rows)
+	assert.Error(t, json.Unmarshal([]byte(`{
+		"headers": ["name", "email"],
+		"rows": [{"name": "Alice", "email": "a@gmail.com", "pet": "Rufus"}]}`),
+		&decoded))
+	assert.Error(t, json.Unmarshal([]byte(`{
+		"headers": ["name", "email"],
+		"rows": [{"name": "Alice"}]}`),
+		&decoded))
+	assert.Error(t, json.Unmarshal([]byte(`{"rows": []}`), &decoded))
+}
@@ -0,0 +1,103 @@
+package merge
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+	"text/template/parse"
+)
+
+// TemplateSet parses campaign templates that extend a base layout so
+// that the header, footer, and branding every email shares live in one
+// place. The layout marks the parts campaigns fill in with block, e.g
+//
+//	{{block "body" .}}{{end}}
+//	--
+//	The Garden Club
+//
+// A campaign template defines the blocks it overrides and nothing
+// else, e.g
+//
+//	{{define "body"}}Dear {{.name}}, ...{{end}}
+//
+// Blocks a campaign leaves out keep what the layout has in them.
+type TemplateSet struct {
+	layout   *template.Template
+	source   string
+	settings *templateSettings
+}
+
+// NewTemplateSet returns a TemplateSet for the layout in layoutPath.
+// NewTemplateSet accepts the same options as ParseTemplateFile, and the
+// layout and campaign templates may use the same functions.
+func NewTemplateSet(
+	layoutPath string, options ...TemplateOption) (*TemplateSet, error) {
+	settings := newTemplateSettings(options)
+	content, err := os.ReadFile(layoutPath)
+	if err != nil {
+		return nil, err
+	}
+	name := filepath.Base(layoutPath)
+	layout, err := template.New(name).
+		Funcs(settings.funcs()).
+		Parse(string(content))
+	if err != nil {
+		return nil, locateError(err, map[string]string{name: string(content)})
+	}
+	if layout.Tree == nil || parse.IsEmptyTree(layout.Tree.Root) {
+		return nil, fmt.Errorf("%s: layout is empty", layout.Name())
+	}
+	return &TemplateSet{
+		layout: layout, source: string(content), settings: settings}, nil
+}
+
+// ParseFile compiles the campaign template in templatePath against the
+// layout. ParseFile returns an error if the campaign template has text
+// outside of define or defines a block that the layout doesn't have, as
+// that text would never show up in emails.
+func (s *TemplateSet) ParseFile(templatePath string) (*Template, error) {
+	content, err := os.ReadFile(templatePath)
+	if err != nil {
+		return nil, err
+	}
+	return s.Parse(filepath.Base(templatePath), string(content))
+}
+
+// Parse works like ParseFile but compiles text as a campaign template
+// called name.
+func (s *TemplateSet) Parse(name, text string) (*Template, error) {
+	sources := map[string]string{name: text, s.layout.Name(): s.source}
+	campaign, err := template.New(name).Funcs(s.settings.funcs()).Parse(text)
+	if err != nil {
+		return nil, locateError(err, sources)
+	}
+	if campaign.Tree != nil && !parse.IsEmptyTree(campaign.Tree.Root) {
+		return nil, fmt.Errorf(
+			"%s: text outside of define; campaign templates extending %s "+
+				"may only define blocks",
+			name,
+			s.layout.Name())
+	}
+	result, err := s.layout.Clone()
+	if err != nil {
+		return nil, err
+	}
+	for _, block := range campaign.Templates() {
+		if block.Name() == name {
+			continue
+		}
+		if s.layout.Lookup(block.Name()) == nil {
+			return nil, fmt.Errorf(
+				"%s: %s has no block %q", name, s.layout.Name(), block.Name())
+		}
+		if _, err := result.AddParseTree(block.Name(), block.Tree); err != nil {
+			return nil, err
+		}
+	}
+	result, err = result.AddParseTree(name, s.layout.Tree)
+	if err != nil {
+		return nil, err
+	}
+	return newTemplate(result, sources, s.settings)
+}
@@ -0,0 +1,86 @@
+package merge
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const kLayout = `{{block "greeting" .}}Hello {{firstName .name}},{{end}}
+
+{{block "body" .}}{{end}}
+--
+The Garden Club{{block "footer" .}}{{end}}
+`
+
+func TestTemplateSet(t *testing.T) {
+	set := newTestTemplateSet(t)
+	invite, err := set.Parse(
+		"invite.txt",
+		`{{define "body"}}Join us on {{.date}}.{{end}}
+{{define "footer"}}
+Unsubscribe: reply STOP{{end}}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "invite.txt", invite.Name())
+	body, err := invite.Execute(CsvRow{"name": "Alice Smith", "date": "May 1"})
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		"Hello Alice,\n\nJoin us on May 1.\n--\nThe Garden Club\n"+
+			"Unsubscribe: reply STOP\n",
+		body)
+
+	// Campaigns don't see each other's blocks.
+	reminder, err := set.Parse(
+		"reminder.txt",
+		`{{define "greeting"}}Hi {{.name}},{{end}}
+{{define "body"}}Don't forget!{{end}}`)
+	assert.NoError(t, err)
+	body, err = reminder.Execute(CsvRow{"name": "Bob"})
+	assert.NoError(t, err)
+	assert.Equal(
+		t, "Hi Bob,\n\nDon't forget!\n--\nThe Garden Club\n", body)
+	body, err = invite.Execute(CsvRow{"name": "Bob", "date": "May 1"})
+	assert.NoError(t, err)
+	assert.Contains(t, body, "Hello Bob,\n\nJoin us")
+}
+
+func TestTemplateSetErrors(t *testing.T) {
+	set := newTestTemplateSet(t)
+	_, err := set.Parse("stray.txt", `Dear {{.name}}`)
+	assert.Error(t, err)
+	_, err = set.Parse("typo.txt", `{{define "boddy"}}Hi{{end}}`)
+	assert.Error(t, err)
+	_, err = set.Parse("bad.txt", `{{define "body"}}{{.name}{{end}}`)
+	assert.Error(t, err)
+}
+
+func TestTemplateSetFile(t *testing.T) {
+	dir := t.TempDir()
+	layoutPath := filepath.Join(dir, "layout.txt")
+	invitePath := filepath.Join(dir, "invite.txt")
+	assert.NoError(t, os.WriteFile(layoutPath, []byte(kLayout), 0644))
+	assert.NoError(t, os.WriteFile(
+		invitePath, []byte(`{{define "body"}}See you!{{end}}`), 0644))
+	set, err := NewTemplateSet(layoutPath, GenericSalutation("Hi"))
+	assert.NoError(t, err)
+	invite, err := set.ParseFile(invitePath)
+	assert.NoError(t, err)
+	body, err := invite.Execute(CsvRow{"name": "Carl"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello Carl,\n\nSee you!\n--\nThe Garden Club\n", body)
+	emptyPath := filepath.Join(dir, "empty.txt")
+	assert.NoError(t, os.WriteFile(emptyPath, nil, 0644))
+	_, err = NewTemplateSet(emptyPath)
+	assert.Error(t, err)
+}
+
+func newTestTemplateSet(t *testing.T) *TemplateSet {
+	path := filepath.Join(t.TempDir(), "layout.txt")
+	assert.NoError(t, os.WriteFile(path, []byte(kLayout), 0644))
+	result, err := NewTemplateSet(path)
+	assert.NoError(t, err)
+	return result
+}
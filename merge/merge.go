@@ -1,14 +1,15 @@
 package merge
 
 import (
-	"encoding/csv"
-	"fmt"
+	"bufio"
 	"io"
 	"maps"
 	"os"
 	"slices"
 	"sort"
 	"strings"
+
+	"github.com/keep94/mailmerge/filelock"
 )
 
 const (
@@ -21,13 +22,45 @@ const (
 
 	// The going column.
 	Going = "going"
+
+	// The salutation column
+	Salutation = "salutation"
+
+	// The tags column holds comma separated tags e.g "vip, speaker".
+	Tags = "tags"
+
+	// The priority column holds a whole number. See CsvFile.ByPriority.
+	Priority = "priority"
+
+	// The attachments column holds semicolon separated paths to files
+	// to attach e.g "tickets/alice.pdf; map.pdf".
+	Attachments = "attachments"
+
+	// Columns starting with HeaderPrefix hold custom email headers,
+	// e.g "header:X-Ticket-Id".
+	HeaderPrefix = "header:"
 )
 
 // CsvRow represents a single row of a mail merge CSV file. The keys
 // are the column names; the values are the column values.
-// CsvRow instances are designed to be immutable.
+// CsvRow instances are designed to be immutable. Rows are shared between
+// CsvFile instances, so changing a row in place changes it everywhere.
+// To change a row, use With or Clone to get a new row instead.
 type CsvRow map[string]string
 
+// Clone returns a copy of this row that can be changed without changing
+// this row.
+func (c CsvRow) Clone() CsvRow {
+	return maps.Clone(c)
+}
+
+// With returns a CsvRow like this one but with column set to value.
+func (c CsvRow) With(column, value string) CsvRow {
+	result := c.Clone()
+	result[column] = value
+	return result
+}
+
 // Name returns the person's name
 func (c CsvRow) Name() string {
 	return c[Name]
@@ -38,18 +71,70 @@ func (c CsvRow) Email() string {
 	return c[Email]
 }
 
+// Lookup returns the value of column and true or the empty string and
+// false if this row has no such column. Lookup tells an empty value
+// apart from a missing column.
+func (c CsvRow) Lookup(column string) (string, bool) {
+	value, ok := c[column]
+	return value, ok
+}
+
+// Columns returns the names of the columns in this row sorted.
+func (c CsvRow) Columns() []string {
+	return slices.Sorted(maps.Keys(c))
+}
+
 // Going returns if person is going to the event. True if it does not start
 // with "n" or "N"
 func (c CsvRow) Going() bool {
 	return !strings.HasPrefix(strings.ToLower(c[Going]), "n")
 }
 
+// CustomHeaders returns the custom email headers for this row. The keys
+// are the header names; the values are the header values. Columns with
+// empty values are left out. Returns nil if there are no custom headers.
+func (c CsvRow) CustomHeaders() map[string]string {
+	var result map[string]string
+	for column, value := range c {
+		name, ok := strings.CutPrefix(column, HeaderPrefix)
+		if !ok || value == "" {
+			continue
+		}
+		if result == nil {
+			result = make(map[string]string)
+		}
+		result[strings.TrimSpace(name)] = value
+	}
+	return result
+}
+
+// Attachments returns the paths in the attachments column of this row
+// in order. Empty paths are left out. Returns nil if there are none.
+func (c CsvRow) Attachments() []string {
+	var result []string
+	for _, path := range strings.Split(c[Attachments], ";") {
+		if path = strings.TrimSpace(path); path != "" {
+			result = append(result, path)
+		}
+	}
+	return result
+}
+
+// Tags returns the tags in the tags column of this row.
+func (c CsvRow) Tags() TagSet {
+	return NewTagSet(c[Tags])
+}
+
 // WithNotGoing returns a CsvRow like this one but with the going column
 // set to "n"
 func (c CsvRow) WithNotGoing() CsvRow {
-	result := maps.Clone(c)
-	result[Going] = "n"
-	return result
+	return c.With(Going, "n")
+}
+
+// WithEmail returns a CsvRow like this one but with the email column
+// set to email.
+func (c CsvRow) WithEmail(email string) CsvRow {
+	return c.With(Email, email)
 }
 
 // EmailSet represents a set of emails
@@ -98,6 +183,54 @@ func (e EmailSet) String() string {
 	return strings.Join(emailSlice, ", ")
 }
 
+// TagSet represents a set of tags. Tags ignore case and surrounding
+// spaces.
+type TagSet map[string]struct{}
+
+// NewTagSet returns a new TagSet from comma separated tags. Empty tags
+// are left out.
+func NewTagSet(commaSeparatedTags string) TagSet {
+	result := make(TagSet)
+	for _, tag := range strings.Split(commaSeparatedTags, ",") {
+		result.Add(tag)
+	}
+	return result
+}
+
+// Contains returns true if this instance contains tag.
+func (t TagSet) Contains(tag string) bool {
+	_, ok := t[normalizeTag(tag)]
+	return ok
+}
+
+// Add adds a tag to this instance in place. Add ignores empty tags.
+func (t TagSet) Add(tag string) {
+	tag = normalizeTag(tag)
+	if tag != "" {
+		t[tag] = struct{}{}
+	}
+}
+
+// Intersects returns true if t and other have a tag in common.
+func (t TagSet) Intersects(other TagSet) bool {
+	for tag := range t {
+		if other.Contains(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns this instance as a comma separated list of tags
+// sorted alphabetically.
+func (t TagSet) String() string {
+	return strings.Join(slices.Sorted(maps.Keys(t)), ", ")
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
 // CsvFile represents a mail merge CsvFile.
 type CsvFile struct {
 
@@ -106,6 +239,20 @@ type CsvFile struct {
 
 	// The rows
 	Rows []CsvRow
+
+	// Lines[i] is the line in the source file where Rows[i] starts so
+	// that people can find the row in their spreadsheet. Lines may be
+	// shorter than Rows, for instance when rows are added after reading.
+	Lines []int
+}
+
+// Line returns the line in the source file where the row at index
+// starts or 0 if unknown.
+func (c *CsvFile) Line(index int) int {
+	if index < 0 || index >= len(c.Lines) {
+		return 0
+	}
+	return c.Lines[index]
 }
 
 // SelectEmails returns a CsvFile like this instance that contains
@@ -141,6 +288,14 @@ func (c *CsvFile) SelectGoing() *CsvFile {
 	return &result
 }
 
+// Select returns a CsvFile like this instance that contains only the
+// rows for which f returns true.
+func (c *CsvFile) Select(f func(CsvRow) bool) *CsvFile {
+	result := *c
+	result.sel(f)
+	return &result
+}
+
 // AsEmailSet returns this instance as an EmailSet.
 func (c *CsvFile) AsEmailSet() EmailSet {
 	result := make(EmailSet, len(c.Rows))
@@ -159,24 +314,58 @@ func (c *CsvFile) WithNotGoing() *CsvFile {
 	return &result
 }
 
-// Write writes this instance to a file.
-func (c *CsvFile) Write(path string) error {
-	f, err := os.Create(path)
+// Write writes this instance to a file. Write holds an exclusive lock
+// on the file while writing. Whatever the options, ReadCsv reads back
+// the same headers and rows that Write wrote, commas and quotes
+// included, with each line break within a value as \n. Each row must
+// have a value for every header and no others. Write returns an error
+// without touching the file if ReadCsv couldn't read it back: if a
+// header appears twice or if a row has no name or email.
+func (c *CsvFile) Write(path string, options ...WriteOption) error {
+	if err := c.check(); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
-	return c.write(f)
+	if err := filelock.Lock(f); err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	return c.write(f, options...)
+}
+
+// check returns an error if CsvWriter would refuse to write this
+// instance.
+func (c *CsvFile) check() error {
+	if err := checkHeaders(c.Headers); err != nil {
+		return err
+	}
+	for i, row := range c.Rows {
+		if err := checkRow(i+1, row); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (c *CsvFile) sel(f func(CsvRow) bool) {
 	var result []CsvRow
-	for _, row := range c.Rows {
+	var lines []int
+	for index, row := range c.Rows {
 		if f(row) {
 			result = append(result, row)
+			if index < len(c.Lines) {
+				lines = append(lines, c.Lines[index])
+			}
 		}
 	}
 	c.Rows = result
+	c.Lines = lines
 }
 
 func (c *CsvFile) addGoingColumn() {
@@ -197,58 +386,61 @@ func (c *CsvFile) setNotGoingInEachRow() {
 	c.Rows = result
 }
 
-func (c *CsvFile) write(w io.Writer) error {
-	csvWriter := csv.NewWriter(w)
-	if err := csvWriter.Write(c.Headers); err != nil {
+func (c *CsvFile) write(w io.Writer, options ...WriteOption) error {
+	csvWriter, err := NewCsvWriter(w, c.Headers, options...)
+	if err != nil {
 		return err
 	}
-	csvRow := make([]string, 0, len(c.Headers))
 	for _, row := range c.Rows {
-		for _, header := range c.Headers {
-			csvRow = append(csvRow, row[header])
-		}
-		if err := csvWriter.Write(csvRow); err != nil {
+		if err := csvWriter.Write(row); err != nil {
 			return err
 		}
-		csvRow = csvRow[:0]
 	}
-	csvWriter.Flush()
-	return csvWriter.Error()
+	return csvWriter.Flush()
 }
 
-// ReadCsv reads a CsvFile.
+// ReadCsv reads a CsvFile. ReadCsv holds a shared lock on the file while
+// reading.
 func ReadCsv(csvPath string) (*CsvFile, error) {
 	f, err := os.Open(csvPath)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
+	if err := filelock.RLock(f); err != nil {
+		return nil, err
+	}
 	return readCsv(f)
 }
 
 func readCsv(r io.Reader) (*CsvFile, error) {
-	csvReader := csv.NewReader(r)
-	headers, err := csvReader.Read()
+	csvReader, err := NewCsvReader(r)
 	if err != nil {
 		return nil, err
 	}
 	var result []CsvRow
+	var lines []int
 	row, err := csvReader.Read()
 	for err != io.EOF {
 		if err != nil {
 			return nil, err
 		}
-		lineNo, _ := csvReader.FieldPos(0)
-		crow := createCsvRow(headers, row)
-		if crow.Name() == "" || crow.Email() == "" {
-			err = fmt.Errorf(
-				"Line %d: name and email columns must be present", lineNo)
-			return nil, err
-		}
-		result = append(result, crow)
+		result = append(result, row)
+		lines = append(lines, csvReader.Line())
 		row, err = csvReader.Read()
 	}
-	return &CsvFile{Headers: headers, Rows: result}, nil
+	return &CsvFile{
+		Headers: csvReader.Headers(), Rows: result, Lines: lines}, nil
+}
+
+// skipBOM returns r without the UTF-8 byte order mark that Excel puts
+// at the start of files.
+func skipBOM(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	if ch, _, err := br.ReadRune(); err == nil && ch != '\ufeff' {
+		br.UnreadRune()
+	}
+	return br
 }
 
 func createCsvRow(headers, row []string) CsvRow {
@@ -99,6 +99,22 @@ charlie@gmail.com,charlie,yes
 	assert.Equal(t, csvStr, builder.String())
 }
 
+func TestSelect(t *testing.T) {
+	r := strings.NewReader(csvStr)
+	csv, err := readCsv(r)
+	assert.NoError(t, err)
+	var builder strings.Builder
+	selected := csv.Select(func(row CsvRow) bool {
+		return strings.HasPrefix(row.Name(), "b")
+	})
+	assert.NoError(t, selected.write(&builder))
+	expected := `email,name,going
+bob@gmail.com,bob,yes
+`
+	assert.Equal(t, expected, builder.String())
+	assert.Len(t, csv.Rows, 3)
+}
+
 func TestSelectGoingNoGoingColumn(t *testing.T) {
 	r := strings.NewReader(csvStrNoGoingColumn)
 	csv, err := readCsv(r)
@@ -150,3 +166,113 @@ func TestDifference(t *testing.T) {
 	assert.Equal(
 		t, "alice@gmail.com, bob@gmail.com, echo@gmail.com", rhs.String())
 }
+
+func TestAttachments(t *testing.T) {
+	row := CsvRow{"attachments": " tickets/alice.pdf;; map.pdf ;"}
+	assert.Equal(
+		t, []string{"tickets/alice.pdf", "map.pdf"}, row.Attachments())
+	assert.Nil(t, CsvRow{"attachments": " "}.Attachments())
+	assert.Nil(t, CsvRow{}.Attachments())
+}
+
+func TestTagSet(t *testing.T) {
+	tags := NewTagSet(" VIP, speaker,,vip ")
+	assert.Equal(t, "speaker, vip", tags.String())
+	assert.True(t, tags.Contains("Vip"))
+	assert.False(t, tags.Contains("declined"))
+	assert.True(t, tags.Intersects(NewTagSet("declined,speaker")))
+	assert.False(t, tags.Intersects(NewTagSet("declined")))
+	assert.False(t, tags.Intersects(NewTagSet("")))
+	assert.Empty(t, CsvRow{"email": "alice@gmail.com"}.Tags())
+	assert.Equal(
+		t, "board, vip", CsvRow{"tags": "vip,Board"}.Tags().String())
+}
+
+func TestWithEmail(t *testing.T) {
+	row := CsvRow{"name": "alice", "email": "alice@gmail.com"}
+	seed := row.WithEmail("seed@outlook.com")
+	assert.Equal(t, "seed@outlook.com", seed.Email())
+	assert.Equal(t, "alice", seed.Name())
+	assert.Equal(t, "alice@gmail.com", row.Email())
+}
+
+func TestCloneAndWith(t *testing.T) {
+	row := CsvRow{"name": "alice", "email": "alice@gmail.com"}
+	pet := row.With("pet", "Rufus")
+	assert.Equal(t, "Rufus", pet["pet"])
+	assert.NotContains(t, row, "pet")
+	clone := row.Clone()
+	clone["name"] = "bob"
+	assert.Equal(t, "alice", row.Name())
+}
+
+func TestCustomHeaders(t *testing.T) {
+	row := CsvRow{
+		"name":               "alice",
+		"email":              "alice@gmail.com",
+		"header:X-Ticket-Id": "1234",
+		"header: X-Campaign": "spring",
+		"header:X-Empty":     "",
+	}
+	assert.Equal(
+		t,
+		map[string]string{"X-Ticket-Id": "1234", "X-Campaign": "spring"},
+		row.CustomHeaders())
+	assert.Nil(t, CsvRow{"name": "bob"}.CustomHeaders())
+}
+
+func TestWriteOptions(t *testing.T) {
+	csv, err := readCsv(strings.NewReader(`email,name
+bob@gmail.com,"Bob ""Bobby"" Smith"
+`))
+	assert.NoError(t, err)
+	var builder strings.Builder
+	assert.NoError(t, csv.write(&builder, CRLF()))
+	assert.Equal(
+		t,
+		"email,name\r\nbob@gmail.com,\"Bob \"\"Bobby\"\" Smith\"\r\n",
+		builder.String())
+	builder.Reset()
+	assert.NoError(t, csv.write(&builder, AlwaysQuote(), BOM()))
+	assert.Equal(
+		t,
+		"\ufeff\"email\",\"name\"\n"+
+			"\"bob@gmail.com\",\"Bob \"\"Bobby\"\" Smith\"\n",
+		builder.String())
+	roundTrip, err := readCsv(strings.NewReader(builder.String()))
+	assert.NoError(t, err)
+	assert.Equal(t, csv, roundTrip)
+}
+
+func TestLines(t *testing.T) {
+	r := strings.NewReader(`email,name,going
+alice@gmail.com,alice,no
+bob@gmail.com,"bob
+smith",yes
+charlie@gmail.com,charlie,yes
+`)
+	csv, err := readCsv(r)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 3, 5}, csv.Lines)
+	going := csv.SelectGoing()
+	assert.Equal(t, 3, going.Line(0))
+	assert.Equal(t, 5, going.Line(1))
+	assert.Equal(t, 0, going.Line(2))
+	assert.Equal(t, 3, going.WithNameParts(ParseName).Line(0))
+	limited, err := LimitFilter(1).Select(going)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3}, limited.Lines)
+}
+
+func TestLookupAndColumns(t *testing.T) {
+	row := CsvRow{"name": "alice", "email": "alice@gmail.com", "pet": ""}
+	value, ok := row.Lookup("pet")
+	assert.True(t, ok)
+	assert.Equal(t, "", value)
+	_, ok = row.Lookup("city")
+	assert.False(t, ok)
+	value, ok = row.Lookup("name")
+	assert.True(t, ok)
+	assert.Equal(t, "alice", value)
+	assert.Equal(t, []string{"email", "name", "pet"}, row.Columns())
+}
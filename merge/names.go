@@ -0,0 +1,127 @@
+package merge
+
+import (
+	"slices"
+	"strings"
+)
+
+const (
+
+	// The computed first name column
+	FirstName = "firstName"
+
+	// The computed last name column
+	LastName = "lastName"
+
+	// The computed title column
+	Title = "title"
+)
+
+var (
+	kTitles = toSet(
+		"dame", "dr", "fr", "hon", "miss", "mr", "mrs", "ms", "mx", "prof",
+		"rev", "sir")
+	kSuffixes = toSet(
+		"esq", "ii", "iii", "iv", "jr", "md", "phd", "sr")
+	kLastNameParticles = toSet(
+		"al", "bin", "da", "de", "del", "della", "der", "di", "du", "la",
+		"le", "st", "van", "von")
+)
+
+// PersonName is a person's name split into parts.
+type PersonName struct {
+	Title string
+	First string
+	Last  string
+}
+
+// NameParser splits a full name into parts. NameParser is the hook for
+// naming conventions that ParseName doesn't handle.
+type NameParser func(fullName string) PersonName
+
+// ParseName splits a full name into parts using western conventions.
+// ParseName understands leading titles like "Dr." and "Mrs", trailing
+// suffixes like "Jr." which it drops, "Last, First" order, and last
+// names with particles like "van der Berg". A single word is taken to be
+// the first name.
+func ParseName(fullName string) PersonName {
+	var result PersonName
+	words := strings.Fields(fullName)
+	for len(words) > 0 && isWordIn(words[len(words)-1], kSuffixes) {
+		words = words[:len(words)-1]
+	}
+	if len(words) > 0 && strings.HasSuffix(words[len(words)-1], ",") {
+		words[len(words)-1] = strings.TrimSuffix(words[len(words)-1], ",")
+	}
+	if last, first, ok := strings.Cut(strings.Join(words, " "), ","); ok {
+		words = append(strings.Fields(first), strings.Fields(last)...)
+		if len(words) > 0 && isWordIn(words[0], kTitles) {
+			result.Title = words[0]
+			words = words[1:]
+		}
+		if len(words) > 0 {
+			result.First = words[0]
+			result.Last = strings.Join(strings.Fields(last), " ")
+		}
+		return result
+	}
+	if len(words) > 1 && isWordIn(words[0], kTitles) {
+		result.Title = words[0]
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return result
+	}
+	result.First = words[0]
+	words = words[1:]
+	if len(words) == 0 {
+		return result
+	}
+	lastStart := len(words) - 1
+	for lastStart > 0 && isWordIn(words[lastStart-1], kLastNameParticles) {
+		lastStart--
+	}
+	result.Last = strings.Join(words[lastStart:], " ")
+	return result
+}
+
+// WithNameParts returns a CsvFile like this instance with firstName,
+// lastName, and title columns computed from the name column using parser.
+// WithNameParts leaves alone any of these columns that already exist.
+func (c *CsvFile) WithNameParts(parser NameParser) *CsvFile {
+	var newColumns []string
+	for _, column := range []string{FirstName, LastName, Title} {
+		if !slices.Contains(c.Headers, column) {
+			newColumns = append(newColumns, column)
+		}
+	}
+	if len(newColumns) == 0 {
+		return c
+	}
+	result := *c
+	result.Headers = append(
+		append(make([]string, 0, len(c.Headers)+len(newColumns)),
+			c.Headers...),
+		newColumns...)
+	result.Rows = make([]CsvRow, 0, len(c.Rows))
+	for _, row := range c.Rows {
+		name := parser(row.Name())
+		parts := map[string]string{
+			FirstName: name.First,
+			LastName:  name.Last,
+			Title:     name.Title,
+		}
+		newRow := row.Clone()
+		for _, column := range newColumns {
+			newRow[column] = parts[column]
+		}
+		result.Rows = append(result.Rows, newRow)
+	}
+	return &result
+}
+
+func isWordIn(word string, set map[string]struct{}) bool {
+	word = strings.ToLower(strings.TrimRight(word, ".,"))
+	_, ok := set[word]
+	return ok
+}
@@ -0,0 +1,59 @@
+package merge
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseName(t *testing.T) {
+	cases := map[string]PersonName{
+		"Jane Smith":              {First: "Jane", Last: "Smith"},
+		"  Jane   Q.  Smith ":     {First: "Jane", Last: "Smith"},
+		"Dr. Jane Smith":          {Title: "Dr.", First: "Jane", Last: "Smith"},
+		"Mrs Jane Smith Jr.":      {Title: "Mrs", First: "Jane", Last: "Smith"},
+		"Smith, Jane":             {First: "Jane", Last: "Smith"},
+		"Smith, Dr. Jane":         {Title: "Dr.", First: "Jane", Last: "Smith"},
+		"Jane Smith, PhD":         {First: "Jane", Last: "Smith"},
+		"Ludwig van Beethoven":    {First: "Ludwig", Last: "van Beethoven"},
+		"Anna van der Berg":       {First: "Anna", Last: "van der Berg"},
+		"Cher":                    {First: "Cher"},
+		"Dr":                      {First: "Dr"},
+		"":                        {},
+		"María José García Núñez": {First: "María", Last: "Núñez"},
+	}
+	for fullName, expected := range cases {
+		assert.Equal(t, expected, ParseName(fullName), fullName)
+	}
+}
+
+func TestWithNameParts(t *testing.T) {
+	r := strings.NewReader(`email,name
+alice@gmail.com,Dr. Alice Jones
+bob@gmail.com,"Smith, Bob"
+`)
+	csv, err := readCsv(r)
+	assert.NoError(t, err)
+	var builder strings.Builder
+	assert.NoError(t, csv.WithNameParts(ParseName).write(&builder))
+	expected := `email,name,firstName,lastName,title
+alice@gmail.com,Dr. Alice Jones,Alice,Jones,Dr.
+bob@gmail.com,"Smith, Bob",Bob,Smith,
+`
+	assert.Equal(t, expected, builder.String())
+}
+
+func TestWithNamePartsKeepsExistingColumns(t *testing.T) {
+	r := strings.NewReader(`email,name,firstName
+alice@gmail.com,Dr. Alice Jones,Ally
+`)
+	csv, err := readCsv(r)
+	assert.NoError(t, err)
+	var builder strings.Builder
+	assert.NoError(t, csv.WithNameParts(ParseName).write(&builder))
+	expected := `email,name,firstName,lastName,title
+alice@gmail.com,Dr. Alice Jones,Ally,Jones,Dr.
+`
+	assert.Equal(t, expected, builder.String())
+}
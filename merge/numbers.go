@@ -0,0 +1,157 @@
+package merge
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// numberFormat is how a language writes numbers.
+type numberFormat struct {
+	Decimal string
+	Group   string
+
+	// Numbers with fewer integer digits than this aren't grouped.
+	MinGroupDigits int
+
+	// True if the currency symbol goes after the amount separated by a
+	// no-break space.
+	SymbolAfter bool
+}
+
+// kNumberFormats are the languages Language accepts.
+var kNumberFormats = map[string]*numberFormat{
+	"en": {Decimal: ".", Group: ",", MinGroupDigits: 4},
+	"es": {Decimal: ",", Group: ".", MinGroupDigits: 5, SymbolAfter: true},
+	"fr": {
+		Decimal:        ",",
+		Group:          "\u202f",
+		MinGroupDigits: 4,
+		SymbolAfter:    true,
+	},
+	"de": {Decimal: ",", Group: ".", MinGroupDigits: 4, SymbolAfter: true},
+}
+
+// currency is how to write amounts of a currency.
+type currency struct {
+	Symbol string
+	Digits int
+}
+
+// kCurrencies are the ISO 4217 codes money accepts.
+var kCurrencies = map[string]currency{
+	"AUD": {"A$", 2},
+	"BRL": {"R$", 2},
+	"CAD": {"CA$", 2},
+	"CHF": {"CHF", 2},
+	"CNY": {"CN¥", 2},
+	"EUR": {"€", 2},
+	"GBP": {"£", 2},
+	"INR": {"₹", 2},
+	"JPY": {"¥", 0},
+	"MXN": {"MX$", 2},
+	"SEK": {"kr", 2},
+	"USD": {"$", 2},
+}
+
+func lookupNumberFormat(lang string) (*numberFormat, error) {
+	if lang == "" {
+		lang = "en"
+	}
+	result, ok := kNumberFormats[lang]
+	if !ok {
+		return nil, fmt.Errorf("unknown language %s", lang)
+	}
+	return result, nil
+}
+
+// parseAmount parses a cell like 1234.5 or 1,234.50. ok is false if
+// value is empty.
+func parseAmount(value string) (amount float64, ok bool, err error) {
+	value = strings.ReplaceAll(strings.TrimSpace(value), ",", "")
+	if value == "" {
+		return 0, false, nil
+	}
+	amount, err = strconv.ParseFloat(value, 64)
+	if err != nil || math.IsInf(amount, 0) || math.IsNaN(amount) {
+		return 0, false, fmt.Errorf("%q is not a number", value)
+	}
+	return amount, true, nil
+}
+
+// formatNumber formats the number in value with decimals digits after
+// the decimal point the way lang writes numbers. An empty value formats
+// as the empty string.
+func formatNumber(value string, decimals int, lang string) (string, error) {
+	format, err := lookupNumberFormat(lang)
+	if err != nil {
+		return "", fmt.Errorf("number: %v", err)
+	}
+	amount, ok, err := parseAmount(value)
+	if err != nil {
+		return "", fmt.Errorf("number: %v", err)
+	}
+	if !ok {
+		return "", nil
+	}
+	if decimals < 0 {
+		return "", fmt.Errorf("number: decimals must not be negative")
+	}
+	return format.Format(amount, decimals), nil
+}
+
+// formatMoney formats the amount in value in currency, an ISO 4217 code
+// like EUR, the way lang writes amounts of money. An empty value formats
+// as the empty string.
+func formatMoney(value, currencyCode, lang string) (string, error) {
+	format, err := lookupNumberFormat(lang)
+	if err != nil {
+		return "", fmt.Errorf("money: %v", err)
+	}
+	cur, ok := kCurrencies[strings.ToUpper(currencyCode)]
+	if !ok {
+		return "", fmt.Errorf("money: unknown currency %s", currencyCode)
+	}
+	amount, ok, err := parseAmount(value)
+	if err != nil {
+		return "", fmt.Errorf("money: %v", err)
+	}
+	if !ok {
+		return "", nil
+	}
+	number := format.Format(amount, cur.Digits)
+	sign := ""
+	if rest, ok := strings.CutPrefix(number, "-"); ok {
+		sign, number = "-", rest
+	}
+	if format.SymbolAfter {
+		return sign + number + "\u00a0" + cur.Symbol, nil
+	}
+	return sign + cur.Symbol + number, nil
+}
+
+// Format formats amount with decimals digits after the decimal point.
+func (f *numberFormat) Format(amount float64, decimals int) string {
+	digits := strconv.FormatFloat(math.Abs(amount), 'f', decimals, 64)
+	whole, fraction, _ := strings.Cut(digits, ".")
+	var builder strings.Builder
+	if amount < 0 && strings.Trim(digits, "0.") != "" {
+		builder.WriteByte('-')
+	}
+	if len(whole) >= f.MinGroupDigits {
+		for i, digit := range whole {
+			if i > 0 && (len(whole)-i)%3 == 0 {
+				builder.WriteString(f.Group)
+			}
+			builder.WriteRune(digit)
+		}
+	} else {
+		builder.WriteString(whole)
+	}
+	if fraction != "" {
+		builder.WriteString(f.Decimal)
+		builder.WriteString(fraction)
+	}
+	return builder.String()
+}
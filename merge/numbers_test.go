@@ -0,0 +1,49 @@
+package merge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMoney(t *testing.T) {
+	tmpl, err := ParseTemplate("money", `{{money .due .currency .lang}}`)
+	assert.NoError(t, err)
+	cases := []struct {
+		due, currency, lang, expected string
+	}{
+		{"1234.5", "EUR", "de", "1.234,50\u00a0€"},
+		{"1234.5", "EUR", "fr", "1\u202f234,50\u00a0€"},
+		{"1234.5", "EUR", "es", "1234,50\u00a0€"},
+		{"12345.5", "EUR", "es", "12.345,50\u00a0€"},
+		{"1,234,567.891", "USD", "en", "$1,234,567.89"},
+		{"-20", "gbp", "en", "-£20.00"},
+		{"1500.4", "JPY", "en", "¥1,500"},
+		{"-0.001", "USD", "en", "$0.00"},
+		{"", "USD", "en", ""},
+	}
+	for _, c := range cases {
+		body, err := tmpl.Execute(
+			CsvRow{"due": c.due, "currency": c.currency, "lang": c.lang})
+		assert.NoError(t, err)
+		assert.Equal(t, c.expected, body)
+	}
+	_, err = tmpl.Execute(CsvRow{"due": "ten", "currency": "USD", "lang": "en"})
+	assert.Error(t, err)
+	_, err = tmpl.Execute(CsvRow{"due": "10", "currency": "XYZ", "lang": "en"})
+	assert.Error(t, err)
+	_, err = tmpl.Execute(CsvRow{"due": "10", "currency": "USD", "lang": "xx"})
+	assert.Error(t, err)
+}
+
+func TestNumber(t *testing.T) {
+	tmpl, err := ParseTemplate(
+		"number", `{{number .n 1}} {{number .n 0 "de"}}`, Language("fr"))
+	assert.NoError(t, err)
+	body, err := tmpl.Execute(CsvRow{"n": "-98765.43"})
+	assert.NoError(t, err)
+	assert.Equal(t, "-98\u202f765,4 -98.765", body)
+	body, err = tmpl.Execute(CsvRow{"n": "-0.01"})
+	assert.NoError(t, err)
+	assert.Equal(t, "0,0 0", body)
+}
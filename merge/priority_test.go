@@ -0,0 +1,59 @@
+package merge
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestByPriority(t *testing.T) {
+	csv, err := readCsv(strings.NewReader(`email,name,priority
+alice@gmail.com,alice,
+bob@gmail.com,bob,2
+carl@gmail.com,carl,-1
+dana@gmail.com,dana, 2
+emma@gmail.com,emma,0
+`))
+	assert.NoError(t, err)
+	sorted, err := csv.ByPriority()
+	assert.NoError(t, err)
+	var emails []string
+	for _, row := range sorted.Rows {
+		emails = append(emails, row.Email())
+	}
+	assert.Equal(
+		t,
+		[]string{
+			"bob@gmail.com",
+			"dana@gmail.com",
+			"alice@gmail.com",
+			"emma@gmail.com",
+			"carl@gmail.com",
+		},
+		emails)
+	assert.Equal(t, []int{3, 5, 2, 6, 4}, sorted.Lines)
+	assert.Equal(t, "alice@gmail.com", csv.Rows[0].Email())
+}
+
+func TestByPriorityNoColumn(t *testing.T) {
+	csv, err := readCsv(strings.NewReader(csvStr))
+	assert.NoError(t, err)
+	sorted, err := csv.ByPriority()
+	assert.NoError(t, err)
+	assert.Same(t, csv, sorted)
+}
+
+func TestByPriorityBadValue(t *testing.T) {
+	csv, err := readCsv(strings.NewReader(`email,name,priority
+bob@gmail.com,bob,1
+alice@gmail.com,alice,high
+`))
+	assert.NoError(t, err)
+	_, err = csv.ByPriority()
+	assert.EqualError(t, err, `Line 3: priority must be a whole number: "high"`)
+	csv.Lines = nil
+	_, err = csv.ByPriority()
+	assert.EqualError(
+		t, err, `alice@gmail.com: priority must be a whole number: "high"`)
+}
@@ -0,0 +1,170 @@
+package merge
+
+import (
+	"math"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ColumnType is the kind of values a column holds.
+type ColumnType string
+
+const (
+	// EmptyColumn is for columns with no values.
+	EmptyColumn ColumnType = "empty"
+
+	// IntegerColumn is for whole numbers like 42.
+	IntegerColumn ColumnType = "integer"
+
+	// NumberColumn is for numbers like 3.5.
+	NumberColumn ColumnType = "number"
+
+	// BooleanColumn is for values like yes, no, true, or false.
+	BooleanColumn ColumnType = "boolean"
+
+	// DateColumn is for dates like 2024-05-16 or 5/16/2024.
+	DateColumn ColumnType = "date"
+
+	// EmailColumn is for email addresses.
+	EmailColumn ColumnType = "email"
+
+	// TextColumn is for everything else.
+	TextColumn ColumnType = "text"
+)
+
+var (
+	kDateLayouts = []string{
+		"2006-01-02",
+		"1/2/2006",
+		"1/2/06",
+		"Jan 2, 2006",
+		"January 2, 2006",
+		"2 Jan 2006",
+		"2 January 2006",
+		"Monday, January 2, 2006",
+	}
+	kBooleans = toSet("y", "n", "yes", "no", "true", "false")
+
+	// Narrowest types first
+	kColumnTypes = []struct {
+		Type    ColumnType
+		Matches func(string) bool
+	}{
+		{IntegerColumn, isInteger},
+		{NumberColumn, isNumber},
+		{BooleanColumn, isBoolean},
+		{DateColumn, isDate},
+		{EmailColumn, isEmail},
+	}
+)
+
+// ColumnSchema describes one column of a CsvFile.
+type ColumnSchema struct {
+
+	// The column name
+	Name string
+
+	// The narrowest type that fits every non-empty value
+	Type ColumnType
+
+	// The number of rows where this column is empty
+	Empty int
+
+	// The fraction of rows where this column is empty
+	EmptyRate float64
+
+	// The number of distinct non-empty values
+	Distinct int
+}
+
+// Schema describes the columns of a CsvFile.
+type Schema struct {
+
+	// The number of rows
+	Rows int
+
+	// The columns in header order
+	Columns []ColumnSchema
+}
+
+// InferSchema guesses the type of each column in csvFile and counts its
+// empty and distinct values. Values that are only whitespace count as
+// empty. InferSchema helps spot a column of dates stored as text, for
+// instance, before a template relies on it.
+func InferSchema(csvFile *CsvFile) *Schema {
+	result := &Schema{Rows: len(csvFile.Rows)}
+	for _, header := range csvFile.Headers {
+		result.Columns = append(
+			result.Columns, inferColumn(header, csvFile.Rows))
+	}
+	return result
+}
+
+func inferColumn(name string, rows []CsvRow) ColumnSchema {
+	result := ColumnSchema{Name: name}
+	distinct := make(map[string]struct{})
+	candidates := make([]bool, len(kColumnTypes))
+	for i := range candidates {
+		candidates[i] = true
+	}
+	for _, row := range rows {
+		value := strings.TrimSpace(row[name])
+		if value == "" {
+			result.Empty++
+			continue
+		}
+		distinct[value] = struct{}{}
+		for i, columnType := range kColumnTypes {
+			if candidates[i] && !columnType.Matches(value) {
+				candidates[i] = false
+			}
+		}
+	}
+	result.Distinct = len(distinct)
+	if len(rows) > 0 {
+		result.EmptyRate = float64(result.Empty) / float64(len(rows))
+	}
+	if result.Distinct == 0 {
+		result.Type = EmptyColumn
+		return result
+	}
+	result.Type = TextColumn
+	for i, columnType := range kColumnTypes {
+		if candidates[i] {
+			result.Type = columnType.Type
+			break
+		}
+	}
+	return result
+}
+
+func isInteger(s string) bool {
+	_, err := strconv.ParseInt(s, 10, 64)
+	return err == nil
+}
+
+func isNumber(s string) bool {
+	number, err := strconv.ParseFloat(s, 64)
+	return err == nil && !math.IsInf(number, 0) && !math.IsNaN(number)
+}
+
+func isBoolean(s string) bool {
+	_, ok := kBooleans[strings.ToLower(s)]
+	return ok
+}
+
+func isDate(s string) bool {
+	for _, layout := range kDateLayouts {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
@@ -0,0 +1,55 @@
+package merge
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInferSchema(t *testing.T) {
+	csv, err := readCsv(strings.NewReader(`name,email,guests,dues,going,date,when,notes
+Alice,alice@gmail.com,2,10.50,yes,2024-05-16,5/16/2024,
+Bob,bob@gmail.com,,20,n,5/16/2024,May 16,
+Carl,carl@gmail.com,1,7,Y,"May 16, 2024",5/16/2024, 
+Dana,dana@gmail.com,1,1e3,,2024-05-17,soon,
+`))
+	assert.NoError(t, err)
+	schema := InferSchema(csv)
+	assert.Equal(t, 4, schema.Rows)
+	types := make(map[string]ColumnType)
+	for _, column := range schema.Columns {
+		types[column.Name] = column.Type
+	}
+	assert.Equal(
+		t,
+		map[string]ColumnType{
+			"name":   TextColumn,
+			"email":  EmailColumn,
+			"guests": IntegerColumn,
+			"dues":   NumberColumn,
+			"going":  BooleanColumn,
+			"date":   DateColumn,
+			"when":   TextColumn,
+			"notes":  EmptyColumn,
+		},
+		types)
+	guests := schema.Columns[2]
+	assert.Equal(t, "guests", guests.Name)
+	assert.Equal(t, 1, guests.Empty)
+	assert.Equal(t, 0.25, guests.EmptyRate)
+	assert.Equal(t, 2, guests.Distinct)
+	assert.Equal(t, 4, schema.Columns[7].Empty)
+}
+
+func TestInferSchemaNotNumbers(t *testing.T) {
+	csv, err := readCsv(strings.NewReader(`name,email,score,ratio
+Alice,alice@gmail.com,NaN,1.5
+Bob,bob@gmail.com,2,Inf
+Carl,carl@gmail.com,3,-infinity
+`))
+	assert.NoError(t, err)
+	schema := InferSchema(csv)
+	assert.Equal(t, TextColumn, schema.Columns[2].Type)
+	assert.Equal(t, TextColumn, schema.Columns[3].Type)
+}
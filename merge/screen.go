@@ -0,0 +1,88 @@
+package merge
+
+import (
+	"strings"
+)
+
+const (
+
+	// RoleAccount is the reason for emails like info@ or admin@ that
+	// belong to a role rather than a person.
+	RoleAccount = "role account"
+
+	// DisposableDomain is the reason for emails at throwaway email
+	// services.
+	DisposableDomain = "disposable domain"
+)
+
+var (
+	kRoleAccounts = toSet(
+		"abuse",
+		"admin",
+		"administrator",
+		"billing",
+		"contact",
+		"enquiries",
+		"help",
+		"hello",
+		"hostmaster",
+		"hr",
+		"info",
+		"inquiries",
+		"jobs",
+		"mail",
+		"marketing",
+		"no-reply",
+		"noreply",
+		"office",
+		"postmaster",
+		"root",
+		"sales",
+		"security",
+		"support",
+		"team",
+		"webmaster",
+	)
+	kDisposableDomains = toSet(
+		"10minutemail.com",
+		"dispostable.com",
+		"emailondeck.com",
+		"fakeinbox.com",
+		"getnada.com",
+		"guerrillamail.com",
+		"mailinator.com",
+		"maildrop.cc",
+		"mintemail.com",
+		"sharklasers.com",
+		"temp-mail.org",
+		"tempmail.com",
+		"throwawaymail.com",
+		"trashmail.com",
+		"yopmail.com",
+	)
+)
+
+// ScreenEmail returns RoleAccount or DisposableDomain if email is likely
+// to hurt engagement or bounce. Otherwise ScreenEmail returns the empty
+// string.
+func ScreenEmail(email string) string {
+	local, domain, ok := strings.Cut(strings.ToLower(email), "@")
+	if !ok {
+		return ""
+	}
+	if _, ok := kRoleAccounts[local]; ok {
+		return RoleAccount
+	}
+	if _, ok := kDisposableDomains[domain]; ok {
+		return DisposableDomain
+	}
+	return ""
+}
+
+func toSet(items ...string) map[string]struct{} {
+	result := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		result[item] = struct{}{}
+	}
+	return result
+}
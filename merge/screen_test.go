@@ -0,0 +1,35 @@
+package merge
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScreenEmail(t *testing.T) {
+	assert.Equal(t, RoleAccount, ScreenEmail("info@club.org"))
+	assert.Equal(t, RoleAccount, ScreenEmail("Admin@Club.org"))
+	assert.Equal(t, DisposableDomain, ScreenEmail("bob@mailinator.com"))
+	assert.Equal(t, "", ScreenEmail("alice@gmail.com"))
+	assert.Equal(t, "", ScreenEmail("information@club.org"))
+	assert.Equal(t, "", ScreenEmail("bogus"))
+}
+
+func TestScreenFilter(t *testing.T) {
+	r := strings.NewReader(`email,name
+info@club.org,club
+alice@gmail.com,alice
+bob@yopmail.com,bob
+`)
+	csv, err := readCsv(r)
+	assert.NoError(t, err)
+	csv, err = ScreenFilter().Select(csv)
+	assert.NoError(t, err)
+	var builder strings.Builder
+	assert.NoError(t, csv.write(&builder))
+	expected := `email,name
+alice@gmail.com,alice
+`
+	assert.Equal(t, expected, builder.String())
+}
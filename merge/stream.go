@@ -0,0 +1,143 @@
+package merge
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CsvReader reads a mail merge CSV file one row at a time so that files
+// too big to fit in memory can be processed.
+type CsvReader struct {
+	reader  *csv.Reader
+	headers []string
+	line    int
+}
+
+// NewCsvReader returns a CsvReader that reads from r. NewCsvReader reads
+// the headers right away skipping any byte order mark.
+func NewCsvReader(r io.Reader) (*CsvReader, error) {
+	reader := csv.NewReader(skipBOM(r))
+	headers, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	reader.ReuseRecord = true
+	return &CsvReader{reader: reader, headers: headers}, nil
+}
+
+// Headers returns the headers. Callers must not change the returned
+// slice.
+func (c *CsvReader) Headers() []string {
+	return c.headers
+}
+
+// Read returns the next row or io.EOF if there are no more rows.
+func (c *CsvReader) Read() (CsvRow, error) {
+	record, err := c.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	c.line, _ = c.reader.FieldPos(0)
+	row := createCsvRow(c.headers, record)
+	if row.Name() == "" || row.Email() == "" {
+		return nil, fmt.Errorf(
+			"Line %d: name and email columns must be present", c.line)
+	}
+	return row, nil
+}
+
+// Line returns the line where the row that Read last returned starts.
+func (c *CsvReader) Line() int {
+	return c.line
+}
+
+// CsvWriter writes a mail merge CSV file one row at a time.
+type CsvWriter struct {
+	writer  recordWriter
+	headers []string
+	record  []string
+	rows    int
+}
+
+// NewCsvWriter returns a CsvWriter that writes to w. NewCsvWriter writes
+// headers right away. NewCsvWriter returns an error if a header appears
+// twice since a CsvRow can't hold both values.
+func NewCsvWriter(w io.Writer, headers []string, options ...WriteOption) (
+	*CsvWriter, error) {
+	if err := checkHeaders(headers); err != nil {
+		return nil, err
+	}
+	settings := newWriteSettings(options)
+	if len(headers) > 0 && strings.HasPrefix(headers[0], "\ufeff") {
+		// Readers strip one byte order mark from the start of the file
+		// so write one more to keep the one in the first header.
+		settings.BOM = true
+	}
+	result, err := newCsvWriter(w, headers, settings)
+	if err != nil {
+		return nil, err
+	}
+	if err := result.writer.Write(headers); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// newCsvWriter returns a CsvWriter that writes to w without writing
+// headers first.
+func newCsvWriter(w io.Writer, headers []string, settings writeSettings) (
+	*CsvWriter, error) {
+	writer, err := newRecordWriter(w, settings)
+	if err != nil {
+		return nil, err
+	}
+	return &CsvWriter{
+		writer:  writer,
+		headers: headers,
+		record:  make([]string, len(headers)),
+	}, nil
+}
+
+// Write writes row. Write writes only the columns in the headers. Write
+// returns an error if row has no name or email since CsvReader wouldn't
+// read it back.
+func (c *CsvWriter) Write(row CsvRow) error {
+	c.rows++
+	if err := checkRow(c.rows, row); err != nil {
+		return err
+	}
+	for i, header := range c.headers {
+		c.record[i] = row[header]
+	}
+	return c.writer.Write(c.record)
+}
+
+// Flush writes any buffered rows.
+func (c *CsvWriter) Flush() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// checkHeaders returns an error if a header appears more than once.
+func checkHeaders(headers []string) error {
+	seen := make(map[string]bool, len(headers))
+	for _, header := range headers {
+		if seen[header] {
+			return fmt.Errorf("duplicate header: %s", header)
+		}
+		seen[header] = true
+	}
+	return nil
+}
+
+// checkRow returns an error if row, the nth row written, has no name or
+// email.
+func checkRow(n int, row CsvRow) error {
+	if row.Name() == "" || row.Email() == "" {
+		return fmt.Errorf(
+			"Row %d: name and email columns must be present", n)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package merge
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCsvReaderAndWriter(t *testing.T) {
+	reader, err := NewCsvReader(strings.NewReader(csvStr))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"email", "name", "going"}, reader.Headers())
+	var builder strings.Builder
+	writer, err := NewCsvWriter(&builder, reader.Headers())
+	assert.NoError(t, err)
+	var lines []int
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		lines = append(lines, reader.Line())
+		if row.Going() {
+			assert.NoError(t, writer.Write(row.WithNotGoing()))
+		}
+	}
+	assert.NoError(t, writer.Flush())
+	assert.Equal(t, []int{2, 3, 4}, lines)
+	expected := `email,name,going
+bob@gmail.com,bob,n
+charlie@gmail.com,charlie,n
+`
+	assert.Equal(t, expected, builder.String())
+}
+
+func TestCsvReaderMissingEmail(t *testing.T) {
+	reader, err := NewCsvReader(strings.NewReader("name,email\nalice,\n"))
+	assert.NoError(t, err)
+	_, err = reader.Read()
+	assert.Error(t, err)
+}
+
+// bigCsv returns a CSV file with rows rows.
+func bigCsv(rows int) []byte {
+	var buffer bytes.Buffer
+	writer, _ := NewCsvWriter(
+		&buffer, []string{Name, Email, Going, "city", "note"})
+	for i := 0; i < rows; i++ {
+		writer.Write(CsvRow{
+			Name:   fmt.Sprintf("Person %d", i),
+			Email:  fmt.Sprintf("person%d@example.com", i),
+			Going:  []string{"y", "n"}[i%2],
+			"city": "Springfield",
+			"note": "Likes long walks, \"quiet\" evenings",
+		})
+	}
+	writer.Flush()
+	return buffer.Bytes()
+}
+
+func BenchmarkReadCsv(b *testing.B) {
+	data := bigCsv(100000)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := readCsv(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCsvReader(b *testing.B) {
+	data := bigCsv(100000)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		reader, err := NewCsvReader(bytes.NewReader(data))
+		if err != nil {
+			b.Fatal(err)
+		}
+		for {
+			_, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkWrite(b *testing.B) {
+	csvFile, err := readCsv(bytes.NewReader(bigCsv(100000)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := csvFile.write(io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSelectGoing(b *testing.B) {
+	csvFile, err := readCsv(bytes.NewReader(bigCsv(100000)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		csvFile.SelectGoing().WithNotGoing()
+	}
+}
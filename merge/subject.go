@@ -0,0 +1,41 @@
+package merge
+
+import (
+	"errors"
+	"strings"
+)
+
+// Subject is a compiled subject line e.g
+// "Reminder for {{.Name}} about the picnic".
+type Subject struct {
+	tmpl *Template
+}
+
+// ParseSubject compiles text as a subject line. ParseSubject accepts the
+// same functions as ParseTemplateFile. Errors call the subject line
+// -subject after the flag that sets it.
+func ParseSubject(text string, options ...TemplateOption) (*Subject, error) {
+	tmpl, err := ParseTemplate("-subject", text, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &Subject{tmpl: tmpl}, nil
+}
+
+// Execute renders this subject line against row without leading or
+// trailing whitespace. Execute returns an error if the result is empty
+// or spans more than one line since either makes a broken email.
+func (s *Subject) Execute(row CsvRow) (string, error) {
+	result, err := s.tmpl.Execute(row)
+	if err != nil {
+		return "", err
+	}
+	result = strings.TrimSpace(result)
+	if result == "" {
+		return "", errors.New("-subject: subject is empty")
+	}
+	if strings.ContainsAny(result, "\r\n") {
+		return "", errors.New("-subject: subject has a line break")
+	}
+	return result, nil
+}
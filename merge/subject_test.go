@@ -0,0 +1,59 @@
+package merge
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubject(t *testing.T) {
+	subject, err := ParseSubject(
+		"Reminder for {{.Name}} about the {{.event}} ")
+	assert.NoError(t, err)
+	result, err := subject.Execute(
+		CsvRow{"name": "Alice", "event": "picnic"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Reminder for Alice about the picnic", result)
+}
+
+func TestSubjectStatic(t *testing.T) {
+	subject, err := ParseSubject("You're invited")
+	assert.NoError(t, err)
+	result, err := subject.Execute(CsvRow{"name": "Alice"})
+	assert.NoError(t, err)
+	assert.Equal(t, "You're invited", result)
+}
+
+func TestSubjectParseError(t *testing.T) {
+	_, err := ParseSubject("Hi {{.name}")
+	var templateErr *TemplateError
+	assert.True(t, errors.As(err, &templateErr))
+	assert.Equal(t, "-subject", templateErr.Name)
+	assert.Equal(t, 1, templateErr.Line)
+	assert.Equal(t, "Hi {{.name}", templateErr.Source)
+}
+
+func TestSubjectExecuteError(t *testing.T) {
+	subject, err := ParseSubject("Hi {{index .name 9}}")
+	assert.NoError(t, err)
+	_, err = subject.Execute(CsvRow{"name": "Al"})
+	var templateErr *TemplateError
+	assert.True(t, errors.As(err, &templateErr))
+	assert.Equal(t, "-subject", templateErr.Name)
+	assert.Equal(t, 6, templateErr.Column)
+}
+
+func TestSubjectEmpty(t *testing.T) {
+	subject, err := ParseSubject("{{.nickname}}")
+	assert.NoError(t, err)
+	_, err = subject.Execute(CsvRow{"name": "Al", "nickname": " "})
+	assert.EqualError(t, err, "-subject: subject is empty")
+}
+
+func TestSubjectLineBreak(t *testing.T) {
+	subject, err := ParseSubject("Hi {{.name}}")
+	assert.NoError(t, err)
+	_, err = subject.Execute(CsvRow{"name": "Al\nBcc: x@y.com"})
+	assert.EqualError(t, err, "-subject: subject has a line break")
+}
@@ -0,0 +1,469 @@
+package merge
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// Template is a compiled mail merge template. Templates that do nothing
+// but substitute columns, like "Dear {{.name}}", render without going
+// through text/template at all.
+type Template struct {
+	tmpl    *template.Template
+	exec    executor
+	sources map[string]string
+	fields  []string
+	plan    []step
+	cache   *renderCache
+}
+
+// executor renders a template. Both text/template and html/template
+// templates are executors.
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+// TemplateOption represents an option for ParseTemplateFile.
+type TemplateOption interface {
+	mutate(s *templateSettings)
+}
+
+// WithNameParser sets the NameParser that the firstName, lastName, and
+// title template functions use. The default is ParseName.
+func WithNameParser(parser NameParser) TemplateOption {
+	return templateOptionFunc(func(s *templateSettings) {
+		s.NameParser = parser
+	})
+}
+
+// GenericSalutation sets what the salutation template function returns
+// for rows with no salutation and no first name. The default is
+// "Dear guest".
+func GenericSalutation(salutation string) TemplateOption {
+	return templateOptionFunc(func(s *templateSettings) {
+		s.GenericSalutation = salutation
+	})
+}
+
+// Language sets the language that the formatDate, number, and money
+// template functions write in: en, es, fr, or de. The default is en.
+func Language(lang string) TemplateOption {
+	return templateOptionFunc(func(s *templateSettings) {
+		s.Lang = lang
+	})
+}
+
+// EscapeHTML makes templates escape what each action writes the way
+// html/template does, according to where it lands in the page. A name
+// like "Tom & Jerry" shows up right in an HTML email, and a column
+// holding a javascript: URL can't end up as a working link. Use
+// EscapeHTML for HTML templates. Text that templates write as is, such as
+// markup, is not escaped.
+func EscapeHTML() TemplateOption {
+	return templateOptionFunc(func(s *templateSettings) {
+		s.EscapeHTML = true
+	})
+}
+
+// WithFuncs adds funcs to the functions that templates may use. funcs
+// may replace the built in functions. If one of funcs panics, Execute
+// returns an error wrapping a *PanicError.
+func WithFuncs(funcs template.FuncMap) TemplateOption {
+	return templateOptionFunc(func(s *templateSettings) {
+		for name, f := range funcs {
+			s.Funcs[name] = recoverFunc(f)
+		}
+	})
+}
+
+// PanicError reports a panic while rendering a template.
+type PanicError struct {
+
+	// What was passed to panic
+	Value any
+
+	// The stack trace where the panic happened
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", p.Value)
+}
+
+// recoverFunc returns f wrapped so that a panic in f becomes a panic
+// with a *PanicError holding the stack trace. text/template turns the
+// panic into an error, but without recoverFunc, the stack trace would
+// be lost.
+func recoverFunc(f any) any {
+	fv := reflect.ValueOf(f)
+	if fv.Kind() != reflect.Func {
+		return f
+	}
+	return reflect.MakeFunc(
+		fv.Type(), func(args []reflect.Value) []reflect.Value {
+			defer func() {
+				if r := recover(); r != nil {
+					panic(&PanicError{Value: r, Stack: debug.Stack()})
+				}
+			}()
+			if fv.Type().IsVariadic() {
+				return fv.CallSlice(args)
+			}
+			return fv.Call(args)
+		}).Interface()
+}
+
+// ParseTemplateFile compiles the template in templatePath. Syntax errors
+// come back as a *TemplateError. In addition to the text/template
+// builtins, templates may use these functions:
+//
+//	firstName, lastName, title: The parts of a full name,
+//	e.g {{firstName .name}}
+//	salutation: The salutation column if the row has one, otherwise
+//	"Dear " followed by the first name, otherwise the generic salutation,
+//	e.g {{salutation .}}
+//	formatDate: Formats an ISO 8601 date like 2006-01-02 or
+//	2006-01-02 15:04 with a Go layout, optionally in a time zone,
+//	e.g {{formatDate .eventDate "2 January 2006" "Europe/Paris"}}
+//	number: Formats a number with the given digits after the decimal
+//	point, optionally in a language other than the template's,
+//	e.g {{number .guests 0}}
+//	money: Formats an amount in a currency, optionally in a language
+//	other than the template's, e.g {{money .amountDue "EUR" "de"}}
+//	going: True if the person is going to the event,
+//	e.g {{if going .}}See you there{{end}}
+//	ifCol: True if a column says yes, that is it isn't empty, n, no,
+//	false, or 0, e.g {{if ifCol . "vegetarian"}}We have a veggie meal{{end}}
+//	choose: The text for the value of a column followed by value, text
+//	pairs and an optional default,
+//	e.g {{choose .size "S" "small" "M" "medium" "large"}}
+func ParseTemplateFile(
+	templatePath string, options ...TemplateOption) (*Template, error) {
+	content, err := os.ReadFile(templatePath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTemplate(
+		filepath.Base(templatePath), string(content), options...)
+}
+
+// ParseTemplate compiles text as a template called name. ParseTemplate
+// accepts the same functions as ParseTemplateFile.
+func ParseTemplate(
+	name, text string, options ...TemplateOption) (*Template, error) {
+	settings := newTemplateSettings(options)
+	sources := map[string]string{name: text}
+	tmpl, err := template.New(name).Funcs(settings.funcs()).Parse(text)
+	if err != nil {
+		return nil, locateError(err, sources)
+	}
+	return newTemplate(tmpl, sources, settings)
+}
+
+func newTemplateSettings(options []TemplateOption) *templateSettings {
+	result := &templateSettings{
+		NameParser:        ParseName,
+		GenericSalutation: "Dear guest",
+		Funcs:             make(template.FuncMap),
+	}
+	for _, option := range options {
+		option.mutate(result)
+	}
+	return result
+}
+
+func (s *templateSettings) funcs() template.FuncMap {
+	result := template.FuncMap{
+		"firstName": func(name string) string {
+			return s.NameParser(name).First
+		},
+		"lastName": func(name string) string {
+			return s.NameParser(name).Last
+		},
+		"title": func(name string) string {
+			return s.NameParser(name).Title
+		},
+		"salutation": func(row CsvRow) string {
+			return salutation(row, s)
+		},
+		"formatDate": func(
+			value, layout string, zone ...string) (string, error) {
+			return formatDate(kDateNames[s.Lang], value, layout, zone...)
+		},
+		"going": func(row CsvRow) bool {
+			return row.Going()
+		},
+		"ifCol":  ifCol,
+		"choose": choose,
+		"number": func(
+			value string, decimals int, lang ...string) (string, error) {
+			return formatNumber(value, decimals, s.lang(lang))
+		},
+		"money": func(
+			value, currency string, lang ...string) (string, error) {
+			return formatMoney(value, currency, s.lang(lang))
+		},
+	}
+	for name, f := range s.Funcs {
+		result[name] = f
+	}
+	return result
+}
+
+func salutation(row CsvRow, settings *templateSettings) string {
+	if s := strings.TrimSpace(row[Salutation]); s != "" {
+		return s
+	}
+	first := strings.TrimSpace(row[FirstName])
+	if first == "" {
+		first = settings.NameParser(row.Name()).First
+	}
+	if first != "" {
+		return "Dear " + first
+	}
+	return settings.GenericSalutation
+}
+
+type templateSettings struct {
+	NameParser        NameParser
+	GenericSalutation string
+	Lang              string
+	EscapeHTML        bool
+	Funcs             template.FuncMap
+}
+
+// lang returns the language a template function was given or the
+// template's language if it wasn't given one.
+func (s *templateSettings) lang(given []string) string {
+	if len(given) > 0 {
+		return given[0]
+	}
+	return s.Lang
+}
+
+type templateOptionFunc func(s *templateSettings)
+
+func (o templateOptionFunc) mutate(s *templateSettings) {
+	o(s)
+}
+
+func newTemplate(
+	tmpl *template.Template,
+	sources map[string]string,
+	settings *templateSettings) (*Template, error) {
+	result := &Template{tmpl: tmpl, exec: tmpl, sources: sources}
+	if settings.EscapeHTML {
+		escaped, err := htmlTemplate(tmpl, settings.funcs())
+		if err != nil {
+			return nil, locateError(err, sources)
+		}
+		result.exec = escaped
+	}
+	if tmpl.Tree == nil {
+		return result, nil
+	}
+	var known bool
+	result.fields, known = referencedFields(tmpl.Tree.Root)
+	// The plan writes columns as is, so escaped templates can't use it.
+	if !settings.EscapeHTML {
+		result.plan, _ = compilePlan(tmpl.Tree.Root)
+	}
+	// Functions from WithFuncs might not give the same output for the
+	// same input, e.g a function returning the time, so only templates
+	// without them get a cache.
+	if known && result.plan == nil && len(settings.Funcs) == 0 {
+		result.cache = newRenderCache(kRenderCacheSize)
+	}
+	return result, nil
+}
+
+// Name returns the name of this template. For templates read from a file,
+// the name is the base name of the file.
+func (t *Template) Name() string {
+	return filepath.Base(t.tmpl.Name())
+}
+
+// Fields returns the columns this template references sorted
+// alphabetically. Fields returns nil if this template references the row
+// in a way that could involve any column, e.g {{template "x" .}}.
+func (t *Template) Fields() []string {
+	return t.fields
+}
+
+// Execute renders this template against row. Errors that text/template
+// can place come back as a *TemplateError. Execute returns a
+// *PanicError, possibly wrapped, rather than panicking. Execute
+// remembers what it rendered for recent rows so that rows with the same
+// values in the columns this template references render only once.
+// Templates using functions from WithFuncs are always rendered.
+func (t *Template) Execute(row CsvRow) (result string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = ""
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	var builder strings.Builder
+	if t.plan != nil {
+		for _, s := range t.plan {
+			s.execute(row, &builder)
+		}
+		return builder.String(), nil
+	}
+	var key string
+	if t.cache != nil {
+		key = cacheKey(t.fields, row)
+		if result, ok := t.cache.Get(key); ok {
+			return result, nil
+		}
+	}
+	if err := t.exec.Execute(&builder, row); err != nil {
+		return "", locateError(err, t.sources)
+	}
+	if t.cache != nil {
+		t.cache.Add(key, builder.String())
+	}
+	return builder.String(), nil
+}
+
+// step is one step of a plan for rendering a simple field substitution
+// template.
+type step struct {
+	text  string
+	field string
+}
+
+func (s step) execute(row CsvRow, builder *strings.Builder) {
+	if s.field == "" {
+		builder.WriteString(s.text)
+		return
+	}
+	value, ok := row[s.field]
+	if !ok {
+		// Match what text/template prints for a missing map key.
+		value = "<no value>"
+	}
+	builder.WriteString(value)
+}
+
+// compilePlan returns the plan for rendering root or false if root does
+// more than substitute columns.
+func compilePlan(root *parse.ListNode) ([]step, bool) {
+	result := make([]step, 0, len(root.Nodes))
+	for _, node := range root.Nodes {
+		switch n := node.(type) {
+		case *parse.TextNode:
+			result = append(result, step{text: string(n.Text)})
+		case *parse.ActionNode:
+			field, ok := simpleField(n.Pipe)
+			if !ok {
+				return nil, false
+			}
+			result = append(result, step{field: field})
+		default:
+			return nil, false
+		}
+	}
+	return result, true
+}
+
+// simpleField returns the column name if pipe is just {{.column}}.
+func simpleField(pipe *parse.PipeNode) (string, bool) {
+	if len(pipe.Decl) != 0 || len(pipe.Cmds) != 1 {
+		return "", false
+	}
+	args := pipe.Cmds[0].Args
+	if len(args) != 1 {
+		return "", false
+	}
+	field, ok := args[0].(*parse.FieldNode)
+	if !ok || len(field.Ident) != 1 || isRowMethod(field.Ident[0]) {
+		return "", false
+	}
+	return field.Ident[0], true
+}
+
+func isRowMethod(name string) bool {
+	_, ok := reflect.TypeOf(CsvRow(nil)).MethodByName(name)
+	return ok
+}
+
+// referencedFields returns the columns that root references or false if
+// root could reference any column.
+func referencedFields(root *parse.ListNode) ([]string, bool) {
+	fields := make(map[string]struct{})
+	if !collectFields(root, fields) {
+		return nil, false
+	}
+	result := make([]string, 0, len(fields))
+	for field := range fields {
+		result = append(result, field)
+	}
+	sort.Strings(result)
+	return result, true
+}
+
+func collectFields(node parse.Node, fields map[string]struct{}) bool {
+	if reflect.ValueOf(node).IsNil() {
+		return true
+	}
+	switch n := node.(type) {
+	case *parse.ListNode:
+		for _, child := range n.Nodes {
+			if !collectFields(child, fields) {
+				return false
+			}
+		}
+	case *parse.ActionNode:
+		return collectFields(n.Pipe, fields)
+	case *parse.IfNode:
+		return collectBranch(&n.BranchNode, fields)
+	case *parse.RangeNode:
+		return collectBranch(&n.BranchNode, fields)
+	case *parse.WithNode:
+		return collectBranch(&n.BranchNode, fields)
+	case *parse.PipeNode:
+		if len(n.Decl) != 0 {
+			return false
+		}
+		for _, cmd := range n.Cmds {
+			if !collectFields(cmd, fields) {
+				return false
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if !collectFields(arg, fields) {
+				return false
+			}
+		}
+	case *parse.FieldNode:
+		if isRowMethod(n.Ident[0]) {
+			return false
+		}
+		fields[n.Ident[0]] = struct{}{}
+	case *parse.ChainNode:
+		return collectFields(n.Node, fields)
+	case *parse.DotNode, *parse.VariableNode, *parse.TemplateNode:
+		return false
+	}
+	return true
+}
+
+func collectBranch(n *parse.BranchNode, fields map[string]struct{}) bool {
+	// Inside range and with, dot no longer refers to the row.
+	if n.Type() != parse.NodeIf {
+		return false
+	}
+	return collectFields(n.Pipe, fields) &&
+		collectFields(n.List, fields) &&
+		collectFields(n.ElseList, fields)
+}
@@ -0,0 +1,197 @@
+package merge
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateFastPath(t *testing.T) {
+	tmpl, err := ParseTemplate("fast", "Dear {{.name}},\nYour pet {{.pet}}.")
+	assert.NoError(t, err)
+	assert.NotNil(t, tmpl.plan)
+	assert.Equal(t, []string{"name", "pet"}, tmpl.Fields())
+	row := CsvRow{"name": "Alice", "email": "alice@gmail.com"}
+	body, err := tmpl.Execute(row)
+	assert.NoError(t, err)
+	assert.Equal(t, slowExecute(t, tmpl, row), body)
+	assert.Equal(t, "Dear Alice,\nYour pet <no value>.", body)
+}
+
+func TestTemplateSlowPath(t *testing.T) {
+	tmpl, err := ParseTemplate(
+		"slow", "Dear {{.Name}}{{if .pet}}, hug {{.pet}}{{end}}.")
+	assert.NoError(t, err)
+	assert.Nil(t, tmpl.plan)
+	assert.Nil(t, tmpl.Fields())
+	body, err := tmpl.Execute(CsvRow{"name": "Bob", "pet": "Rufus"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Dear Bob, hug Rufus.", body)
+}
+
+func TestTemplateFields(t *testing.T) {
+	tmpl, err := ParseTemplate(
+		"fields", "{{if .going}}{{.name}} {{printf \"%s\" .pet}}{{end}}")
+	assert.NoError(t, err)
+	assert.Nil(t, tmpl.plan)
+	assert.Equal(t, []string{"going", "name", "pet"}, tmpl.Fields())
+	tmpl, err = ParseTemplate("fields", "{{range .}}{{.}}{{end}}")
+	assert.NoError(t, err)
+	assert.Nil(t, tmpl.Fields())
+}
+
+func TestTemplateEmpty(t *testing.T) {
+	tmpl, err := ParseTemplate("empty", "")
+	assert.NoError(t, err)
+	body, err := tmpl.Execute(CsvRow{"name": "Bob"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", body)
+}
+
+func TestTemplateNameFuncs(t *testing.T) {
+	tmpl, err := ParseTemplate(
+		"names",
+		"Dear {{title .Name}} {{lastName .Name}} ({{firstName .Name}})")
+	assert.NoError(t, err)
+	body, err := tmpl.Execute(CsvRow{"name": "Dr. Jane Smith"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Dear Dr. Smith (Jane)", body)
+	upper := func(name string) PersonName {
+		return PersonName{First: "JANE"}
+	}
+	tmpl, err = ParseTemplate(
+		"names", "Dear {{firstName .Name}}", WithNameParser(upper))
+	assert.NoError(t, err)
+	body, err = tmpl.Execute(CsvRow{"name": "Dr. Jane Smith"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Dear JANE", body)
+}
+
+func TestTemplateWithFuncs(t *testing.T) {
+	tmpl, err := ParseTemplate(
+		"funcs",
+		"{{shout .name}} {{firstName .name}}",
+		WithFuncs(map[string]any{
+			"shout":     strings.ToUpper,
+			"firstName": func(string) string { return "Pal" },
+		}))
+	assert.NoError(t, err)
+	body, err := tmpl.Execute(CsvRow{"name": "Alice Jones"})
+	assert.NoError(t, err)
+	assert.Equal(t, "ALICE JONES Pal", body)
+}
+
+func TestTemplatePanic(t *testing.T) {
+	tmpl, err := ParseTemplate(
+		"panic",
+		"{{boom .name}}",
+		WithFuncs(map[string]any{
+			"boom": func(string) string { panic("kaboom") },
+		}))
+	assert.NoError(t, err)
+	_, err = tmpl.Execute(CsvRow{"name": "Alice"})
+	var panicErr *PanicError
+	assert.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "kaboom", panicErr.Value)
+	assert.Contains(t, string(panicErr.Stack), "TestTemplatePanic")
+}
+
+func TestTemplatePanicVariadic(t *testing.T) {
+	tmpl, err := ParseTemplate(
+		"variadic",
+		"{{join .name .pet}}",
+		WithFuncs(map[string]any{
+			"join": func(s ...string) string {
+				return strings.Join(s, "+")
+			},
+		}))
+	assert.NoError(t, err)
+	body, err := tmpl.Execute(CsvRow{"name": "Alice", "pet": "Rufus"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Alice+Rufus", body)
+}
+
+func TestTemplateSalutation(t *testing.T) {
+	tmpl, err := ParseTemplate("salutation", "{{salutation .}},")
+	assert.NoError(t, err)
+	cases := []struct {
+		row      CsvRow
+		expected string
+	}{
+		{
+			row: CsvRow{
+				"name":       "Alice Jones",
+				"firstName":  "Ally",
+				"salutation": "Dear Professor Jones",
+			},
+			expected: "Dear Professor Jones,",
+		},
+		{
+			row: CsvRow{
+				"name": "Alice Jones", "firstName": "Ally", "salutation": " "},
+			expected: "Dear Ally,",
+		},
+		{
+			row:      CsvRow{"name": "Dr. Alice Jones"},
+			expected: "Dear Alice,",
+		},
+		{
+			row:      CsvRow{"name": "", "salutation": ""},
+			expected: "Dear guest,",
+		},
+	}
+	for _, c := range cases {
+		body, err := tmpl.Execute(c.row)
+		assert.NoError(t, err)
+		assert.Equal(t, c.expected, body)
+	}
+	tmpl, err = ParseTemplate(
+		"salutation", "{{salutation .}},", GenericSalutation("Hi friend"))
+	assert.NoError(t, err)
+	body, err := tmpl.Execute(CsvRow{"name": " "})
+	assert.NoError(t, err)
+	assert.Equal(t, "Hi friend,", body)
+}
+
+func BenchmarkExecuteFastPath(b *testing.B) {
+	tmpl, row := benchmarkTemplate(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tmpl.Execute(row)
+	}
+}
+
+func BenchmarkExecuteTextTemplate(b *testing.B) {
+	tmpl, row := benchmarkTemplate(b)
+	tmpl.plan = nil
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tmpl.Execute(row)
+	}
+}
+
+func benchmarkTemplate(b *testing.B) (*Template, CsvRow) {
+	tmpl, err := ParseTemplate(
+		"bench",
+		"Dear {{.name}}:\n\nYour pet, {{.petname}} is due for a checkup.\n")
+	if err != nil {
+		b.Fatal(err)
+	}
+	row := CsvRow{"name": "Alice", "email": "alice@gmail.com"}
+	for i := 0; i < 50; i++ {
+		row[fmt.Sprintf("col%d", i)] = "value"
+	}
+	row["petname"] = "Patches"
+	return tmpl, row
+}
+
+func slowExecute(t *testing.T, tmpl *Template, row CsvRow) string {
+	plan := tmpl.plan
+	defer func() { tmpl.plan = plan }()
+	tmpl.plan = nil
+	result, err := tmpl.Execute(row)
+	assert.NoError(t, err)
+	return result
+}
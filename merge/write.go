@@ -0,0 +1,140 @@
+package merge
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// WriteOption represents an option for CsvFile.Write.
+type WriteOption interface {
+	mutate(s *writeSettings)
+}
+
+// CRLF ends lines with \r\n instead of \n.
+func CRLF() WriteOption {
+	return writeOptionFunc(func(s *writeSettings) {
+		s.CRLF = true
+	})
+}
+
+// AlwaysQuote puts quotes around every value, not just the values that
+// need them.
+func AlwaysQuote() WriteOption {
+	return writeOptionFunc(func(s *writeSettings) {
+		s.AlwaysQuote = true
+	})
+}
+
+// BOM starts the file with a UTF-8 byte order mark so that Excel on
+// Windows reads it as UTF-8.
+func BOM() WriteOption {
+	return writeOptionFunc(func(s *writeSettings) {
+		s.BOM = true
+	})
+}
+
+type writeSettings struct {
+	CRLF        bool
+	AlwaysQuote bool
+	BOM         bool
+}
+
+type writeOptionFunc func(s *writeSettings)
+
+func (o writeOptionFunc) mutate(s *writeSettings) {
+	o(s)
+}
+
+// recordWriter writes CSV records. *csv.Writer is a recordWriter.
+type recordWriter interface {
+	Write(record []string) error
+	Flush()
+	Error() error
+}
+
+func newWriteSettings(options []WriteOption) writeSettings {
+	var result writeSettings
+	for _, option := range options {
+		option.mutate(&result)
+	}
+	return result
+}
+
+func newRecordWriter(w io.Writer, settings writeSettings) (
+	recordWriter, error) {
+	if settings.BOM {
+		if _, err := io.WriteString(w, "\ufeff"); err != nil {
+			return nil, err
+		}
+	}
+	// csv.Writer drops carriage returns within values when it ends lines
+	// with \r\n, so quotingWriter does that instead.
+	if settings.AlwaysQuote || settings.CRLF {
+		lineEnd := "\n"
+		if settings.CRLF {
+			lineEnd = "\r\n"
+		}
+		return &quotingWriter{
+			w:        bufio.NewWriter(w),
+			lineEnd:  lineEnd,
+			quoteAll: settings.AlwaysQuote,
+		}, nil
+	}
+	return csv.NewWriter(w), nil
+}
+
+// quotingWriter is a recordWriter that quotes the values that need it
+// or, if quoteAll is true, every value.
+type quotingWriter struct {
+	w        *bufio.Writer
+	lineEnd  string
+	quoteAll bool
+	err      error
+}
+
+func (q *quotingWriter) Write(record []string) error {
+	if q.err != nil {
+		return q.err
+	}
+	for i, field := range record {
+		if i > 0 {
+			q.w.WriteByte(',')
+		}
+		if !q.quoteAll && !needsQuotes(field) {
+			q.w.WriteString(field)
+			continue
+		}
+		q.w.WriteByte('"')
+		q.w.WriteString(strings.ReplaceAll(field, `"`, `""`))
+		q.w.WriteByte('"')
+	}
+	_, q.err = q.w.WriteString(q.lineEnd)
+	return q.err
+}
+
+func (q *quotingWriter) Flush() {
+	if err := q.w.Flush(); err != nil && q.err == nil {
+		q.err = err
+	}
+}
+
+func (q *quotingWriter) Error() error {
+	return q.err
+}
+
+// needsQuotes returns true if field needs quotes using the same rules
+// as csv.Writer.
+func needsQuotes(field string) bool {
+	if field == "" {
+		return false
+	}
+	if field == `\.` || strings.ContainsAny(field, "\",\r\n") {
+		return true
+	}
+	first, _ := utf8.DecodeRuneInString(field)
+	return unicode.IsSpace(first)
+}
@@ -0,0 +1,100 @@
+package merge
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func FuzzRoundTrip(f *testing.F) {
+	f.Add("alice", "alice@gmail.com", "pet", "Rufus")
+	f.Add("Smith, Alice", `a"b@gmail.com`, "note", "line 1\nline 2")
+	f.Add("alice", "alice@gmail.com", "\ufeffpet", "")
+	f.Add(" alice ", "alice@gmail.com", "note", "a\r\nb")
+	f.Add("alice", "alice@gmail.com", "note", `"`)
+	f.Add("", "alice@gmail.com", "note", "")
+	f.Add("alice", "alice@gmail.com", Email, "bob@gmail.com")
+	f.Fuzz(func(t *testing.T, name, email, column, value string) {
+		unreadable := name == "" || email == "" ||
+			column == Name || column == Email
+		csvFile := &CsvFile{
+			Headers: []string{column, Name, Email},
+			Rows: []CsvRow{
+				{column: value, Name: name, Email: email},
+			},
+		}
+		for _, options := range [][]WriteOption{
+			nil, {CRLF()}, {AlwaysQuote()}, {BOM(), CRLF(), AlwaysQuote()},
+		} {
+			var buffer bytes.Buffer
+			err := csvFile.write(&buffer, options...)
+			if unreadable {
+				assert.Error(t, err)
+				assert.Error(t, csvFile.check())
+				continue
+			}
+			assert.NoError(t, err)
+			read, err := readCsv(&buffer)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, crlfToLf(csvFile.Headers), read.Headers)
+			assert.Equal(
+				t,
+				crlfToLf([]string{value, name, email}),
+				[]string{read.Rows[0][read.Headers[0]], read.Rows[0].Name(),
+					read.Rows[0].Email()})
+		}
+	})
+}
+
+// crlfToLf returns values with each \r\n changed to \n as Write
+// promises.
+func crlfToLf(values []string) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		result = append(result, strings.ReplaceAll(value, "\r\n", "\n"))
+	}
+	return result
+}
+
+func TestWriteRefusesUnreadable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.csv")
+	assert.NoError(t, os.WriteFile(path, []byte("keep me\n"), 0644))
+	testCases := []struct {
+		name    string
+		csvFile *CsvFile
+		want    string
+	}{
+		{
+			name: "duplicate header",
+			csvFile: &CsvFile{
+				Headers: []string{Name, Email, "pet", "pet"},
+			},
+			want: "duplicate header: pet",
+		},
+		{
+			name: "no email",
+			csvFile: &CsvFile{
+				Headers: []string{Name, Email},
+				Rows: []CsvRow{
+					{Name: "alice", Email: "alice@gmail.com"},
+					{Name: "bob", Email: ""},
+				},
+			},
+			want: "Row 2: name and email columns must be present",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.EqualError(t, tc.csvFile.Write(path), tc.want)
+			content, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, "keep me\n", string(content))
+		})
+	}
+}
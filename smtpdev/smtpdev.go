@@ -0,0 +1,227 @@
+// Package smtpdev provides an SMTP server that captures emails instead of
+// delivering them along with a web page for viewing what it captured.
+// smtpdev is for rehearsing a mail merge end to end without sending
+// anything. It accepts any credentials and does not support TLS.
+package smtpdev
+
+import (
+	"bufio"
+	"html/template"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a captured email.
+type Message struct {
+
+	// The envelope sender
+	From string
+
+	// The envelope recipients
+	To []string
+
+	// The raw message
+	Data []byte
+
+	// When the message arrived
+	Received time.Time
+}
+
+// Subject returns the subject of this message decoded.
+func (m *Message) Subject() string {
+	msg, err := mail.ReadMessage(strings.NewReader(string(m.Data)))
+	if err != nil {
+		return ""
+	}
+	subject := msg.Header.Get("Subject")
+	decoded, err := new(mime.WordDecoder).DecodeHeader(subject)
+	if err != nil {
+		return subject
+	}
+	return decoded
+}
+
+// Server captures emails sent to it over SMTP. Server also implements
+// http.Handler to show the captured emails.
+type Server struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewServer returns a new Server.
+func NewServer() *Server {
+	return &Server{}
+}
+
+// Serve accepts SMTP connections on listener until listener is closed.
+func (s *Server) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+// Messages returns the captured messages oldest first.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Clear discards the captured messages.
+func (s *Server) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+}
+
+func (s *Server) add(message Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, message)
+}
+
+// ServeHTTP shows the list of captured messages at / and the raw
+// message at /message?id=N.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost && r.URL.Path == "/clear" {
+		s.Clear()
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	messages := s.Messages()
+	if r.URL.Path == "/message" {
+		id, err := strconv.Atoi(r.FormValue("id"))
+		if err != nil || id < 0 || id >= len(messages) {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(messages[id].Data)
+		return
+	}
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	kListTemplate.Execute(w, messages)
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+	var message Message
+	reply("220 smtpdev ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			reply("250-smtpdev")
+			reply("250-8BITMIME")
+			reply("250-SMTPUTF8")
+			reply("250 AUTH PLAIN LOGIN")
+		case "HELO":
+			reply("250 smtpdev")
+		case "AUTH":
+			if strings.HasPrefix(strings.ToUpper(arg), "LOGIN") {
+				reply("334 VXNlcm5hbWU6")
+				r.ReadString('\n')
+				reply("334 UGFzc3dvcmQ6")
+				r.ReadString('\n')
+			}
+			reply("235 Authentication succeeded")
+		case "MAIL":
+			message = Message{From: pathArg(arg)}
+			reply("250 OK")
+		case "RCPT":
+			message.To = append(message.To, pathArg(arg))
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			data, err := readData(r)
+			if err != nil {
+				return
+			}
+			message.Data = data
+			message.Received = time.Now()
+			s.add(message)
+			message = Message{}
+			reply("250 OK")
+		case "RSET":
+			message = Message{}
+			reply("250 OK")
+		case "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// pathArg returns the address in "FROM:<alice@example.com> SMTPUTF8".
+func pathArg(arg string) string {
+	start := strings.Index(arg, "<")
+	end := strings.Index(arg, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return arg[start+1 : end]
+}
+
+func readData(r *bufio.Reader) ([]byte, error) {
+	var result []byte
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if line == ".\r\n" || line == ".\n" {
+			return result, nil
+		}
+		line = strings.TrimPrefix(line, ".")
+		result = append(result, line...)
+	}
+}
+
+var kListTemplate = template.Must(template.New("list").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>smtpdev</title>
+</head>
+<body>
+<h1>{{len .}} captured emails</h1>
+<form method="post" action="/clear"><input type="submit" value="Clear"></form>
+<table>
+<tr><th>#</th><th>Received</th><th>To</th><th>Subject</th></tr>
+{{range $i, $m := .}}<tr>
+<td><a href="/message?id={{$i}}">{{$i}}</a></td>
+<td>{{$m.Received.Format "15:04:05"}}</td>
+<td>{{range $m.To}}{{.}} {{end}}</td>
+<td>{{$m.Subject}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
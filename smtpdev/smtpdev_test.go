@@ -0,0 +1,53 @@
+package smtpdev
+
+import (
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/keep94/mailmerge/mailer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapture(t *testing.T) {
+	server := NewServer()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go server.Serve(listener)
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	m := mailer.NewWithOptions(
+		"alice@example.com",
+		"secret",
+		mailer.Server("127.0.0.1", portNum),
+		mailer.SendWaitTime(0))
+	assert.NoError(t, <-m.SendFuture(mailer.Email{
+		To:      []string{"Bob <bob@example.com>"},
+		Subject: "Café",
+		Body:    "Hi Bob\n.\nBye",
+	}))
+	m.Shutdown()
+	messages := server.Messages()
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "alice@example.com", messages[0].From)
+	assert.Equal(t, []string{"bob@example.com"}, messages[0].To)
+	assert.Equal(t, "Café", messages[0].Subject())
+	assert.True(t, strings.HasSuffix(
+		string(messages[0].Data), "\r\n\r\nHi Bob\r\n.\r\nBye\r\n"))
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Contains(t, w.Body.String(), "Café")
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/message?id=0", nil))
+	assert.Contains(t, w.Body.String(), "Hi Bob")
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/message?id=1", nil))
+	assert.Equal(t, 404, w.Code)
+
+	server.Clear()
+	assert.Empty(t, server.Messages())
+}